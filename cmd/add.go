@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"github.com/pol-rivero/doot/lib/commands/add"
+	"github.com/pol-rivero/doot/lib"
+	"github.com/pol-rivero/doot/lib/engine"
 	"github.com/spf13/cobra"
 )
 
@@ -19,7 +20,8 @@ var addCmd = &cobra.Command{
 		if err != nil {
 			panic(err)
 		}
-		add.Add(args, isCrypt, hostSpecific)
+		_, err = lib.NewCliEngine(cmd).Add(args, engine.AddOptions{Crypt: isCrypt, HostSpecific: hostSpecific})
+		lib.ExitOnError(err)
 	},
 }
 
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/cachecmd"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "cache",
+	Short:   "Inspect and manage the cache of installed files.",
+}
+
+var cacheShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print all the cache entries (one for each dotfiles directory and target directory) and their links.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		cachecmd.Show()
+	},
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export [output_file]",
+	Short: "Write the cache to a file, or to stdout if no file is given (the binary format is not written to a terminal).",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJson, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			panic(err)
+		}
+		SetUpLogger(cmd)
+		outputFile := ""
+		if len(args) > 0 {
+			outputFile = args[0]
+		}
+		cachecmd.Export(outputFile, asJson)
+	},
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import <input_file>",
+	Short: "Read a file created with 'doot cache export' (use '-' for stdin). Its entries replace the existing entries for the same directories.",
+	Run: func(cmd *cobra.Command, args []string) {
+		replace, err := cmd.Flags().GetBool("replace")
+		if err != nil {
+			panic(err)
+		}
+		SetUpLogger(cmd)
+		cachecmd.Import(args[0], replace)
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the cache entries whose dotfiles directory no longer exists, offering to remove their symlinks.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		cachecmd.Prune()
+	},
+}
+
+var cacheRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild the cache of the current dotfiles directory by searching the target directory for links to it.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		cachecmd.Rebuild()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheExportCmd)
+	cacheCmd.AddCommand(cacheImportCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheRebuildCmd)
+
+	cacheShowCmd.Args = cobra.NoArgs
+
+	cacheExportCmd.Args = cobra.MaximumNArgs(1)
+	cacheExportCmd.ArgAliases = []string{"output_file"}
+	cacheExportCmd.Flags().Bool("json", false, "Export as JSON instead of the binary format of the cache file.")
+
+	cacheImportCmd.Args = cobra.ExactArgs(1)
+	cacheImportCmd.ArgAliases = []string{"input_file"}
+	cacheImportCmd.Flags().Bool("replace", false, "Replace the whole cache instead of only the imported entries.")
+
+	cachePruneCmd.Args = cobra.NoArgs
+	cacheRebuildCmd.Args = cobra.NoArgs
+}
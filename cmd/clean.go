@@ -1,27 +1,31 @@
 package cmd
 
 import (
-	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib"
+	"github.com/pol-rivero/doot/lib/engine"
 	"github.com/spf13/cobra"
 )
 
 var cleanCmd = &cobra.Command{
 	GroupID: basicCommandsGroup.ID,
-	Use:     "clean",
-	Short:   "Remove all symlinks created by doot.",
+	Use:     "clean [path ...]",
+	Short:   "Remove all symlinks created by doot. If paths or globs are given (installed paths or dotfiles), only the matching files are removed.",
 	Run: func(cmd *cobra.Command, args []string) {
 		SetUpLogger(cmd)
 		fullClean, err := cmd.Flags().GetBool("full-clean")
 		if err != nil {
 			panic(err)
 		}
-		install.Clean(fullClean)
+		_, err = lib.NewCliEngine(cmd).Clean(engine.InstallOptions{Paths: args, FullClean: fullClean})
+		lib.ExitOnError(err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(cleanCmd)
 
-	cleanCmd.Args = cobra.NoArgs
+	cleanCmd.Args = cobra.ArbitraryArgs
+	cleanCmd.ArgAliases = []string{"path"}
 	cleanCmd.Flags().Bool("full-clean", false, "Search and remove all broken symlinks that point to the dotfiles directory, even if they were created by another program. Can be slow.")
+	cleanCmd.Flags().Bool("dry-run", false, "Show what would be changed without modifying any file. Conflicts are still reported, but hooks are not run.")
 }
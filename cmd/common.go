@@ -1,6 +1,11 @@
 package cmd
 
 import (
+	"path/filepath"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
 	"github.com/pol-rivero/doot/lib/common/log"
 	"github.com/spf13/cobra"
 )
@@ -17,3 +22,26 @@ func SetUpLogger(cmd *cobra.Command) {
 
 	log.Init(verbose, quiet)
 }
+
+func SetUpDirectoryFlags(cmd *cobra.Command) {
+	dotfilesDir, err := cmd.Flags().GetString("dotfiles-dir")
+	if err != nil {
+		panic(err)
+	}
+	targetDir, err := cmd.Flags().GetString("target-dir")
+	if err != nil {
+		panic(err)
+	}
+
+	if dotfilesDir != "" {
+		common.SetDotfilesDirOverride(dotfilesDir)
+	}
+	if targetDir != "" {
+		config.SetTargetDirOverride(targetDir)
+		absTargetDir, err := filepath.Abs(targetDir)
+		if err != nil {
+			log.Fatal("Invalid target directory %s: %v", targetDir, err)
+		}
+		cache.SetCacheDirOverride(filepath.Join(absTargetDir, ".cache", "doot"))
+	}
+}
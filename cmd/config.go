@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/configcmd"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "config",
+	Short:   "Inspect and validate the configuration files.",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config files for syntax errors, unknown keys and invalid values. Exits with a non-zero code if there are problems.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		configcmd.Validate()
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective config, after applying the host-specific and local config files.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		configcmd.Show()
+	},
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON schema of the config file, for editor completion and validation.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		configcmd.Schema()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSchemaCmd)
+
+	configValidateCmd.Args = cobra.NoArgs
+	configShowCmd.Args = cobra.NoArgs
+	configSchemaCmd.Args = cobra.NoArgs
+}
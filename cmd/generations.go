@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/generationscmd"
+	"github.com/spf13/cobra"
+)
+
+var generationsCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "generations",
+	Short:   "Inspect the generations (sets of installed links) recorded after every successful install.",
+}
+
+var generationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the generations of the current dotfiles directory, newest first. Use --verbose to see their links.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		generationscmd.List()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generationsCmd)
+
+	generationsCmd.AddCommand(generationsListCmd)
+
+	generationsListCmd.Args = cobra.NoArgs
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/ignore"
+	"github.com/spf13/cobra"
+)
+
+var ignoreCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "ignore <installed_file> [installed_file2 ...]",
+	Short:   "Don't install the given files or directories in this machine, and remove them if they are already installed. This setting is not stored in the dotfiles directory.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		ignore.Ignore(args)
+	},
+}
+
+var unignoreCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "unignore <installed_file> [installed_file2 ...]",
+	Short:   "Undo 'doot ignore' and install the given files again.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		ignore.Unignore(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ignoreCmd)
+	rootCmd.AddCommand(unignoreCmd)
+
+	ignoreCmd.Args = cobra.MinimumNArgs(1)
+	ignoreCmd.ArgAliases = []string{"installed_file"}
+
+	unignoreCmd.Args = cobra.MinimumNArgs(1)
+	unignoreCmd.ArgAliases = []string{"installed_file"}
+}
@@ -7,17 +7,19 @@ import (
 
 var installCmd = &cobra.Command{
 	GroupID: basicCommandsGroup.ID,
-	Use:     "install",
-	Short:   "Install or incrementally update the symlinks. This is the default command.",
+	Use:     "install [path ...]",
+	Short:   "Install or incrementally update the symlinks. This is the default command. If paths or globs are given (installed paths or dotfiles), only the matching files are updated.",
 	Run: func(cmd *cobra.Command, args []string) {
 		SetUpLogger(cmd)
-		lib.ExecuteInstall(cmd)
+		lib.ExecuteInstall(cmd, args)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
 
-	installCmd.Args = cobra.NoArgs
+	installCmd.Args = cobra.ArbitraryArgs
+	installCmd.ArgAliases = []string{"path"}
 	installCmd.Flags().Bool("full-clean", false, "Search and remove all broken symlinks that point to the dotfiles directory, even if they were created by another program. Can be slow.")
+	installCmd.Flags().Bool("dry-run", false, "Show what would be changed without modifying any file. Conflicts are still reported, but hooks are not run.")
 }
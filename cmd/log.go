@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/journalcmd"
+	"github.com/spf13/cobra"
+)
+
+var logCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "log",
+	Short:   "List the operations that modified files, newest first. Use --verbose to see every change.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		journalcmd.PrintLog()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+
+	logCmd.Args = cobra.NoArgs
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/module"
+	"github.com/spf13/cobra"
+)
+
+var moduleCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "module",
+	Short:   "Select which modules (packages of dotfiles, such as 'nvim' or 'zsh') are installed in this machine.",
+}
+
+var moduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available modules and whether they are enabled in this machine.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		module.List()
+	},
+}
+
+var moduleEnableCmd = &cobra.Command{
+	Use:   "enable <module> [module2 ...]",
+	Short: "Enable modules in this machine (overrides the config file) and install them.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		module.Enable(args)
+	},
+}
+
+var moduleDisableCmd = &cobra.Command{
+	Use:   "disable <module> [module2 ...]",
+	Short: "Disable modules in this machine (overrides the config file) and remove their symlinks.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		module.Disable(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(moduleCmd)
+
+	moduleCmd.AddCommand(moduleListCmd)
+	moduleCmd.AddCommand(moduleEnableCmd)
+	moduleCmd.AddCommand(moduleDisableCmd)
+
+	moduleListCmd.Args = cobra.NoArgs
+
+	moduleEnableCmd.Args = cobra.MinimumNArgs(1)
+	moduleEnableCmd.ArgAliases = []string{"module"}
+
+	moduleDisableCmd.Args = cobra.MinimumNArgs(1)
+	moduleDisableCmd.ArgAliases = []string{"module"}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/relocate"
+	"github.com/spf13/cobra"
+)
+
+var relocateCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "relocate <old_dir> <new_dir>",
+	Short:   "Update the installed links after moving the dotfiles directory or changing the target directory.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		relocate.Relocate(args[0], args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relocateCmd)
+
+	relocateCmd.Args = cobra.ExactArgs(2)
+	relocateCmd.ArgAliases = []string{"old_dir", "new_dir"}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/repo"
+	"github.com/spf13/cobra"
+)
+
+var repoCmd = &cobra.Command{
+	GroupID: advancedCommandsGroup.ID,
+	Use:     "repo",
+	Short:   "Manage the dotfiles repositories registered in this machine ($XDG_CONFIG_HOME/doot/doot.toml).",
+}
+
+var repoAddCmd = &cobra.Command{
+	Use:   "add <dotfiles_dir> [name]",
+	Short: "Register a dotfiles directory. The name defaults to the name of the directory, and renames the directory if it is already registered.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+		repo.Add(args[0], name)
+	},
+}
+
+var repoListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registered repositories. The one in use is marked with '*'.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		repo.List()
+	},
+}
+
+var repoUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Use a registered repository by default (unless $DOOT_DIR or --dotfiles-dir are set).",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		repo.Use(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repoCmd)
+
+	repoCmd.AddCommand(repoAddCmd)
+	repoCmd.AddCommand(repoListCmd)
+	repoCmd.AddCommand(repoUseCmd)
+
+	repoAddCmd.Args = cobra.RangeArgs(1, 2)
+	repoAddCmd.ArgAliases = []string{"dotfiles_dir", "name"}
+
+	repoListCmd.Args = cobra.NoArgs
+
+	repoUseCmd.Args = cobra.ExactArgs(1)
+	repoUseCmd.ArgAliases = []string{"name"}
+}
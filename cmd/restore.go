@@ -1,7 +1,9 @@
 package cmd
 
 import (
-	"github.com/pol-rivero/doot/lib/commands/restore"
+	"os"
+
+	"github.com/pol-rivero/doot/lib"
 	"github.com/spf13/cobra"
 )
 
@@ -11,7 +13,11 @@ var restoreCmd = &cobra.Command{
 	Short:   "Opposite of 'doot add'. Replace symlinks with the original files.",
 	Run: func(cmd *cobra.Command, args []string) {
 		SetUpLogger(cmd)
-		restore.Restore(args)
+		restored, err := lib.NewCliEngine(cmd).Restore(args)
+		lib.ExitOnError(err)
+		if len(restored) == 0 {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/pol-rivero/doot/lib"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	GroupID: basicCommandsGroup.ID,
+	Use:     "rollback [generation]",
+	Short:   "Restore the links of a previous generation (see 'doot generations list'). By default, the one before the current generation.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		number := 0
+		if len(args) > 0 {
+			var err error
+			number, err = strconv.Atoi(args[0])
+			if err != nil || number <= 0 {
+				log.Fatal("Invalid generation number: %s", args[0])
+			}
+		}
+		_, err := lib.NewCliEngine(cmd).Rollback(number)
+		lib.ExitOnError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+
+	rollbackCmd.Args = cobra.MaximumNArgs(1)
+	rollbackCmd.ArgAliases = []string{"generation"}
+	rollbackCmd.Flags().Bool("dry-run", false, "Show what would be changed without modifying any file. Conflicts are still reported, but hooks are not run.")
+}
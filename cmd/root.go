@@ -50,7 +50,14 @@ func init() {
 	rootCmd.AddGroup(otherCommandsGroup)
 
 	rootCmd.Flags().Bool("full-clean", false, "Search and remove all broken symlinks that point to the dotfiles directory, even if they were created by another program. Can be slow.")
+	rootCmd.Flags().Bool("dry-run", false, "Show what would be changed without modifying any file. Conflicts are still reported, but hooks are not run.")
 
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		SetUpDirectoryFlags(cmd)
+	}
+
+	rootCmd.PersistentFlags().String("dotfiles-dir", "", "Use this dotfiles directory instead of $DOOT_DIR or the default locations.")
+	rootCmd.PersistentFlags().String("target-dir", "", "Install into this directory instead of the target_dir of the config file. The cache is stored in <target-dir>/.cache/doot, unless $DOOT_CACHE_DIR is set.")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Print additional information to stdout.")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Suppress warnings and errors.")
 	rootCmd.SetHelpCommandGroupID(otherCommandsGroup.ID)
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/status"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	GroupID: basicCommandsGroup.ID,
+	Use:     "status",
+	Short:   "Show the dotfiles and target directories, and the settings that only apply to this machine.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		status.PrintStatus()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Args = cobra.NoArgs
+}
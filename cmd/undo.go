@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/pol-rivero/doot/lib"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	GroupID: basicCommandsGroup.ID,
+	Use:     "undo",
+	Short:   "Revert the changes made by the last install, clean, add or restore. Run it again to revert older operations.",
+	Run: func(cmd *cobra.Command, args []string) {
+		SetUpLogger(cmd)
+		_, err := lib.NewCliEngine(cmd).Undo()
+		lib.ExitOnError(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Args = cobra.NoArgs
+}
@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"os"
+
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/engine"
+	"github.com/spf13/cobra"
+)
+
+// The engine used by the doot command. The --dotfiles-dir and --target-dir flags are applied by SetUpDirectoryFlags.
+func NewCliEngine(cmd *cobra.Command) *engine.Engine {
+	dryRun := false
+	if cmd.Flags().Lookup("dry-run") != nil {
+		var err error
+		if dryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+			panic(err)
+		}
+	}
+	return engine.New(engine.Options{HandleSignals: true, DryRun: dryRun})
+}
+
+// Exits with a non-zero code if the command failed. Fatal errors have already been printed.
+func ExitOnError(err error) {
+	if err == nil {
+		return
+	}
+	if _, isFatal := err.(*log.FatalError); !isFatal {
+		log.Error("%v", err)
+	}
+	if log.PanicInsteadOfExit {
+		panic(err.Error())
+	}
+	os.Exit(1)
+}
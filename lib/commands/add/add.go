@@ -9,37 +9,40 @@ import (
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/cache"
 	"github.com/pol-rivero/doot/lib/common/config"
-	"github.com/pol-rivero/doot/lib/common/glob_collection"
+	"github.com/pol-rivero/doot/lib/common/hosts"
+	"github.com/pol-rivero/doot/lib/common/journal"
 	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/name_mapping"
 	"github.com/pol-rivero/doot/lib/linkmode"
 	. "github.com/pol-rivero/doot/lib/types"
 	file_utils "github.com/pol-rivero/doot/lib/utils/files"
-	"github.com/pol-rivero/doot/lib/utils/set"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
-func Add(files []string, isCrypt bool, isHostSpecific bool) {
+func Add(files []string, isCrypt bool, isHostSpecific bool) install.Changes {
+	journal.Begin("add", files)
+	defer journal.End()
 	dotfilesDir := common.FindDotfilesDir()
 	config := config.FromDotfilesDir(dotfilesDir)
 	linkMode := linkmode.GetLinkMode(&config)
 
 	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	journal.SetCacheKey(cacheKey)
 	cache := cache.Load()
 	installedLinks := cache.GetEntry(cacheKey).GetLinks()
 
 	params := ProcessAddedFileParams{
-		crypt:             isCrypt,
-		hostSpecificDir:   getHostSpecificDir(&config, isHostSpecific),
-		dotfilesDir:       dotfilesDir.Str(),
-		targetDir:         config.TargetDir,
-		implicitDot:       config.ImplicitDot,
-		implicitDotIgnore: set.NewFromSlice(config.ImplicitDotIgnore),
-		includeFiles:      glob_collection.NewGlobCollection(config.IncludeFiles),
-		excludeFiles:      glob_collection.NewGlobCollection(config.ExcludeFiles),
+		crypt:           isCrypt,
+		hostSpecificDir: getHostSpecificDir(&config, isHostSpecific),
+		dotfilesDir:     dotfilesDir.Str(),
+		targetDir:       config.TargetDir,
+		nameMapping:     name_mapping.NewNameMapping(&config),
+		filter:          install.CreateFilter(&config, false),
 	}
 
 	if isCrypt && !crypt.GitCryptIsInitialized(dotfilesDir) {
 		log.Error("Can't add private files with --crypt flag because repository is not initialized. Run 'doot crypt init' first.")
-		return
+		return install.Changes{}
 	}
 
 	addedFiles := make([]AbsolutePath, 0, 16)
@@ -54,14 +57,16 @@ func Add(files []string, isCrypt bool, isHostSpecific bool) {
 			continue
 		}
 		dotfilePath := dotfilesDir.JoinPath(dotfileRelativePath)
-		err = os.MkdirAll(filepath.Dir(dotfilePath.Str()), 0755)
+		err = filesystem.MkdirAll(filepath.Dir(dotfilePath.Str()), 0755)
 		if err != nil {
 			log.Error("Error creating directory %s: %v", filepath.Dir(dotfilePath.Str()), err)
 			continue
 		}
 		// Prefer hardlinking to avoid copying large files. The original file will be replaced on install anyway.
 		// The dotfiles directory may reside in a different filesystem than the home directory, fallback to copy if hardlinking fails.
+		change := journal.Prepare(dotfilePath, dotfilesDir)
 		err = file_utils.HardlinkOrCopyFile(file, dotfilePath.Str(), false)
+		change.Done(err == nil)
 		if err == nil {
 			log.Info("Copied file %s -> %s", file, dotfilePath)
 			addedFiles = append(addedFiles, RelativeToPWD(file))
@@ -73,25 +78,22 @@ func Add(files []string, isCrypt bool, isHostSpecific bool) {
 	}
 
 	log.Info("Files have been copied to the dotfiles directory, now running 'install'...")
-	install.InstallAfterAdd(false, addedFiles)
+	return install.InstallAfterAdd(false, addedFiles)
 }
 
 func getHostSpecificDir(config *config.Config, isHostSpecific bool) string {
 	if !isHostSpecific {
 		return ""
 	}
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Error("Error getting hostname: %v", err)
-		return ""
-	}
-	hostSpecificDir, ok := config.Hosts[hostname]
-	if !ok {
-		log.Fatal(`--host flag is set but your hostname (%s) is not in the hosts map. Consider adding the following to your doot config:
+	hostname := common.GetHostname()
+	activeDirs, _ := hosts.ResolveHostDirs(config, hostname)
+	if len(activeDirs) == 0 {
+		log.Fatal(`--host flag is set but your hostname (%s) does not match any entry in the hosts map. Consider adding the following to your doot config:
 [hosts]
 "%s" = "%s-files"`, hostname, hostname, hostname)
 	}
-	return hostSpecificDir
+	// Add the file to the most specific directory, so that it overrides the rest
+	return activeDirs[len(activeDirs)-1].Dir
 }
 
 func alreadyManaged(file string, installedLinks *SymlinkCollection, linkMode linkmode.LinkMode) bool {
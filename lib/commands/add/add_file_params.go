@@ -7,27 +7,26 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/pol-rivero/doot/lib/commands/install"
 	"github.com/pol-rivero/doot/lib/common"
-	"github.com/pol-rivero/doot/lib/common/glob_collection"
 	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/name_mapping"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils"
-	"github.com/pol-rivero/doot/lib/utils/set"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 type ProcessAddedFileParams struct {
-	crypt             bool
-	hostSpecificDir   string
-	dotfilesDir       string
-	targetDir         string
-	implicitDot       bool
-	implicitDotIgnore set.Set[string]
-	includeFiles      glob_collection.GlobCollection
-	excludeFiles      glob_collection.GlobCollection
+	crypt           bool
+	hostSpecificDir string
+	dotfilesDir     string
+	targetDir       string
+	nameMapping     name_mapping.NameMapping
+	filter          install.FileFilter
 }
 
 func ProcessAddedFile(input string, params ProcessAddedFileParams) (RelativePath, error) {
-	fileInfo, err := os.Lstat(input)
+	fileInfo, err := filesystem.Lstat(input)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return "", errors.New("this file does not exist")
@@ -37,7 +36,7 @@ func ProcessAddedFile(input string, params ProcessAddedFileParams) (RelativePath
 	if fileInfo.IsDir() {
 		return "", fmt.Errorf("it's a directory. Consider adding %s/**/* instead", input)
 	}
-	cleanAbsFile, err := filepath.Abs(input)
+	cleanAbsFile, err := filesystem.Abs(input)
 	if err != nil {
 		return "", fmt.Errorf("error getting absolute path: %v", err)
 	}
@@ -50,19 +49,12 @@ func ProcessAddedFile(input string, params ProcessAddedFileParams) (RelativePath
 		return "", fmt.Errorf("error getting relative path: %v", err)
 	}
 
-	if params.implicitDot && !params.implicitDotIgnore.Contains(relPath.TopLevelDir()) {
-		if relPath.IsHidden() {
-			relPath = relPath.Unhide()
-		} else {
-			return "", fmt.Errorf("its current filename is impossible because implicit_dot is true. Add '%s' to implicit_dot_ignore to fix this", relPath.TopLevelDir())
-		}
-	}
-
-	if params.crypt && !strings.Contains(relPath.Str(), common.DOOT_CRYPT_EXT) {
-		relPath = addDootCryptExtension(relPath)
+	relPath, err = params.nameMapping.TargetToSource(relPath, params.crypt)
+	if err != nil {
+		return "", err
 	}
 
-	if err := checkIsIncluded(relPath, params.includeFiles, params.excludeFiles); err != nil {
+	if err := checkIsIncluded(relPath, &params.filter); err != nil {
 		return "", err
 	}
 
@@ -83,14 +75,14 @@ func constructRelativePath(absPath string, params ProcessAddedFileParams) (Relat
 
 	for i := range len(parts) - 1 {
 		currentAbsDir := filepath.Join(appendTo(params.dotfilesDir, parts[:i+1])...)
-		if stat, err := os.Stat(currentAbsDir); err == nil && stat.IsDir() {
+		if stat, err := filesystem.Stat(currentAbsDir); err == nil && stat.IsDir() {
 			continue
 		}
 
 		log.Info("%s does not exist, checking for crypt directory", currentAbsDir)
 		parentDir := filepath.Join(appendTo(params.dotfilesDir, parts[:i])...)
 		cryptDir := filepath.Join(parentDir, parts[i]+common.DOOT_CRYPT_EXT)
-		if stat, err := os.Stat(cryptDir); err == nil && stat.IsDir() {
+		if stat, err := filesystem.Stat(cryptDir); err == nil && stat.IsDir() {
 			canUseCrypt := params.crypt || utils.RequestInput("Yn", "Do you want to add '%s' inside the existing directory '%s'? It will become encrypted even though you didn't use the --crypt flag. Press N to create a new directory '%s'", absPath, cryptDir, currentAbsDir) == 'y'
 			if !canUseCrypt {
 				continue
@@ -106,28 +98,9 @@ func appendTo(base string, elements []string) []string {
 	return append([]string{base}, elements...)
 }
 
-func checkIsIncluded(relPath RelativePath, includeFiles glob_collection.GlobCollection, excludeFiles glob_collection.GlobCollection) error {
-	if relPath.Str() == "." {
-		return nil
-	}
-	if excludeFiles.Matches(relPath) && !includeFiles.Matches(relPath) {
-		return fmt.Errorf("%s matches exclude_files but is not included in include_files", relPath)
-	}
-	return checkIsIncluded(relPath.Parent(), includeFiles, excludeFiles)
-}
-
-func addDootCryptExtension(relPath RelativePath) RelativePath {
-	dir, file := relPath.Split()
-	parts := strings.Split(file, ".")
-	if len(parts) == 0 {
-		panic("impossible according to strings.Split spec, since sep is not empty")
-	}
-	if len(parts) == 1 || (len(parts) == 2 && parts[0] == "") {
-		// file.DOOT-CRYPT or .file.DOOT-CRYPT
-		parts = append(parts, common.DOOT_CRYPT_EXT_WITHOUT_DOT)
-	} else {
-		// some.file.DOOT-CRYPT.ext
-		parts = append(parts[:len(parts)-1], common.DOOT_CRYPT_EXT_WITHOUT_DOT, parts[len(parts)-1])
+func checkIsIncluded(relPath RelativePath, filter *install.FileFilter) error {
+	if !filter.IsPathIncluded(relPath) {
+		return fmt.Errorf("%s is excluded by the include/exclude rules in the config file", relPath)
 	}
-	return RelativePath(filepath.Join(dir.Str(), strings.Join(parts, ".")))
+	return nil
 }
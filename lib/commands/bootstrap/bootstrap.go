@@ -1,23 +1,19 @@
 package bootstrap
 
 import (
-	"os"
-
 	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/repo"
 	"github.com/pol-rivero/doot/lib/common"
-	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils/optional"
 )
 
-func Bootstrap(repo string, dotfilesDirRel string, keyPath optional.Optional[string]) {
+func Bootstrap(repoUrl string, dotfilesDirRel string, keyPath optional.Optional[string]) {
 	dotfilesDir := RelativeToPWD(dotfilesDirRel)
-	CloneRepoOrExit(repo, dotfilesDir)
+	CloneRepoOrExit(repoUrl, dotfilesDir)
 
-	err := os.Setenv(common.ENV_DOOT_DIR, dotfilesDir.Str())
-	if err != nil {
-		log.Fatal("Error setting DOOT_DIR environment variable: %v", err)
-	}
+	common.SetDotfilesDirOverride(dotfilesDir.Str())
+	repo.RegisterAndUse(dotfilesDir)
 
 	UnlockIfNeeded(dotfilesDir, keyPath)
 
@@ -0,0 +1,215 @@
+package cachecmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/layers"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/linkmode"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Prints every cache entry (one per dotfiles directory and target directory) and its links
+func Show() {
+	dootCache := cache.Load()
+	if len(dootCache.Entries) == 0 {
+		log.Printlnf("The cache is empty")
+		return
+	}
+	var sb strings.Builder
+	for i, entry := range dootCache.Entries {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		dotfilesDir, targetDir := cache.SplitCacheKey(entry.CacheKey)
+		files := entry.InstalledFiles
+		sb.WriteString(fmt.Sprintf("%s -> %s\n", dotfilesDir, targetDir))
+		if !dirExists(dotfilesDir.Str()) {
+			sb.WriteString("  The dotfiles directory no longer exists (run 'doot cache prune' to remove this entry)\n")
+		}
+		sb.WriteString(fmt.Sprintf("  Links: %d, generated files: %d\n", len(files.Links), len(files.Generated)))
+		writeList(&sb, "Modules enabled in this machine", files.EnabledModules)
+		writeList(&sb, "Modules disabled in this machine", files.DisabledModules)
+		writeList(&sb, "Ignored in this machine", files.IgnoredTargets)
+		links := files.GetLinks()
+		for _, line := range strings.Split(strings.TrimSuffix(links.PrintList(), "\n"), "\n") {
+			if line != "" {
+				sb.WriteString("    " + line + "\n")
+			}
+		}
+	}
+	log.Printlnf("%s", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+// Writes the whole cache to the file (or stdout if it's empty), as JSON or in the binary format of the cache file
+func Export(outputFile string, asJson bool) {
+	toStdout := outputFile == "" || outputFile == "-"
+	if toStdout && !asJson && stdoutIsTerminal() {
+		log.Fatal("Refusing to write the binary cache to the terminal. Use --json, give an output file or redirect the output.")
+	}
+	dootCache := cache.Load()
+	var data []byte
+	var err error
+	if asJson {
+		data, err = dootCache.MarshalJson()
+		data = append(data, '\n')
+	} else {
+		data, err = dootCache.MarshalBinary()
+	}
+	if err != nil {
+		log.Fatal("Error serializing the cache: %v", err)
+	}
+	if toStdout {
+		if _, err := os.Stdout.Write(data); err != nil {
+			log.Fatal("Error writing the cache: %v", err)
+		}
+		return
+	}
+	if err := filesystem.WriteFile(outputFile, data, 0644); err != nil {
+		log.Fatal("Error writing %s: %v", outputFile, err)
+	}
+	log.Printlnf("Exported %d cache entries to %s", len(dootCache.Entries), outputFile)
+}
+
+// Reads a file created with 'export' (in either format). Its entries replace the entries of the cache with the same
+// dotfiles and target directories, or the whole cache if replaceAll is set.
+func Import(inputFile string, replaceAll bool) {
+	data, err := readInput(inputFile)
+	if err != nil {
+		log.Fatal("Error reading %s: %v", inputFile, err)
+	}
+	var imported cache.DootCache
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = imported.UnmarshalJson(data)
+	} else {
+		err = imported.UnmarshalBinary(data)
+		if err == nil && imported.Version != cache.CURRENT_CACHE_VERSION {
+			err = fmt.Errorf("unsupported cache version %d (expected %d)", imported.Version, cache.CURRENT_CACHE_VERSION)
+		}
+	}
+	if err != nil {
+		log.Fatal("Invalid cache file %s: %v", inputFile, err)
+	}
+
+	dootCache := cache.Load()
+	if replaceAll {
+		dootCache.Entries = []*cache.CacheEntry{}
+	}
+	for _, entry := range imported.Entries {
+		dootCache.Entries = slices.DeleteFunc(dootCache.Entries, func(e *cache.CacheEntry) bool {
+			return e.CacheKey == entry.CacheKey
+		})
+		dootCache.Entries = append(dootCache.Entries, entry)
+	}
+	dootCache.Save()
+	log.Printlnf("Imported %d cache entries", len(imported.Entries))
+}
+
+// Removes the entries whose dotfiles directory no longer exists, offering to remove their symlinks first
+func Prune() {
+	journal.Begin("cache prune", nil)
+	defer journal.End()
+	dootCache := cache.Load()
+	pruned := 0
+	for _, entry := range slices.Clone(dootCache.Entries) {
+		dotfilesDir, targetDir := cache.SplitCacheKey(entry.CacheKey)
+		if targetDir == "" {
+			log.Warning("Skipping the cache entry %s, its key is not valid", entry.CacheKey)
+			continue
+		}
+		if dirExists(dotfilesDir.Str()) {
+			continue
+		}
+		journal.AddCacheKey(entry.CacheKey)
+		brokenLinks := findLinksInto(entry.InstalledFiles, dotfilesDir)
+		if len(brokenLinks) > 0 {
+			clean := utils.RequestInput("yN", "The dotfiles directory %s no longer exists, but %d symlinks in %s still point to it. Remove them?", dotfilesDir, len(brokenLinks), targetDir)
+			if clean == 'y' {
+				for _, link := range brokenLinks {
+					log.Info("Removing link %s", link)
+					change := journal.Prepare(link, NewAbsolutePath(targetDir))
+					change.Done(files.RemoveAndCleanup(link, NewAbsolutePath(targetDir)))
+				}
+			}
+		}
+		log.Printlnf("Removing cache entry %s -> %s", dotfilesDir, targetDir)
+		dootCache.Entries = slices.DeleteFunc(dootCache.Entries, func(e *cache.CacheEntry) bool { return e == entry })
+		pruned++
+	}
+	if pruned == 0 {
+		log.Printlnf("Nothing to prune, all the dotfiles directories in the cache exist")
+		return
+	}
+	journal.Atomically(dootCache.Save)
+}
+
+// Replaces the links of the current cache entry with the links found in the target directory, like 'install --full-clean'
+// but without installing or removing anything
+func Rebuild() {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+	linkMode := linkmode.GetLinkMode(&config)
+	layerList := layers.Resolve(dotfilesDir, &config)
+
+	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	dootCache := cache.Load()
+	installedFilesCache := dootCache.GetEntry(cacheKey)
+	previousCount := len(installedFilesCache.Links)
+	installedFilesCache.Links = install.RecalculateCache(linkMode, layerList, config.TargetDir)
+	dootCache.Save()
+	log.Printlnf("Rebuilt the cache of %s: found %d links (previously %d)", config.TargetDir, len(installedFilesCache.Links), previousCount)
+}
+
+// Only symlinks are considered, hardlinks may be the only remaining copy of the dotfile
+func findLinksInto(entry *cache.InstalledFilesCache, dotfilesDir AbsolutePath) []AbsolutePath {
+	links := make([]AbsolutePath, 0)
+	prefix := dotfilesDir.Str() + string(filepath.Separator)
+	for _, link := range entry.Links {
+		fileInfo, err := filesystem.Lstat(link.Path)
+		if err != nil || !common.IsSymlink(fileInfo) {
+			continue
+		}
+		content, err := filesystem.Readlink(link.Path)
+		if err == nil && strings.HasPrefix(content, prefix) {
+			links = append(links, NewAbsolutePath(link.Path))
+		}
+	}
+	return links
+}
+
+func readInput(inputFile string) ([]byte, error) {
+	if inputFile == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return filesystem.ReadFile(inputFile)
+}
+
+func writeList(sb *strings.Builder, title string, items []string) {
+	if len(items) > 0 {
+		sb.WriteString(fmt.Sprintf("  %s: %s\n", title, strings.Join(items, ", ")))
+	}
+}
+
+func dirExists(path string) bool {
+	fileInfo, err := filesystem.Stat(path)
+	return err == nil && fileInfo.IsDir()
+}
+
+func stdoutIsTerminal() bool {
+	fileInfo, err := os.Stdout.Stat()
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
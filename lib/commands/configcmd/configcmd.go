@@ -0,0 +1,44 @@
+package configcmd
+
+import (
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+)
+
+func Validate() {
+	dotfilesDir := common.FindDotfilesDir()
+	result := config.LoadFromDotfilesDir(dotfilesDir)
+
+	if len(result.Files) == 0 {
+		log.Printlnf("No config file found, the default config is used")
+	}
+	for _, file := range result.Files {
+		log.Printlnf("Checked %s", file)
+	}
+	if len(result.Problems) == 0 {
+		log.Printlnf("The config is valid")
+		return
+	}
+	for _, problem := range result.Problems {
+		log.Printlnf("%s", problem)
+	}
+	log.Fatal("Found %d problem(s) in the config", len(result.Problems))
+}
+
+// Prints the effective config, after applying the overlays and expanding the environment variables
+func Show() {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+
+	configBytes, err := toml.Marshal(config)
+	if err != nil {
+		log.Fatal("Error serializing config: %v", err)
+	}
+	log.Printlnf("%s", configBytes)
+}
+
+func Schema() {
+	log.Printlnf("%s", config.JSONSchema())
+}
@@ -2,7 +2,6 @@ package crypt
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/pol-rivero/doot/lib/utils/optional"
 )
 
@@ -63,7 +63,7 @@ func gitCryptKeyExists(dotfilesDir AbsolutePath) bool {
 		return false
 	}
 	keyPath := repoDir.Value().JoinPath(gitCryptKeyPath)
-	fileInfo, err := os.Stat(keyPath.Str())
+	fileInfo, err := filesystem.Stat(keyPath.Str())
 	return err == nil && fileInfo.Mode().IsRegular()
 }
 
@@ -73,7 +73,7 @@ func gitAttributesIsSet(dotfilesDir AbsolutePath) bool {
 		return false
 	}
 	attributesPath := repoDir.Value().JoinPath(gitAttributesPath)
-	attributesContent, err := os.ReadFile(attributesPath.Str())
+	attributesContent, err := filesystem.ReadFile(attributesPath.Str())
 	if err != nil {
 		return false
 	}
@@ -88,18 +88,12 @@ func appendGitAttributes(dotfilesDir AbsolutePath) error {
 	attributesPath := repoDir.Value().JoinPath(gitAttributesPath)
 	content := getGitAttributesContent(true)
 
-	f, err := os.OpenFile(attributesPath.Str(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	_, err = f.WriteString(content)
-	return err
+	return filesystem.AppendFile(attributesPath.Str(), []byte(content), 0644)
 }
 
 func getRepoRootPath(dirInRepo AbsolutePath) optional.Optional[AbsolutePath] {
 	for i := 0; i < MAX_PARENTS_UP; i++ {
-		if _, err := os.Stat(dirInRepo.Join(".git").Str()); err == nil {
+		if _, err := filesystem.Stat(dirInRepo.Join(".git").Str()); err == nil {
 			return optional.Of(dirInRepo)
 		}
 		dirInRepo = dirInRepo.Parent()
@@ -0,0 +1,62 @@
+package generationscmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/generations"
+	"github.com/pol-rivero/doot/lib/common/log"
+)
+
+const TIME_FORMAT string = "2006-01-02 15:04:05"
+
+// Prints the generations of the current dotfiles directory, from newest to oldest. In verbose mode, their links are
+// listed too.
+func List() {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+	list := generations.List(cache.ComputeCacheKey(dotfilesDir, config.TargetDir))
+	if len(list) == 0 {
+		log.Printlnf("No generations have been recorded yet. A generation is recorded after every successful install.")
+		return
+	}
+	var sb strings.Builder
+	for i := len(list) - 1; i >= 0; i-- {
+		generation := &list[i]
+		sb.WriteString(fmt.Sprintf("%4d  %s  ", generation.Number, generation.Time.Local().Format(TIME_FORMAT)))
+		if generation.Commit != "" {
+			sb.WriteString(generations.ShortCommit(generation.Commit))
+			if generation.Dirty {
+				sb.WriteString(" (uncommitted changes)")
+			}
+			sb.WriteString("  ")
+		}
+		sb.WriteString(fmt.Sprintf("%d %s", len(generation.Links), pluralize(len(generation.Links), "link", "links")))
+		if len(generation.Generated) > 0 {
+			sb.WriteString(fmt.Sprintf(", %d %s", len(generation.Generated), pluralize(len(generation.Generated), "generated file", "generated files")))
+		}
+		if i == len(list)-1 {
+			sb.WriteString(" (current)")
+		}
+		sb.WriteString("\n")
+		if log.IsVerbose() {
+			for _, link := range generation.Links {
+				sb.WriteString(fmt.Sprintf("        %s -> %s\n", link.Path, link.Source))
+			}
+			for _, generated := range generation.Generated {
+				sb.WriteString(fmt.Sprintf("        %s (generated)\n", generated.Path))
+			}
+		}
+	}
+	log.Printlnf("%s", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
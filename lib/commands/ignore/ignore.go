@@ -0,0 +1,47 @@
+package ignore
+
+import (
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+// Excludes the targets in this machine only. The exclusion is stored in the cache, not in the dotfiles repository.
+func Ignore(targets []string) {
+	setIgnored(targets, true)
+}
+
+func Unignore(targets []string) {
+	setIgnored(targets, false)
+}
+
+func setIgnored(targets []string, ignored bool) {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+
+	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	cache := cache.Load()
+	installedFilesCache := cache.GetEntry(cacheKey)
+
+	changed := false
+	for _, target := range targets {
+		targetPath := RelativeToPWD(target)
+		if installedFilesCache.SetTargetIgnored(targetPath, ignored) {
+			changed = true
+		} else if ignored {
+			log.Printlnf("%s is already ignored", targetPath)
+		} else {
+			log.Printlnf("%s is not ignored", targetPath)
+		}
+	}
+	if !changed {
+		return
+	}
+	cache.Save()
+
+	log.Info("Ignored files have been updated, now running 'install'...")
+	install.Install(false)
+}
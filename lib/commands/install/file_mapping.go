@@ -1,80 +1,204 @@
 package install
 
 import (
+	"cmp"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/journal"
 	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/modules"
+	"github.com/pol-rivero/doot/lib/common/name_mapping"
 	"github.com/pol-rivero/doot/lib/linkmode"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils"
 	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/pol-rivero/doot/lib/utils/optional"
-	"github.com/pol-rivero/doot/lib/utils/set"
 )
 
 type SourcePath struct {
-	path         AbsolutePath
-	hostSpecific bool
+	path AbsolutePath
+	// 0 for regular dotfiles, >0 for host-specific dotfiles (higher values override lower ones)
+	priority int
+	// Index of the layer that contains the dotfile (0 is the lowest layer). Higher layers override lower ones.
+	layer int
+}
+
+// Returns a positive number if a overrides b, negative if b overrides a, and 0 if they conflict
+func comparePrecedence(a, b SourcePath) int {
+	return cmp.Or(cmp.Compare(a.layer, b.layer), cmp.Compare(a.priority, b.priority))
+}
+
+// The dotfiles of a layer, and the settings used to map them to targets
+type LayerFiles struct {
+	Name    string
+	Dir     AbsolutePath
+	Config  *config.Config
+	Modules *modules.Selection
+	Files   []RelativePath
+}
+
+type mappingLayer struct {
+	name           string
+	sourceBaseDir  AbsolutePath
+	nameMapping    name_mapping.NameMapping
+	hostnameFilter HostnameFilter
+	modules        *modules.Selection
 }
 
 type FileMapping struct {
-	mapping           map[AbsolutePath]SourcePath // Installed target (symlink path) -> source (dotfile, symlink content/target)
-	sourceBaseDir     AbsolutePath
-	targetBaseDir     AbsolutePath
-	implicitDot       bool
-	implicitDotIgnore set.Set[string]
-	hostnameFilter    HostnameFilter
-	diffCommand       string
-	targetsSkipped    []AbsolutePath
-	linkMode          linkmode.LinkMode
+	mapping         map[AbsolutePath]SourcePath // Installed target (symlink path) -> source (dotfile, symlink content/target)
+	generated       map[AbsolutePath]generatedFile
+	patches         map[AbsolutePath][]SourcePath // Target -> '.doot-patch' files that should be merged into it
+	generatedHashes map[AbsolutePath]string       // Generated target -> hash of the contents written to it
+	layers          []mappingLayer                // From lowest to highest
+	sourceBaseDir   AbsolutePath                  // Directory of the highest layer
+	targetBaseDir   AbsolutePath
+	diffCommand     string
+	targetsSkipped  []AbsolutePath
+	linksNotRemoved SymlinkCollection // Stale links that could not be removed, they are still installed
+	linkMode        linkmode.LinkMode
 }
 
-func NewFileMapping(dotfilesDir AbsolutePath, config *config.Config, sourceFiles []RelativePath) FileMapping {
+func NewFileMapping(dotfilesDir AbsolutePath, config *config.Config, moduleSelection *modules.Selection, sourceFiles []RelativePath) FileMapping {
+	return NewLayeredFileMapping(config, []LayerFiles{{
+		Dir:     dotfilesDir,
+		Config:  config,
+		Modules: moduleSelection,
+		Files:   sourceFiles,
+	}})
+}
+
+// Maps the files of several layers (from lowest to highest) onto the same target. The target directory, link mode and
+// diff command are taken from the config of the highest layer.
+func NewLayeredFileMapping(config *config.Config, layers []LayerFiles) FileMapping {
 	mapping := FileMapping{
-		mapping:           make(map[AbsolutePath]SourcePath, len(sourceFiles)),
-		sourceBaseDir:     dotfilesDir,
-		targetBaseDir:     NewAbsolutePath(config.TargetDir),
-		implicitDot:       config.ImplicitDot,
-		implicitDotIgnore: set.NewFromSlice(config.ImplicitDotIgnore),
-		hostnameFilter:    getHostnameFilter(config.Hosts),
-		diffCommand:       config.DiffCommand,
-		targetsSkipped:    make([]AbsolutePath, 0),
-		linkMode:          linkmode.GetLinkMode(config),
-	}
-	for _, sourceFile := range sourceFiles {
-		mapping.Add(sourceFile)
+		mapping:         make(map[AbsolutePath]SourcePath),
+		generated:       make(map[AbsolutePath]generatedFile),
+		patches:         make(map[AbsolutePath][]SourcePath),
+		generatedHashes: make(map[AbsolutePath]string),
+		layers:          make([]mappingLayer, 0, len(layers)),
+		sourceBaseDir:   layers[len(layers)-1].Dir,
+		targetBaseDir:   NewAbsolutePath(config.TargetDir),
+		diffCommand:     config.DiffCommand,
+		targetsSkipped:  make([]AbsolutePath, 0),
+		linksNotRemoved: NewSymlinkCollection(0),
+		linkMode:        linkmode.GetLinkMode(config),
 	}
+	for i, layer := range layers {
+		mapping.layers = append(mapping.layers, mappingLayer{
+			name:           layer.Name,
+			sourceBaseDir:  layer.Dir,
+			nameMapping:    name_mapping.NewNameMapping(layer.Config),
+			hostnameFilter: getHostnameFilter(layer.Config),
+			modules:        layer.Modules,
+		})
+		for _, sourceFile := range layer.Files {
+			mapping.add(i, sourceFile)
+		}
+	}
+	mapping.resolveGeneratedConflicts()
+	mapping.resolvePatches()
 	return mapping
 }
 
-func (fm *FileMapping) Add(relativeSource RelativePath) {
-	relativeTarget, newIsHostSpecific := fm.mapSourceToTarget(relativeSource)
+func (fm *FileMapping) add(layerIndex int, relativeSource RelativePath) {
+	layer := &fm.layers[layerIndex]
+	relativeTarget, newPriority := layer.mapSourceToTarget(relativeSource)
 	if relativeTarget.IsEmpty() {
 		return
 	}
-	source := fm.sourceBaseDir.JoinPath(relativeSource)
-	target := fm.targetBaseDir.JoinPath(relativeTarget.Value())
+	newSource := SourcePath{
+		path:     layer.sourceBaseDir.JoinPath(relativeSource),
+		priority: newPriority,
+		layer:    layerIndex,
+	}
+
+	if fragmentTarget, fragmentName, isFragment := splitFragmentPath(relativeTarget.Value()); isFragment {
+		target := fm.targetBaseDir.JoinPath(fragmentTarget)
+		fragments, isFragmentsFile := fm.generated[target].(*fragmentsFile)
+		if !isFragmentsFile {
+			fragments = newFragmentsFile()
+			fm.generated[target] = fragments
+		}
+		fragments.addFragment(fragmentName, newSource, target)
+		return
+	}
 
+	if patchTarget, isPatch := relativeTarget.Value().CutSuffix(common.DOOT_PATCH_EXT); isPatch {
+		target := fm.targetBaseDir.JoinPath(patchTarget)
+		fm.patches[target] = append(fm.patches[target], newSource)
+		return
+	}
+
+	target := fm.targetBaseDir.JoinPath(relativeTarget.Value())
 	oldSource, oldSourceExists := fm.mapping[target]
-	preferNewSource := !oldSourceExists || newIsHostSpecific
-	if preferNewSource {
-		fm.mapping[target] = SourcePath{
-			path:         source,
-			hostSpecific: newIsHostSpecific,
+	if preferNewSource(oldSource, oldSourceExists, newSource, target) {
+		fm.mapping[target] = newSource
+	}
+}
+
+// Removes the targets that have been ignored in this machine with 'doot ignore', or are inside an ignored directory
+func (fm *FileMapping) SkipIgnoredTargets(ignoredTargets []AbsolutePath) {
+	for target := range fm.mapping {
+		if isIgnoredTarget(target, ignoredTargets) {
+			log.Info("Skipping %s because it's ignored in this machine", target)
+			delete(fm.mapping, target)
 		}
-		if oldSourceExists {
-			log.Info("Host-specific file %s overrides %s for target %s", source, oldSource.path, target)
+	}
+	for target := range fm.generated {
+		if isIgnoredTarget(target, ignoredTargets) {
+			log.Info("Skipping %s because it's ignored in this machine", target)
+			delete(fm.generated, target)
+		}
+	}
+}
+
+func isIgnoredTarget(target AbsolutePath, ignoredTargets []AbsolutePath) bool {
+	for _, ignored := range ignoredTargets {
+		if target == ignored || strings.HasPrefix(target.Str(), ignored.Str()+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fm *FileMapping) resolveGeneratedConflicts() {
+	for target, generated := range fm.generated {
+		linkSource, conflict := fm.mapping[target]
+		if !conflict {
+			continue
+		}
+		precedence := comparePrecedence(linkSource, generated.highestSource())
+		if precedence > 0 {
+			log.Info("File %s overrides the fragments of %s", linkSource.path, target)
+			delete(fm.generated, target)
+			continue
+		}
+		if precedence == 0 {
+			log.Warning("Conflicting files: %s and the fragments %s both map to %s. Ignoring %s", linkSource.path, describeSources(generated), target, linkSource.path)
+		}
+		delete(fm.mapping, target)
+	}
+}
+
+// Returns a map of target -> name of the layer of its source. It's empty if there is a single layer.
+func (fm *FileMapping) GetInstalledLayers() map[AbsolutePath]string {
+	layers := make(map[AbsolutePath]string)
+	if len(fm.layers) < 2 {
+		return layers
+	}
+	for targetPath, sourcePath := range fm.mapping {
+		if !slices.Contains(fm.targetsSkipped, targetPath) {
+			layers[targetPath] = fm.layers[sourcePath.layer].name
 		}
-	} else if oldSource.hostSpecific {
-		log.Info("Host-specific file %s overrides %s for target %s", oldSource.path, source, target)
-	} else {
-		// This is rare, but it can happen if 2 files map to the same target after removing '.doot-crypt' or adding the implicit dot
-		log.Warning("Conflicting files: %s and %s both map to %s. Ignoring %s", oldSource.path, source, target, source)
 	}
+	return layers
 }
 
 func (fm *FileMapping) GetInstalledTargets() SymlinkCollection {
@@ -87,6 +211,10 @@ func (fm *FileMapping) GetInstalledTargets() SymlinkCollection {
 	return targets
 }
 
+func (fm *FileMapping) GetLinksNotRemoved() SymlinkCollection {
+	return fm.linksNotRemoved
+}
+
 func (fm *FileMapping) InstallNewLinks() []AbsolutePath {
 	createdLinks := make([]AbsolutePath, 0, 5)
 	for target, sourceStruct := range fm.mapping {
@@ -95,40 +223,50 @@ func (fm *FileMapping) InstallNewLinks() []AbsolutePath {
 			// Already correctly linked, skip early
 			continue
 		}
+		if fm.installLink(target, newSource) {
+			createdLinks = append(createdLinks, target)
+		} else if !slices.Contains(fm.targetsSkipped, target) {
+			// Not linked, so it must not be stored in the cache
+			fm.targetsSkipped = append(fm.targetsSkipped, target)
+		}
+	}
+	return createdLinks
+}
 
-		fileInfo, err := os.Lstat(target.Str())
+func (fm *FileMapping) installLink(target, source AbsolutePath) bool {
+	fileInfo, err := filesystem.Lstat(target.Str())
+	if err == nil {
+		return fm.handleTargetAlreadyExists(fileInfo, target, source)
+	}
+	if os.IsNotExist(err) && files.EnsureParentDir(target) {
+		log.Info("Linking %s -> %s", target, source)
+		change := journal.Prepare(target, fm.targetBaseDir).Linking(source, fm.usesHardlinks())
+		err = fm.linkMode.CreateLink(source, target)
+		change.Done(err == nil)
 		if err == nil {
-			added := fm.handleTargetAlreadyExists(fileInfo, target, newSource)
-			if added {
-				createdLinks = append(createdLinks, target)
-			}
-			continue
+			return true
 		}
-		if os.IsNotExist(err) && files.EnsureParentDir(target) {
-			log.Info("Linking %s -> %s", target, newSource)
-			err = fm.linkMode.CreateLink(newSource, target)
-			if err == nil {
-				createdLinks = append(createdLinks, target)
-				continue
-			}
-		}
-		log.Error("Failed to create link %s -> %s: %s", target, newSource, err)
 	}
-	return createdLinks
+	log.Error("Failed to create link %s -> %s: %s", target, source, err)
+	return false
 }
 
 func (fm *FileMapping) RemoveStaleLinks(previousLinks *SymlinkCollection) []AbsolutePath {
 	removedLinks := make([]AbsolutePath, 0, 5)
-	for previousLinkPath := range previousLinks.Iter() {
+	for previousLinkPath, previousSource := range previousLinks.Iter() {
 		if _, contains := fm.mapping[previousLinkPath]; !contains {
-			if !fm.canBeSafelyRemoved(previousLinkPath) {
+			if !fm.canBeSafelyRemoved(previousLinkPath, previousSource) {
 				log.Info("%s appears to have been modified externally. Skipping removal to avoid data loss.", previousLinkPath)
 				continue
 			}
 			log.Info("Removing link %s", previousLinkPath)
+			change := journal.Prepare(previousLinkPath, fm.targetBaseDir)
 			success := files.RemoveAndCleanup(previousLinkPath, fm.targetBaseDir)
+			change.Done(success)
 			if success {
 				removedLinks = append(removedLinks, previousLinkPath)
+			} else if _, err := filesystem.Lstat(previousLinkPath.Str()); err == nil {
+				fm.linksNotRemoved.Add(previousLinkPath, previousSource)
 			}
 		}
 	}
@@ -149,25 +287,22 @@ func (fm *FileMapping) handleTargetAlreadyExists(targetFileInfo os.FileInfo, tar
 }
 
 func (fm *FileMapping) handleExistingSymlink(target, source AbsolutePath) bool {
-	linkSource, linkErr := os.Readlink(target.Str())
+	linkSource, linkErr := filesystem.Readlink(target.Str())
 	if linkErr != nil {
 		log.Error("Failed to read link %s: %s", target, linkErr)
 		return false
 	}
-	if strings.HasPrefix(linkSource, fm.sourceBaseDir.Str()) {
+	if fm.isInSourceDir(linkSource) {
 		log.Info("Link %s is incorrect (%s) but points to the source directory, replacing silently with %s", target, linkSource, source)
-		err := files.ReplaceWithLink(target, source, fm.linkMode)
-		return err == nil
+		return fm.replaceWithLink(target, source)
 	}
 	if common.IsSymlinkWithTarget(source, linkSource) {
 		log.Info("Link %s is incorrect (%s) but the dotfile %s is also a symlink to same target, replacing silently", target, linkSource, source)
-		err := files.ReplaceWithLink(target, source, fm.linkMode)
-		return err == nil
+		return fm.replaceWithLink(target, source)
 	}
 	replace := utils.RequestInput("yN", "Link %s already exists, but it points to %s instead of %s. Replace it?", target, linkSource, source)
 	if replace == 'y' {
-		err := files.ReplaceWithLink(target, source, fm.linkMode)
-		return err == nil
+		return fm.replaceWithLink(target, source)
 	} else {
 		fm.targetsSkipped = append(fm.targetsSkipped, target)
 		return false
@@ -175,7 +310,7 @@ func (fm *FileMapping) handleExistingSymlink(target, source AbsolutePath) bool {
 }
 
 func (fm *FileMapping) handleExistingFile(target, source AbsolutePath) bool {
-	sourceFileInfo, statErr := os.Lstat(source.Str())
+	sourceFileInfo, statErr := filesystem.Lstat(source.Str())
 	if statErr != nil {
 		log.Error("Failed to lstat source file %s: %s", source, statErr)
 		return false
@@ -184,41 +319,38 @@ func (fm *FileMapping) handleExistingFile(target, source AbsolutePath) bool {
 		return fm.handleReplaceRegularFileWithSymlink(target, source)
 	}
 
-	contents, readErr := os.ReadFile(target.Str())
+	contents, readErr := filesystem.ReadFile(target.Str())
 	if readErr != nil {
 		log.Error("Failed to read target file %s: %s", target, readErr)
 		return false
 	}
-	sourceContents, readErr := os.ReadFile(source.Str())
+	sourceContents, readErr := filesystem.ReadFile(source.Str())
 	if readErr != nil {
 		log.Error("Failed to read source file %s: %s", source, readErr)
 		return false
 	}
 	if string(contents) == string(sourceContents) {
 		log.Info("File %s exists but its contents are identical to %s, replacing silently", target, source)
-		err := files.ReplaceWithLink(target, source, fm.linkMode)
-		return err == nil
+		return fm.replaceWithLink(target, source)
 	}
 	for {
 		replace := utils.RequestInput("yNda", "File %s already exists, but its contents differ from %s. Replace it? (D to see diff, A to adopt changes into dotfiles repo)", target, source)
 		switch replace {
 		case 'y':
-			err := files.ReplaceWithLink(target, source, fm.linkMode)
-			return err == nil
+			return fm.replaceWithLink(target, source)
 		case 'n':
 			fm.targetsSkipped = append(fm.targetsSkipped, target)
 			return false
 		case 'd':
 			fm.printDiff(source, target)
 		case 'a':
-			err := files.AdoptChanges(target, source, fm.linkMode)
-			return err == nil
+			return fm.adoptChanges(target, source)
 		}
 	}
 }
 
 func (fm *FileMapping) handleReplaceRegularFileWithSymlink(target, sourceSymlink AbsolutePath) bool {
-	sourceSymlinkTarget, err := os.Readlink(sourceSymlink.Str())
+	sourceSymlinkTarget, err := filesystem.Readlink(sourceSymlink.Str())
 	if err != nil {
 		log.Error("Failed to read symlink target %s: %s", sourceSymlink, err)
 		return false
@@ -227,42 +359,114 @@ func (fm *FileMapping) handleReplaceRegularFileWithSymlink(target, sourceSymlink
 		replace := utils.RequestInput("yNa", "File %s already exists, but it is a regular file and you are trying to replace it with a symlink to '%s'. Replace it? (A to adopt the regular file into dotfiles repo)", target, sourceSymlinkTarget)
 		switch replace {
 		case 'y':
-			err := files.ReplaceWithLink(target, sourceSymlink, fm.linkMode)
-			return err == nil
+			return fm.replaceWithLink(target, sourceSymlink)
 		case 'n':
 			fm.targetsSkipped = append(fm.targetsSkipped, target)
 			return false
 		case 'a':
-			err := files.AdoptChanges(target, sourceSymlink, fm.linkMode)
-			return err == nil
+			return fm.adoptChanges(target, sourceSymlink)
 		}
 	}
 }
 
-func (fm *FileMapping) mapSourceToTarget(source RelativePath) (optional.Optional[RelativePath], bool) {
+func (fm *FileMapping) replaceWithLink(target, source AbsolutePath) bool {
+	change := journal.Prepare(target, fm.targetBaseDir).Linking(source, fm.usesHardlinks())
+	err := files.ReplaceWithLink(target, source, fm.linkMode)
+	change.Done(err == nil)
+	return err == nil
+}
+
+// Copies the target into the dotfile and replaces it with a link
+func (fm *FileMapping) adoptChanges(target, source AbsolutePath) bool {
+	dotfileChange := journal.Prepare(source, fm.sourceBaseDir)
+	targetChange := journal.Prepare(target, fm.targetBaseDir).Linking(source, fm.usesHardlinks())
+	err := files.AdoptChanges(target, source, fm.linkMode)
+	dotfileChange.Done(err == nil)
+	targetChange.Done(err == nil)
+	return err == nil
+}
+
+func (fm *FileMapping) usesHardlinks() bool {
+	return isHardlinkMode(fm.linkMode)
+}
+
+func (layer *mappingLayer) mapSourceToTarget(source RelativePath) (optional.Optional[RelativePath], int) {
 	target := source
-	if fm.hostnameFilter.isIgnored(source) {
-		return optional.Empty[RelativePath](), false
+	if layer.hostnameFilter.isIgnored(source) {
+		return optional.Empty[RelativePath](), 0
 	}
-	isHostSpecific, prefixLen := fm.hostnameFilter.isHostSpecific(source)
-	if isHostSpecific {
+	priority, prefixLen := layer.hostnameFilter.isHostSpecific(source)
+	if priority > 0 {
 		target = target.RemoveBaseDir(prefixLen)
 	}
-	if fm.implicitDot && !fm.implicitDotIgnore.Contains(source.TopLevelDir()) && !strings.HasPrefix(target.Str(), ".") {
-		target = "." + target
+	// Host-specific files can also belong to a module: "laptop/nvim/init.lua" is part of the "nvim" module
+	if layer.modules.IsInDisabledModule(target) {
+		return optional.Empty[RelativePath](), 0
 	}
-	target = target.Replace(common.DOOT_CRYPT_EXT, "")
-	return optional.WrapString(target), isHostSpecific
+	if modulePrefixLen := layer.modules.EnabledPrefixLen(target); modulePrefixLen > 0 {
+		target = target.RemoveBaseDir(modulePrefixLen)
+	}
+	target = layer.nameMapping.SourceToTarget(target)
+	return optional.WrapString(target), priority
 }
 
-func (fm *FileMapping) canBeSafelyRemoved(linkPath AbsolutePath) bool {
-	expectedDestinationDir := fm.sourceBaseDir.Str()
-	return fm.linkMode.CanBeSafelyRemoved(linkPath, expectedDestinationDir)
+// A link can be removed if it still points to one of the layers, or to the dotfile it was created for
+func (fm *FileMapping) canBeSafelyRemoved(linkPath AbsolutePath, previousSource AbsolutePath) bool {
+	for _, layer := range fm.layers {
+		if fm.linkMode.CanBeSafelyRemoved(linkPath, layer.sourceBaseDir.Str()) {
+			return true
+		}
+	}
+	return fm.linkMode.CanBeSafelyRemoved(linkPath, previousSource.Str())
+}
+
+// Returns true if the path is inside the directory of any of the layers
+func (fm *FileMapping) isInSourceDir(path string) bool {
+	for _, layer := range fm.layers {
+		if strings.HasPrefix(path, layer.sourceBaseDir.Str()) {
+			return true
+		}
+	}
+	return false
 }
 
 func (fm *FileMapping) printDiff(leftFile, rightFile AbsolutePath) {
+	leftFile, ok := realFile(leftFile)
+	if !ok {
+		return
+	}
+	defer removeRealFile(leftFile)
+	rightFile, ok = realFile(rightFile)
+	if !ok {
+		return
+	}
+	defer removeRealFile(rightFile)
+	fm.runDiffCommand(leftFile, rightFile)
+}
+
+func (fm *FileMapping) runDiffCommand(leftFile, rightFile AbsolutePath) {
 	err := utils.RunCommandStr(fm.sourceBaseDir, fm.diffCommand, leftFile.Str(), rightFile.Str())
 	if err != nil {
 		log.Info("Diff command had non-zero exit code: %s. This is usually not a problem.", err)
 	}
 }
+
+// The diff command only sees the real disk. If the changes are not being written to it, the file is copied to a
+// temporary file (that must be removed with removeRealFile).
+func realFile(path AbsolutePath) (AbsolutePath, bool) {
+	if filesystem.IsReal() {
+		return path, true
+	}
+	contents, err := filesystem.ReadFile(path.Str())
+	if err != nil {
+		log.Error("Failed to read %s: %s", path, err)
+		return "", false
+	}
+	return writeRealTempFile(contents)
+}
+
+func removeRealFile(path AbsolutePath) {
+	if !filesystem.IsReal() {
+		os.Remove(path.Str())
+	}
+}
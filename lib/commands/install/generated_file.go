@@ -0,0 +1,143 @@
+package install
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// A target that is not linked to a single dotfile, but generated from several sources
+type generatedFile interface {
+	render() ([]byte, os.FileMode, error)
+	// The source with the highest precedence (see comparePrecedence)
+	highestSource() SourcePath
+	// The files used to generate the target, in the order they are applied
+	sourcePaths() []AbsolutePath
+}
+
+func describeSources(g generatedFile) string {
+	sources := make([]string, 0, 4)
+	for _, source := range g.sourcePaths() {
+		sources = append(sources, source.Str())
+	}
+	return strings.Join(sources, ", ")
+}
+
+// A file assembled by concatenating the contents of a '.doot-fragments' directory
+type fragmentsFile struct {
+	// fragment name -> source of the fragment
+	fragments map[string]SourcePath
+}
+
+func newFragmentsFile() *fragmentsFile {
+	return &fragmentsFile{
+		fragments: make(map[string]SourcePath),
+	}
+}
+
+func (g *fragmentsFile) addFragment(fragmentName string, source SourcePath, target AbsolutePath) {
+	oldSource, oldSourceExists := g.fragments[fragmentName]
+	if preferNewSource(oldSource, oldSourceExists, source, target) {
+		g.fragments[fragmentName] = source
+	}
+}
+
+func (g *fragmentsFile) highestSource() SourcePath {
+	var highest SourcePath
+	for _, source := range g.fragments {
+		if highest.path == "" || comparePrecedence(source, highest) > 0 {
+			highest = source
+		}
+	}
+	return highest
+}
+
+func (g *fragmentsFile) sortedFragmentNames() []string {
+	names := make([]string, 0, len(g.fragments))
+	for name := range g.fragments {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Concatenates the fragments in lexical order. The file mode is taken from the first fragment.
+func (g *fragmentsFile) render() ([]byte, os.FileMode, error) {
+	var result bytes.Buffer
+	perm := os.FileMode(0644)
+	for i, name := range g.sortedFragmentNames() {
+		source := g.fragments[name].path
+		contents, err := filesystem.ReadFile(source.Str())
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == 0 {
+			if info, err := filesystem.Stat(source.Str()); err == nil {
+				perm = info.Mode().Perm()
+			}
+		}
+		result.Write(contents)
+		if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+			result.WriteByte('\n')
+		}
+	}
+	return result.Bytes(), perm, nil
+}
+
+func (g *fragmentsFile) sourcePaths() []AbsolutePath {
+	sources := make([]AbsolutePath, 0, len(g.fragments))
+	for _, name := range g.sortedFragmentNames() {
+		sources = append(sources, g.fragments[name].path)
+	}
+	return sources
+}
+
+// If a directory in the path is named '<name>.doot-fragments', the file is a fragment of the target '<name>'.
+// For example, 'bashrc.doot-fragments/10-aliases.sh' is the fragment '10-aliases.sh' of 'bashrc'.
+func splitFragmentPath(path RelativePath) (RelativePath, string, bool) {
+	components := strings.Split(path.Str(), string(filepath.Separator))
+	for i, component := range components[:len(components)-1] {
+		targetName, isFragmentsDir := strings.CutSuffix(component, common.DOOT_FRAGMENTS_EXT)
+		if !isFragmentsDir || targetName == "" {
+			continue
+		}
+		targetComponents := append(slices.Clone(components[:i]), targetName)
+		target := RelativePath(filepath.Join(targetComponents...))
+		fragmentName := filepath.Join(components[i+1:]...)
+		return target, fragmentName, true
+	}
+	return "", "", false
+}
+
+// Returns true if newSource should replace oldSource as the source of the given target
+func preferNewSource(oldSource SourcePath, oldSourceExists bool, newSource SourcePath, target AbsolutePath) bool {
+	if !oldSourceExists {
+		return true
+	}
+	precedence := comparePrecedence(newSource, oldSource)
+	if precedence > 0 {
+		log.Info("%s overrides %s for target %s", describeOverride(newSource, oldSource), oldSource.path, target)
+		return true
+	}
+	if precedence < 0 {
+		log.Info("%s overrides %s for target %s", describeOverride(oldSource, newSource), newSource.path, target)
+	} else {
+		// This is rare, but it can happen if 2 files map to the same target after removing '.doot-crypt' or adding the implicit dot
+		log.Warning("Conflicting files: %s and %s both map to %s. Ignoring %s", oldSource.path, newSource.path, target, newSource.path)
+	}
+	return false
+}
+
+func describeOverride(winner, loser SourcePath) string {
+	if winner.layer > loser.layer {
+		return "File " + winner.path.Str() + " from a higher layer"
+	}
+	return "Host-specific file " + winner.path.Str()
+}
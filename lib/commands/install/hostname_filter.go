@@ -1,67 +1,68 @@
 package install
 
 import (
-	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/hosts"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
-	"github.com/pol-rivero/doot/lib/utils/optional"
 )
 
+type hostSpecificDir struct {
+	prefix   string
+	priority int
+}
+
 type HostnameFilter struct {
-	hostSpecificDirPrefix optional.Optional[string]
-	ignorePrefixes        []string
+	hostSpecificDirs []hostSpecificDir
+	ignorePrefixes   []string
 }
 
-func getHostnameFilter(hosts map[string]string) HostnameFilter {
-	hostname := getHostname()
+func getHostnameFilter(config *config.Config) HostnameFilter {
+	activeDirs, ignoredDirs := hosts.ResolveHostDirs(config, common.GetHostname())
 	result := HostnameFilter{
-		hostSpecificDirPrefix: optional.Empty[string](),
-		ignorePrefixes:        make([]string, 0, len(hosts)+1),
+		hostSpecificDirs: make([]hostSpecificDir, 0, len(activeDirs)),
+		ignorePrefixes:   make([]string, 0, len(ignoredDirs)+1),
 	}
 
 	// The doot directory should never be symlinked
 	result.ignorePrefixes = append(result.ignorePrefixes, "doot"+string(filepath.Separator))
 
-	for host, dir := range hosts {
-		dirPrefix := dir + string(filepath.Separator)
-		if host == hostname {
-			log.Info("Using host-specific directory: %s", dir)
-			result.hostSpecificDirPrefix = optional.Of(dirPrefix)
-		} else {
-			result.ignorePrefixes = append(result.ignorePrefixes, dirPrefix)
-		}
+	for _, hostDir := range activeDirs {
+		log.Info("Using host-specific directory: %s (priority %d)", hostDir.Dir, hostDir.Priority)
+		result.hostSpecificDirs = append(result.hostSpecificDirs, hostSpecificDir{
+			prefix:   hostDir.Dir + string(filepath.Separator),
+			priority: hostDir.Priority,
+		})
+	}
+	for _, dir := range ignoredDirs {
+		result.ignorePrefixes = append(result.ignorePrefixes, dir+string(filepath.Separator))
 	}
 	return result
 }
 
-func (hf HostnameFilter) isHostSpecific(path RelativePath) (isHostSpecific bool, prefixLen int) {
-	if hf.hostSpecificDirPrefix.IsEmpty() {
-		return false, 0
-	}
-	prefix := hf.hostSpecificDirPrefix.Value()
-	if !strings.HasPrefix(path.Str(), prefix) {
-		return false, 0
+// Returns the priority of the host-specific directory that contains the path (0 if it's not host-specific)
+// and the length of its prefix. If the host-specific directories are nested, the innermost one is used.
+func (hf HostnameFilter) isHostSpecific(path RelativePath) (priority int, prefixLen int) {
+	for _, hostDir := range hf.hostSpecificDirs {
+		if strings.HasPrefix(path.Str(), hostDir.prefix) && len(hostDir.prefix) > prefixLen {
+			priority = hostDir.priority
+			prefixLen = len(hostDir.prefix)
+		}
 	}
-	return true, len(prefix)
+	return priority, prefixLen
 }
 
 func (hf HostnameFilter) isIgnored(path RelativePath) bool {
+	_, hostPrefixLen := hf.isHostSpecific(path)
 	for _, prefix := range hf.ignorePrefixes {
-		if strings.HasPrefix(path.Str(), prefix) {
+		// A host-specific directory nested inside the directory of another host is still used
+		if strings.HasPrefix(path.Str(), prefix) && len(prefix) >= hostPrefixLen {
 			return true
 		}
 	}
 	return false
 }
-
-func getHostname() string {
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Error("Failed to get hostname: %v", err)
-		return ""
-	}
-	return hostname
-}
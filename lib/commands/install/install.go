@@ -2,64 +2,235 @@ package install
 
 import (
 	"github.com/pol-rivero/doot/lib/commands/crypt"
+	"github.com/pol-rivero/doot/lib/commands/relocate"
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/cache"
 	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/generations"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/layers"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/modules"
 	"github.com/pol-rivero/doot/lib/linkmode"
+	hardlink "github.com/pol-rivero/doot/lib/linkmode/hardlink"
 	. "github.com/pol-rivero/doot/lib/types"
 )
 
-type GetFilesFunc func(*config.Config, AbsolutePath) []RelativePath
+type GetFilesFunc func(*config.Config, AbsolutePath, *modules.Selection) []RelativePath
 
-func Install(fullClean bool) {
-	regularInstall(fullClean, nil)
+// The targets that were linked or generated, and the ones that were removed
+type Changes struct {
+	Added   []AbsolutePath
+	Removed []AbsolutePath
 }
 
-func InstallAfterAdd(fullClean bool, extraAddedFiles []AbsolutePath) {
-	regularInstall(fullClean, extraAddedFiles)
+func Install(fullClean bool) Changes {
+	journal.Begin("install", nil)
+	defer journal.End()
+	return regularInstall(fullClean, nil, nil)
 }
 
-func regularInstall(fullClean bool, extraAddedFiles []AbsolutePath) {
-	getFiles := func(config *config.Config, dotfilesDir AbsolutePath) []RelativePath {
+// Only links the entries whose target or dotfile matches one of the given paths or globs. The rest are left untouched.
+func InstallPaths(fullClean bool, paths []string) Changes {
+	journal.Begin("install", paths)
+	defer journal.End()
+	selector := NewPathSelector(paths)
+	return regularInstall(fullClean, nil, &selector)
+}
+
+func InstallAfterAdd(fullClean bool, extraAddedFiles []AbsolutePath) Changes {
+	journal.Begin("install", nil)
+	defer journal.End()
+	return regularInstall(fullClean, extraAddedFiles, nil)
+}
+
+func regularInstall(fullClean bool, extraAddedFiles []AbsolutePath, selector *PathSelector) Changes {
+	getFiles := func(config *config.Config, dotfilesDir AbsolutePath, moduleSelection *modules.Selection) []RelativePath {
 		ignoreDootCrypt := !crypt.GitCryptIsInitialized(dotfilesDir)
 		filter := CreateFilter(config, ignoreDootCrypt)
+		filter.Modules = *moduleSelection
+		if config.GitTrackedOnly {
+			files, isGitRepo := ScanGitFiles(dotfilesDir, &filter, config.GitIncludeUntracked)
+			if isGitRepo {
+				return files
+			}
+			log.Warning("git_tracked_only is enabled, but %s is not a git repository. Falling back to scanning the whole directory.", dotfilesDir)
+		}
 		return ScanDirectory(dotfilesDir, &filter)
 	}
-	added, removed := install(getFiles, fullClean)
+	added, removed := install(getFiles, fullClean, selector)
 	printChanges(added, removed, extraAddedFiles)
+	return Changes{Added: mergeSlices(added, extraAddedFiles), Removed: removed}
 }
 
-func Clean(fullClean bool) {
-	getFiles := func(config *config.Config, dotfilesDir AbsolutePath) []RelativePath {
+func Clean(fullClean bool) Changes {
+	journal.Begin("clean", nil)
+	defer journal.End()
+	return regularClean(fullClean, nil)
+}
+
+// Only removes the links whose target or dotfile matches one of the given paths or globs. The rest are left untouched.
+func CleanPaths(fullClean bool, paths []string) Changes {
+	journal.Begin("clean", paths)
+	defer journal.End()
+	selector := NewPathSelector(paths)
+	return regularClean(fullClean, &selector)
+}
+
+func regularClean(fullClean bool, selector *PathSelector) Changes {
+	getFiles := func(config *config.Config, dotfilesDir AbsolutePath, moduleSelection *modules.Selection) []RelativePath {
 		return []RelativePath{}
 	}
-	added, removed := install(getFiles, fullClean)
+	added, removed := install(getFiles, fullClean, selector)
 	printChanges(added, removed, nil)
+	return Changes{Added: added, Removed: removed}
 }
 
-func install(getFiles GetFilesFunc, fullClean bool) ([]AbsolutePath, []AbsolutePath) {
+func install(getFiles GetFilesFunc, fullClean bool, selector *PathSelector) ([]AbsolutePath, []AbsolutePath) {
 	dotfilesDir := common.FindDotfilesDir()
 	config := config.FromDotfilesDir(dotfilesDir)
 	linkMode := linkmode.GetLinkMode(&config)
+	layerList := layers.Resolve(dotfilesDir, &config)
 
 	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	journal.SetCacheKey(cacheKey)
 	cache := cache.Load()
+	if relocate.DetectMovedDotfilesDir(&cache, dotfilesDir, config.TargetDir) {
+		// The relocated links are not part of this operation, they must survive a rollback
+		journal.Atomically(cache.Save)
+		journal.RefreshCacheSnapshot()
+	}
 	installedFilesCache := cache.GetEntry(cacheKey)
 	if fullClean {
-		installedFilesCache.Links = linkMode.RecalculateCache(dotfilesDir, config.TargetDir)
+		installedFilesCache.Links = RecalculateCache(linkMode, layerList, config.TargetDir)
 	}
 
-	common.RunHooks(dotfilesDir, "before-update")
-	fileList := getFiles(&config, dotfilesDir)
-	fileMapping := NewFileMapping(dotfilesDir, &config, fileList)
+	runHooks(layerList, "before-update")
+	plan := planInstall(&config, layerList, installedFilesCache, getFiles, selector)
+	added, removed := plan.apply()
+	plan.updateCache(installedFilesCache)
+	journal.Atomically(func() {
+		cache.Save()
+		generations.Record(cacheKey, dotfilesDir, installedFilesCache, isHardlinkMode(linkMode))
+	})
 
-	oldLinks := installedFilesCache.GetLinks()
-	removed := fileMapping.RemoveStaleLinks(&oldLinks)
-	added := fileMapping.InstallNewLinks()
+	runHooks(layerList, "after-update")
+	return added, removed
+}
+
+// The links and generated files that install should have after running, computed without modifying anything
+type installPlan struct {
+	fileMapping        FileMapping
+	oldLinks           SymlinkCollection
+	oldGeneratedFiles  map[AbsolutePath]string
+	keptLinks          SymlinkCollection
+	keptGeneratedFiles map[AbsolutePath]string
+}
+
+func planInstall(config *config.Config, layerList []layers.Layer, installedFilesCache *cache.InstalledFilesCache, getFiles GetFilesFunc, selector *PathSelector) installPlan {
+	enabledModules, disabledModules := installedFilesCache.GetModuleOverrides()
+	moduleOverrides := modules.LocalOverrides{
+		Enabled:  enabledModules,
+		Disabled: disabledModules,
+	}
+
+	layerFiles := make([]LayerFiles, 0, len(layerList))
+	moduleSelections := make([]modules.Selection, 0, len(layerList))
+	for _, layer := range layerList {
+		moduleSelection := modules.Resolve(layer.Config, layer.Dir, common.GetHostname(), moduleOverrides)
+		moduleSelections = append(moduleSelections, moduleSelection)
+		layerFiles = append(layerFiles, LayerFiles{
+			Name:    layer.Name,
+			Dir:     layer.Dir,
+			Config:  layer.Config,
+			Modules: &moduleSelection,
+			Files:   getFiles(layer.Config, layer.Dir, &moduleSelection),
+		})
+	}
+	modules.WarnUnknownOverrides(moduleOverrides, moduleSelections...)
+	fileMapping := NewLayeredFileMapping(config, layerFiles)
+	fileMapping.SkipIgnoredTargets(installedFilesCache.GetIgnoredTargets())
 
-	installedFilesCache.SetLinks(fileMapping.GetInstalledTargets())
-	cache.Save()
+	plan := installPlan{
+		fileMapping:        fileMapping,
+		oldLinks:           installedFilesCache.GetLinks(),
+		oldGeneratedFiles:  installedFilesCache.GetGeneratedFiles(),
+		keptLinks:          NewSymlinkCollection(0),
+		keptGeneratedFiles: map[AbsolutePath]string{},
+	}
+	if selector != nil {
+		plan.keptLinks, plan.keptGeneratedFiles = plan.fileMapping.restrictTo(selector, &plan.oldLinks, plan.oldGeneratedFiles, installedFilesCache.GetGeneratedSources())
+	}
+	return plan
+}
 
-	common.RunHooks(dotfilesDir, "after-update")
+// Returns the added and removed targets
+func (plan *installPlan) apply() ([]AbsolutePath, []AbsolutePath) {
+	fileMapping := &plan.fileMapping
+	removed := fileMapping.RemoveStaleLinks(&plan.oldLinks)
+	removed = append(removed, fileMapping.RemoveStaleGeneratedFiles(plan.oldGeneratedFiles)...)
+	added := fileMapping.InstallNewLinks()
+	added = append(added, fileMapping.InstallGeneratedFiles(plan.oldGeneratedFiles)...)
 	return added, removed
 }
+
+// Stores the links and generated files that are on disk after applying the plan
+func (plan *installPlan) updateCache(installedFilesCache *cache.InstalledFilesCache) {
+	fileMapping := &plan.fileMapping
+	previousLayers := installedFilesCache.GetLinkLayers()
+	previousSources := installedFilesCache.GetGeneratedSources()
+	installedLinks := fileMapping.GetInstalledTargets()
+	installedLayers := fileMapping.GetInstalledLayers()
+	notRemoved := fileMapping.GetLinksNotRemoved()
+	for _, kept := range []*SymlinkCollection{&plan.keptLinks, &notRemoved} {
+		for target, source := range kept.Iter() {
+			if installedLinks.Get(target).IsEmpty() {
+				installedLinks.Add(target, source)
+				if layer, hasLayer := previousLayers[target]; hasLayer {
+					installedLayers[target] = layer
+				}
+			}
+		}
+	}
+	generatedFiles := fileMapping.GetGeneratedTargets()
+	generatedSources := fileMapping.GetGeneratedSources()
+	for target, hash := range plan.keptGeneratedFiles {
+		if _, exists := generatedFiles[target]; !exists {
+			generatedFiles[target] = hash
+			if sources, hasSources := previousSources[target]; hasSources {
+				generatedSources[target] = sources
+			}
+		}
+	}
+	installedFilesCache.SetLinks(installedLinks)
+	installedFilesCache.SetLinkLayers(installedLayers)
+	installedFilesCache.SetGeneratedFiles(generatedFiles)
+	installedFilesCache.SetGeneratedSources(generatedSources)
+}
+
+// Looks for links to any of the layers in the target directory
+func RecalculateCache(linkMode linkmode.LinkMode, layerList []layers.Layer, targetDir string) []*cache.InstalledFile {
+	result := make([]*cache.InstalledFile, 0)
+	for _, layer := range layerList {
+		links := linkMode.RecalculateCache(layer.Dir, targetDir)
+		if len(layerList) > 1 {
+			for _, link := range links {
+				link.Layer = layer.Name
+			}
+		}
+		result = append(result, links...)
+	}
+	return result
+}
+
+func isHardlinkMode(linkMode linkmode.LinkMode) bool {
+	_, isHardlink := linkMode.(*hardlink.HardlinkLinkMode)
+	return isHardlink
+}
+
+// Runs the hooks of every layer, starting from the lowest one
+func runHooks(layerList []layers.Layer, hookName string) {
+	for _, layer := range layerList {
+		common.RunHooks(layer.Dir, hookName)
+	}
+}
@@ -0,0 +1,221 @@
+package install
+
+import (
+	"os"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+func (fm *FileMapping) GetGeneratedTargets() map[AbsolutePath]string {
+	return fm.generatedHashes
+}
+
+// Returns the dotfiles that each generated target was generated from
+func (fm *FileMapping) GetGeneratedSources() map[AbsolutePath][]AbsolutePath {
+	sources := make(map[AbsolutePath][]AbsolutePath, len(fm.generatedHashes))
+	for target := range fm.generatedHashes {
+		if generated, exists := fm.generated[target]; exists {
+			sources[target] = generated.sourcePaths()
+		}
+	}
+	return sources
+}
+
+// Writes the generated targets. previousHashes contains the hashes recorded in the previous installation, used to
+// detect whether the user has modified a generated file.
+func (fm *FileMapping) InstallGeneratedFiles(previousHashes map[AbsolutePath]string) []AbsolutePath {
+	writtenFiles := make([]AbsolutePath, 0, 5)
+	for target, generated := range fm.generated {
+		previousHash, wasGenerated := previousHashes[target]
+		if fm.installGeneratedFile(target, generated, previousHash, wasGenerated) {
+			writtenFiles = append(writtenFiles, target)
+		}
+		if _, installed := fm.generatedHashes[target]; !installed && wasGenerated {
+			// The write was skipped or failed, the file is still the one generated by the previous installation
+			fm.generatedHashes[target] = previousHash
+		}
+	}
+	return writtenFiles
+}
+
+// Returns whether the target was written
+func (fm *FileMapping) installGeneratedFile(target AbsolutePath, generated generatedFile, previousHash string, wasGenerated bool) bool {
+	contents, perm, err := generated.render()
+	if err != nil {
+		log.Error("Failed to generate %s: %s", target, err)
+		return false
+	}
+	newHash := files.HashContents(contents)
+	if wasGenerated && previousHash == newHash && fm.hashMatches(target, newHash) {
+		// Already up to date, skip early
+		fm.generatedHashes[target] = newHash
+		return false
+	}
+
+	fileInfo, err := filesystem.Lstat(target.Str())
+	if err == nil {
+		written := fm.handleGeneratedTargetExists(fileInfo, target, contents, perm, previousHash, wasGenerated)
+		if written {
+			fm.generatedHashes[target] = newHash
+		}
+		return written
+	}
+	if os.IsNotExist(err) && files.EnsureParentDir(target) {
+		log.Info("Generating %s from %s", target, describeSources(generated))
+		change := journal.Prepare(target, fm.targetBaseDir)
+		err = files.WriteFileAtomic(target, contents, perm)
+		change.Done(err == nil)
+		if err == nil {
+			fm.generatedHashes[target] = newHash
+			return true
+		}
+	}
+	log.Error("Failed to generate %s: %s", target, err)
+	return false
+}
+
+func (fm *FileMapping) RemoveStaleGeneratedFiles(previousHashes map[AbsolutePath]string) []AbsolutePath {
+	removedFiles := make([]AbsolutePath, 0, 5)
+	for previousTarget, previousHash := range previousHashes {
+		if _, contains := fm.generated[previousTarget]; contains {
+			continue
+		}
+		if _, err := filesystem.Lstat(previousTarget.Str()); os.IsNotExist(err) {
+			log.Info("Generated file %s does not exist, it may have been removed manually", previousTarget)
+			continue
+		}
+		if !fm.hashMatches(previousTarget, previousHash) {
+			log.Info("%s appears to have been modified externally. Skipping removal to avoid data loss.", previousTarget)
+			continue
+		}
+		log.Info("Removing generated file %s", previousTarget)
+		change := journal.Prepare(previousTarget, fm.targetBaseDir)
+		success := files.RemoveAndCleanup(previousTarget, fm.targetBaseDir)
+		change.Done(success)
+		if success {
+			removedFiles = append(removedFiles, previousTarget)
+		}
+	}
+	return removedFiles
+}
+
+func (fm *FileMapping) hashMatches(target AbsolutePath, expectedHash string) bool {
+	fileInfo, err := filesystem.Lstat(target.Str())
+	if err != nil || !fileInfo.Mode().IsRegular() {
+		return false
+	}
+	currentHash, err := files.HashFile(target)
+	return err == nil && currentHash == expectedHash
+}
+
+func (fm *FileMapping) handleGeneratedTargetExists(targetFileInfo os.FileInfo, target AbsolutePath, contents []byte, perm os.FileMode, previousHash string, wasGenerated bool) bool {
+	if common.IsSymlink(targetFileInfo) {
+		return fm.handleSymlinkToGenerated(target, contents, perm)
+	} else if targetFileInfo.Mode().IsRegular() {
+		return fm.handleRegularFileToGenerated(target, contents, perm, previousHash, wasGenerated)
+	} else if targetFileInfo.Mode().IsDir() {
+		log.Warning("Skipping %s because it already exists but it's a directory. If you want to replace it, first check its contents and delete it manually.", target)
+	} else {
+		log.Warning("Skipping %s because it already exists. If you want to replace it, first check its contents and delete it manually.", target)
+	}
+	return false
+}
+
+func (fm *FileMapping) handleSymlinkToGenerated(target AbsolutePath, contents []byte, perm os.FileMode) bool {
+	linkSource, linkErr := filesystem.Readlink(target.Str())
+	if linkErr != nil {
+		log.Error("Failed to read link %s: %s", target, linkErr)
+		return false
+	}
+	if fm.isInSourceDir(linkSource) {
+		log.Info("Link %s points to the source directory (%s), replacing silently with a generated file", target, linkSource)
+		return fm.writeGeneratedFile(target, contents, perm)
+	}
+	replace := utils.RequestInput("yN", "%s is a symlink to %s, but it should be a file generated from fragments. Replace it?", target, linkSource)
+	if replace == 'y' {
+		return fm.writeGeneratedFile(target, contents, perm)
+	}
+	return false
+}
+
+func (fm *FileMapping) handleRegularFileToGenerated(target AbsolutePath, contents []byte, perm os.FileMode, previousHash string, wasGenerated bool) bool {
+	currentHash, err := files.HashFile(target)
+	if err != nil {
+		log.Error("Failed to read target file %s: %s", target, err)
+		return false
+	}
+	if currentHash == files.HashContents(contents) {
+		log.Info("File %s exists and its contents are already up to date", target)
+		return true
+	}
+	if wasGenerated && currentHash == previousHash {
+		log.Info("Regenerating %s", target)
+		return fm.writeGeneratedFile(target, contents, perm)
+	}
+	var message string
+	if wasGenerated {
+		message = "File %s has been modified since it was generated. Overwrite it? (D to see diff)"
+	} else {
+		message = "File %s already exists, but its contents differ from the generated file. Replace it? (D to see diff)"
+	}
+	for {
+		replace := utils.RequestInput("yNd", message, target)
+		switch replace {
+		case 'y':
+			return fm.writeGeneratedFile(target, contents, perm)
+		case 'n':
+			return false
+		case 'd':
+			fm.printGeneratedDiff(target, contents)
+		}
+	}
+}
+
+func (fm *FileMapping) writeGeneratedFile(target AbsolutePath, contents []byte, perm os.FileMode) bool {
+	change := journal.Prepare(target, fm.targetBaseDir)
+	err := files.WriteFileAtomic(target, contents, perm)
+	change.Done(err == nil)
+	if err != nil {
+		log.Error("Failed to write %s: %s", target, err)
+		return false
+	}
+	return true
+}
+
+func (fm *FileMapping) printGeneratedDiff(target AbsolutePath, contents []byte) {
+	generatedFile, ok := writeRealTempFile(contents)
+	if !ok {
+		return
+	}
+	defer os.Remove(generatedFile.Str())
+	target, ok = realFile(target)
+	if !ok {
+		return
+	}
+	defer removeRealFile(target)
+	fm.runDiffCommand(generatedFile, target)
+}
+
+// The diff command only sees the real disk, so the temporary file is written (and must be removed) with os instead of
+// the filesystem package, even if the changes are not being written to disk
+func writeRealTempFile(contents []byte) (AbsolutePath, bool) {
+	tempFile, err := os.CreateTemp("", "doot-diff-*")
+	if err != nil {
+		log.Error("Failed to create temporary file: %s", err)
+		return "", false
+	}
+	_, err = tempFile.Write(contents)
+	tempFile.Close()
+	if err != nil {
+		log.Error("Failed to write temporary file %s: %s", tempFile.Name(), err)
+		os.Remove(tempFile.Name())
+		return "", false
+	}
+	return NewAbsolutePath(tempFile.Name()), true
+}
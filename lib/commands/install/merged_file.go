@@ -0,0 +1,116 @@
+package install
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/pol-rivero/doot/lib/utils/merge"
+)
+
+// A structured file (JSON, TOML or YAML) generated by deep-merging '.doot-patch' files into a base file
+type mergedFile struct {
+	format  merge.Format
+	base    SourcePath
+	patches []SourcePath // Sorted by precedence, the last patch is applied last
+}
+
+func (m *mergedFile) render() ([]byte, os.FileMode, error) {
+	baseInfo, err := filesystem.Stat(m.base.path.Str())
+	if err != nil {
+		return nil, 0, err
+	}
+	document, err := m.parseFile(m.base.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, patch := range m.patches {
+		patchDocument, err := m.parseFile(patch.path)
+		if err != nil {
+			return nil, 0, err
+		}
+		document = merge.MergePatch(document, patchDocument).(map[string]any)
+	}
+	contents, err := merge.Serialize(m.format, document)
+	if err != nil {
+		return nil, 0, err
+	}
+	return contents, baseInfo.Mode().Perm(), nil
+}
+
+func (m *mergedFile) parseFile(path AbsolutePath) (map[string]any, error) {
+	contents, err := filesystem.ReadFile(path.Str())
+	if err != nil {
+		return nil, err
+	}
+	document, err := merge.Parse(m.format, contents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return document, nil
+}
+
+func (m *mergedFile) highestSource() SourcePath {
+	highest := m.base
+	for _, patch := range m.patches {
+		if comparePrecedence(patch, highest) > 0 {
+			highest = patch
+		}
+	}
+	return highest
+}
+
+func (m *mergedFile) sourcePaths() []AbsolutePath {
+	sources := []AbsolutePath{m.base.path}
+	for _, patch := range m.patches {
+		sources = append(sources, patch.path)
+	}
+	return sources
+}
+
+// Turns the targets that have '.doot-patch' files into merged files. Patches are only applied on top of a base file
+// with the same or lower precedence; a host-specific file (or a file from a higher layer) that completely replaces the
+// base also discards lower patches.
+func (fm *FileMapping) resolvePatches() {
+	for target, patches := range fm.patches {
+		base, hasBase := fm.mapping[target]
+		if !hasBase {
+			if _, isGenerated := fm.generated[target]; isGenerated {
+				log.Warning("Cannot apply %s to %s because it is generated from fragments. Ignoring the patch.", patches[0].path, target)
+			} else {
+				log.Warning("Patch %s has no base file for target %s. Ignoring the patch.", patches[0].path, target)
+			}
+			continue
+		}
+		format, supported := merge.FormatFromPath(target.Str())
+		if !supported {
+			log.Warning("Cannot apply %s because %s is not a JSON, TOML or YAML file. Ignoring the patch.", patches[0].path, target)
+			continue
+		}
+		applicablePatches := make([]SourcePath, 0, len(patches))
+		for _, patch := range patches {
+			if comparePrecedence(patch, base) < 0 {
+				log.Info("%s overrides the patch %s for target %s", describeOverride(base, patch), patch.path, target)
+				continue
+			}
+			applicablePatches = append(applicablePatches, patch)
+		}
+		if len(applicablePatches) == 0 {
+			continue
+		}
+		slices.SortFunc(applicablePatches, func(a, b SourcePath) int {
+			return cmp.Or(comparePrecedence(a, b), strings.Compare(a.path.Str(), b.path.Str()))
+		})
+		fm.generated[target] = &mergedFile{
+			format:  format,
+			base:    base,
+			patches: applicablePatches,
+		}
+		delete(fm.mapping, target)
+	}
+}
@@ -0,0 +1,97 @@
+package install
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+// Restricts 'install' and 'clean' to the entries whose target or dotfile matches one of the paths given in the command line.
+// A path matches the entry if it's the file itself or one of its parent directories. Paths can also be globs.
+type PathSelector struct {
+	paths []string
+	globs []glob.Glob
+}
+
+func NewPathSelector(args []string) PathSelector {
+	selector := PathSelector{
+		paths: make([]string, 0, len(args)),
+		globs: make([]glob.Glob, 0),
+	}
+	for _, arg := range args {
+		absolutePath := RelativeToPWD(arg).Str()
+		if !strings.ContainsAny(arg, "*?[{") {
+			selector.paths = append(selector.paths, absolutePath)
+			continue
+		}
+		g, err := glob.Compile(absolutePath, filepath.Separator)
+		if err != nil {
+			log.Fatal("Invalid glob '%s': %v", arg, err)
+		}
+		selector.globs = append(selector.globs, g)
+	}
+	return selector
+}
+
+func (ps *PathSelector) matchesAny(paths ...AbsolutePath) bool {
+	for _, path := range paths {
+		if ps.matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps *PathSelector) matches(path AbsolutePath) bool {
+	for _, selected := range ps.paths {
+		if path.Str() == selected || strings.HasPrefix(path.Str(), selected+string(filepath.Separator)) {
+			return true
+		}
+	}
+	for current := path; ; current = current.Parent() {
+		for _, g := range ps.globs {
+			if g.Match(current.Str()) {
+				return true
+			}
+		}
+		if current.Parent() == current {
+			return false
+		}
+	}
+}
+
+// Removes the entries that don't match the selector from the mapping and from the previous links and generated files,
+// so that they are neither installed nor removed. Previous generated files also match by the sources they were generated
+// from. Returns the unmatched previous entries, which must be kept in the cache.
+func (fm *FileMapping) restrictTo(selector *PathSelector, previousLinks *SymlinkCollection, previousGenerated map[AbsolutePath]string, previousSources map[AbsolutePath][]AbsolutePath) (SymlinkCollection, map[AbsolutePath]string) {
+	for target, source := range fm.mapping {
+		if !selector.matchesAny(target, source.path) {
+			delete(fm.mapping, target)
+		}
+	}
+	for target, generated := range fm.generated {
+		if !selector.matchesAny(append(generated.sourcePaths(), target)...) {
+			delete(fm.generated, target)
+		}
+	}
+
+	keptLinks := NewSymlinkCollection(previousLinks.Len())
+	for target, source := range previousLinks.Iter() {
+		if !selector.matchesAny(target, source) {
+			keptLinks.Add(target, source)
+			previousLinks.Remove(target)
+		}
+	}
+	keptGenerated := make(map[AbsolutePath]string)
+	for target, hash := range previousGenerated {
+		_, stillGenerated := fm.generated[target]
+		if !selector.matchesAny(append(previousSources[target], target)...) && !stillGenerated {
+			keptGenerated[target] = hash
+			delete(previousGenerated, target)
+		}
+	}
+	return keptLinks, keptGenerated
+}
@@ -0,0 +1,172 @@
+package install
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/generations"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/layers"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/linkmode"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Restores the links and generated files of a previous generation. If number is 0, the generation before the current
+// one is restored.
+func Rollback(number int) Changes {
+	args := []string{}
+	if number != 0 {
+		args = append(args, strconv.Itoa(number))
+	}
+	journal.Begin("rollback", args)
+	defer journal.End()
+
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+	linkMode := linkmode.GetLinkMode(&config)
+	layerList := layers.Resolve(dotfilesDir, &config)
+
+	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	journal.SetCacheKey(cacheKey)
+	generation := findGeneration(generations.List(cacheKey), number)
+	if commit, _ := generations.CurrentCommit(dotfilesDir); generation.Commit != "" && commit != generation.Commit {
+		log.Warning("Generation %d was installed from commit %s, but %s is now at %s. The links will point to the current version of the dotfiles; run 'git checkout %s' to restore their contents too.", generation.Number, generations.ShortCommit(generation.Commit), dotfilesDir, generations.ShortCommit(commit), generations.ShortCommit(generation.Commit))
+	}
+
+	cache := cache.Load()
+	installedFilesCache := cache.GetEntry(cacheKey)
+	runHooks(layerList, "before-update")
+	plan := installPlan{
+		fileMapping:        newGenerationFileMapping(&config, layerList, generation, cacheKey, dotfilesDir),
+		oldLinks:           installedFilesCache.GetLinks(),
+		oldGeneratedFiles:  installedFilesCache.GetGeneratedFiles(),
+		keptLinks:          NewSymlinkCollection(0),
+		keptGeneratedFiles: map[AbsolutePath]string{},
+	}
+	added, removed := plan.apply()
+	plan.updateCache(installedFilesCache)
+	journal.Atomically(func() {
+		cache.Save()
+		generations.Record(cacheKey, dotfilesDir, installedFilesCache, isHardlinkMode(linkMode))
+	})
+	runHooks(layerList, "after-update")
+
+	printChanges(added, removed, nil)
+	log.Printlnf("Rolled back to generation %d", generation.Number)
+	return Changes{Added: added, Removed: removed}
+}
+
+func findGeneration(list []generations.Generation, number int) *generations.Generation {
+	if number == 0 {
+		if len(list) < 2 {
+			log.Fatal("There is no previous generation to roll back to")
+		}
+		return &list[len(list)-2]
+	}
+	for i := range list {
+		if list[i].Number == number {
+			return &list[i]
+		}
+	}
+	log.Fatal("Generation %d does not exist. Run 'doot generations list' to see the available generations.", number)
+	return nil
+}
+
+// Maps the targets to the sources recorded in the generation. Dotfiles that no longer exist, and hardlinked dotfiles
+// whose contents have changed, are restored as regular files with the recorded contents.
+func newGenerationFileMapping(config *config.Config, layerList []layers.Layer, generation *generations.Generation, cacheKey string, dotfilesDir AbsolutePath) FileMapping {
+	layerFiles := make([]LayerFiles, 0, len(layerList))
+	layerIndexes := make(map[string]int, len(layerList))
+	for i, layer := range layerList {
+		layerFiles = append(layerFiles, LayerFiles{Name: layer.Name, Dir: layer.Dir, Config: layer.Config})
+		layerIndexes[layer.Name] = i
+	}
+	fm := NewLayeredFileMapping(config, layerFiles)
+	for _, link := range generation.Links {
+		target := NewAbsolutePath(link.Path)
+		layerIndex, layerExists := layerIndexes[link.Layer]
+		if !layerExists {
+			layerIndex = len(layerList) - 1
+		}
+		source := SourcePath{path: NewAbsolutePath(link.Source), layer: layerIndex}
+		if generated := restoreContents(link, source, generation, cacheKey, dotfilesDir); generated != nil {
+			fm.generated[target] = generated
+		} else if _, err := filesystem.Lstat(link.Source); err == nil {
+			fm.mapping[target] = source
+		} else {
+			log.Warning("%s no longer exists and its contents were not recorded, skipping %s", link.Source, target)
+		}
+	}
+	for _, generated := range generation.Generated {
+		target := NewAbsolutePath(generated.Path)
+		contents, err := generations.ReadStoredFile(cacheKey, generated.Hash)
+		if err != nil {
+			log.Warning("The contents of %s were not recorded in generation %d, skipping it: %v", target, generation.Number, err)
+			continue
+		}
+		fm.generated[target] = &storedFile{
+			contents: contents,
+			perm:     generated.Mode,
+			origin:   generations.StoredFilePath(cacheKey, generated.Hash),
+		}
+	}
+	return fm
+}
+
+// Returns the recorded contents of the dotfile if it can't be linked as it was, or nil if it can
+func restoreContents(link generations.Link, source SourcePath, generation *generations.Generation, cacheKey string, dotfilesDir AbsolutePath) *storedFile {
+	if link.Hash != "" {
+		if hash, err := files.HashFile(source.path); err == nil && hash == link.Hash {
+			return nil
+		}
+		contents, err := generations.ReadStoredFile(cacheKey, link.Hash)
+		if err != nil {
+			return nil
+		}
+		log.Warning("%s has changed since generation %d, restoring %s as a copy of its previous contents", link.Source, generation.Number, link.Path)
+		return &storedFile{contents: contents, perm: sourcePerm(source.path), origin: generations.StoredFilePath(cacheKey, link.Hash), source: source}
+	}
+	if _, err := filesystem.Lstat(link.Source); err == nil || generation.Commit == "" {
+		return nil
+	}
+	contents, err := generations.ReadFileAtCommit(dotfilesDir, generation.Commit, source.path)
+	if err != nil {
+		return nil
+	}
+	log.Warning("%s no longer exists, restoring %s as a copy of its contents at commit %s", link.Source, link.Path, generations.ShortCommit(generation.Commit))
+	return &storedFile{contents: contents, perm: 0644, origin: source.path, source: source}
+}
+
+// A generated file whose contents come from a generation, instead of being rendered from the dotfiles
+type storedFile struct {
+	contents []byte
+	perm     os.FileMode
+	// Where the contents were taken from, only used in messages
+	origin AbsolutePath
+	source SourcePath
+}
+
+func (g *storedFile) render() ([]byte, os.FileMode, error) {
+	return g.contents, g.perm, nil
+}
+
+func (g *storedFile) highestSource() SourcePath {
+	return g.source
+}
+
+func (g *storedFile) sourcePaths() []AbsolutePath {
+	return []AbsolutePath{g.origin}
+}
+
+func sourcePerm(path AbsolutePath) os.FileMode {
+	if fileInfo, err := filesystem.Stat(path.Str()); err == nil {
+		return fileInfo.Mode().Perm()
+	}
+	return 0644
+}
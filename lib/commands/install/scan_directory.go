@@ -3,81 +3,199 @@ package install
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/config"
 	"github.com/pol-rivero/doot/lib/common/glob_collection"
 	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/modules"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/pol-rivero/doot/lib/utils/set"
 )
 
 type FileFilter struct {
-	IgnoreHidden        bool
 	IgnoreDootCrypt     bool
 	ExploreExcludedDirs bool
-	ExcludeGlobs        glob_collection.GlobCollection
-	IncludeGlobs        glob_collection.GlobCollection
+	FollowSymlinks      bool
+	Rules               glob_collection.RuleList
+	// Directories of the modules that are not enabled are never explored
+	Modules modules.Selection
 }
 
 func CreateFilter(config *config.Config, ignoreDootCrypt bool) FileFilter {
-	ignoreHidden := false
-	newExcludeFiles := make([]string, 0, len(config.ExcludeFiles))
-	for _, excludePattern := range config.ExcludeFiles {
-		if excludePattern == common.IGNORE_HIDDEN_FILES_GLOB {
-			ignoreHidden = true
-		} else {
-			newExcludeFiles = append(newExcludeFiles, excludePattern)
-		}
-	}
 	return FileFilter{
-		IgnoreHidden:        ignoreHidden,
 		IgnoreDootCrypt:     ignoreDootCrypt,
 		ExploreExcludedDirs: config.ExploreExcludedDirs,
-		ExcludeGlobs:        glob_collection.NewGlobCollection(newExcludeFiles),
-		IncludeGlobs:        glob_collection.NewGlobCollection(config.IncludeFiles),
+		FollowSymlinks:      config.FollowRepoSymlinks,
+		Rules:               glob_collection.NewRuleList(config.FilterRules()),
 	}
 }
 
 const SEPARATOR_LEN = len(string(filepath.Separator))
 
+type readDirFunc func(dir AbsolutePath) ([]os.DirEntry, error)
+
+type directoryScanner struct {
+	filter    *FileFilter
+	result    []RelativePath
+	prefixLen int
+	readDir   readDirFunc
+	// Directories that are currently being scanned (the current one and its ancestors), used to detect symlink cycles
+	activeDirs set.Set[files.FileId]
+}
+
 func ScanDirectory(dir AbsolutePath, filter *FileFilter) []RelativePath {
-	prefixLen := len(dir) + SEPARATOR_LEN
-	files := make([]RelativePath, 0, 64)
-	scanDirectoryRecursive(filter, &files, prefixLen, dir, false)
-	return files
+	readDir := func(dir AbsolutePath) ([]os.DirEntry, error) {
+		return filesystem.ReadDir(dir.Str())
+	}
+	return scanWith(dir, filter, readDir)
+}
+
+func scanWith(dir AbsolutePath, filter *FileFilter, readDir readDirFunc) []RelativePath {
+	scanner := directoryScanner{
+		filter:    filter,
+		result:    make([]RelativePath, 0, 64),
+		prefixLen: len(dir) + SEPARATOR_LEN,
+		readDir:   readDir,
+	}
+	if filter.FollowSymlinks {
+		scanner.activeDirs = set.New[files.FileId](16)
+		if _, ok := scanner.enterDir(dir); !ok {
+			return scanner.result
+		}
+	}
+	scanner.scanRecursive(dir, false, glob_collection.IgnoreStack{})
+	return scanner.result
 }
 
-func scanDirectoryRecursive(filter *FileFilter, result *[]RelativePath, prefixLen int, scanPath AbsolutePath, inExcludedDir bool) {
-	entries, err := os.ReadDir(scanPath.Str())
+func (s *directoryScanner) scanRecursive(scanPath AbsolutePath, inExcludedDir bool, ignoreStack glob_collection.IgnoreStack) {
+	entries, err := s.readDir(scanPath)
 	if err != nil {
 		log.Error("Error reading directory %s: %v", scanPath, err)
 		return
 	}
+	ignoreStack = pushDootIgnoreFile(ignoreStack, entries, scanPath, s.prefixLen)
 	for _, entry := range entries {
 		entryName := entry.Name()
+		if entryName == common.DOOT_IGNORE_FILE {
+			continue
+		}
 		entryPath := scanPath.Join(entryName)
-		entryRelativePath := entryPath.ExtractRelativePath(prefixLen)
-		fileOrDirIsExcluded := filter.isExcluded(entryRelativePath, entryName, inExcludedDir)
-		if fileOrDirIsExcluded && !filter.ExploreExcludedDirs {
+		entryRelativePath := entryPath.ExtractRelativePath(s.prefixLen)
+		isDir := s.isDirectory(entry, entryPath)
+		if isDir && s.filter.Modules.IsDisabledDir(entryRelativePath) {
+			continue
+		}
+		var fileOrDirIsExcluded bool
+		switch ignoreStack.Match(entryRelativePath, isDir) {
+		case glob_collection.IGNORED:
+			continue
+		case glob_collection.UNIGNORED:
+			fileOrDirIsExcluded = s.filter.IgnoreDootCrypt && strings.Contains(entryName, common.DOOT_CRYPT_EXT)
+		default:
+			fileOrDirIsExcluded = s.filter.isExcluded(entryRelativePath, entryName, inExcludedDir)
+		}
+		if fileOrDirIsExcluded && !s.filter.shouldExploreExcluded(entryRelativePath, isDir) {
 			continue
 		}
 
-		if entry.IsDir() {
-			scanDirectoryRecursive(filter, result, prefixLen, entryPath, fileOrDirIsExcluded)
+		if isDir {
+			s.scanSubdirectory(entryPath, fileOrDirIsExcluded, ignoreStack)
 		} else if !fileOrDirIsExcluded {
-			*result = append(*result, entryRelativePath)
+			s.result = append(s.result, entryRelativePath)
+		}
+	}
+}
+
+// Symlinks to directories are only considered directories if FollowSymlinks is enabled
+func (s *directoryScanner) isDirectory(entry os.DirEntry, entryPath AbsolutePath) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if !s.filter.FollowSymlinks || entry.Type()&os.ModeSymlink == 0 {
+		return false
+	}
+	info, err := filesystem.Stat(entryPath.Str())
+	if err != nil {
+		log.Warning("Could not follow symlink %s: %v", entryPath, err)
+		return false
+	}
+	return info.IsDir()
+}
+
+func (s *directoryScanner) scanSubdirectory(dir AbsolutePath, inExcludedDir bool, ignoreStack glob_collection.IgnoreStack) {
+	if !s.filter.FollowSymlinks {
+		s.scanRecursive(dir, inExcludedDir, ignoreStack)
+		return
+	}
+	dirId, ok := s.enterDir(dir)
+	if !ok {
+		return
+	}
+	s.scanRecursive(dir, inExcludedDir, ignoreStack)
+	s.activeDirs.Remove(dirId)
+}
+
+func (s *directoryScanner) enterDir(dir AbsolutePath) (files.FileId, bool) {
+	dirId, err := files.GetFileId(dir)
+	if err != nil {
+		log.Error("Error reading directory %s: %v", dir, err)
+		return dirId, false
+	}
+	if s.activeDirs.Contains(dirId) {
+		log.Warning("Skipping %s because it's a symlink to one of its parent directories", dir)
+		return dirId, false
+	}
+	s.activeDirs.Add(dirId)
+	return dirId, true
+}
+
+func pushDootIgnoreFile(ignoreStack glob_collection.IgnoreStack, entries []os.DirEntry, dir AbsolutePath, prefixLen int) glob_collection.IgnoreStack {
+	hasIgnoreFile := slices.ContainsFunc(entries, func(entry os.DirEntry) bool {
+		return entry.Name() == common.DOOT_IGNORE_FILE && !entry.IsDir()
+	})
+	if !hasIgnoreFile {
+		return ignoreStack
+	}
+	ignoreFilePath := dir.Join(common.DOOT_IGNORE_FILE)
+	contents, err := filesystem.ReadFile(ignoreFilePath.Str())
+	if err != nil {
+		log.Warning("Could not read %s: %v", ignoreFilePath, err)
+		return ignoreStack
+	}
+	baseDir := RelativePath("")
+	if len(dir) >= prefixLen {
+		baseDir = dir.ExtractRelativePath(prefixLen)
+	}
+	ignoreFile := glob_collection.NewIgnoreFile(baseDir, string(contents))
+	return ignoreStack.Push(&ignoreFile)
+}
+
+// Evaluates each component of the path in the same way as ScanDirectory (.dootignore files are not considered).
+// Returns false if the path is excluded or it's inside a directory that wouldn't be explored.
+func (f *FileFilter) IsPathIncluded(path RelativePath) bool {
+	components := strings.Split(path.Str(), string(filepath.Separator))
+	excluded := false
+	for i, component := range components {
+		componentPath := RelativePath(filepath.Join(components[:i+1]...))
+		excluded = f.isExcluded(componentPath, component, excluded)
+		isDir := i < len(components)-1
+		if excluded && !f.shouldExploreExcluded(componentPath, isDir) {
+			return false
 		}
 	}
+	return !excluded
 }
 
 func (f *FileFilter) isExcluded(path RelativePath, fileName string, inExcludedDir bool) bool {
-	return (inExcludedDir || f.matchesExcludePattern(path, fileName)) &&
-		!f.IncludeGlobs.Matches(path)
+	excludedByDefault := inExcludedDir || (f.IgnoreDootCrypt && strings.Contains(fileName, common.DOOT_CRYPT_EXT))
+	return f.Rules.IsExcluded(path, fileName, excludedByDefault)
 }
 
-func (f *FileFilter) matchesExcludePattern(path RelativePath, fileName string) bool {
-	return (f.IgnoreHidden && fileName[0] == '.') ||
-		(f.IgnoreDootCrypt && strings.Contains(fileName, common.DOOT_CRYPT_EXT)) ||
-		f.ExcludeGlobs.Matches(path)
+func (f *FileFilter) shouldExploreExcluded(path RelativePath, isDir bool) bool {
+	return isDir && (f.ExploreExcludedDirs || f.Rules.MayIncludeBelow(path))
 }
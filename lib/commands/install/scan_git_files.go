@@ -0,0 +1,82 @@
+package install
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Same as ScanDirectory, but only the files known to git are considered. The filters are applied on top of that list.
+// Returns false if the directory is not a git repository.
+func ScanGitFiles(dir AbsolutePath, filter *FileFilter, includeUntracked bool) ([]RelativePath, bool) {
+	args := []string{"ls-files", "-z", "--cached"}
+	if includeUntracked {
+		args = append(args, "--others", "--exclude-standard")
+	}
+	output, err := utils.RunCommandOutput(dir, "git", args...)
+	if err != nil {
+		log.Info("Could not list git files in %s: %v", dir, err)
+		return nil, false
+	}
+	tree := buildGitTree(dir, output)
+	readDir := func(dir AbsolutePath) ([]os.DirEntry, error) {
+		if entries, isKnownDir := tree[dir]; isKnownDir {
+			return entries, nil
+		}
+		// Git doesn't list the contents of symlinked directories, which are only scanned if FollowSymlinks is enabled
+		return filesystem.ReadDir(dir.Str())
+	}
+	return scanWith(dir, filter, readDir), true
+}
+
+// Returns a map of directory -> entries, including only the files listed by git (and their parent directories)
+func buildGitTree(dir AbsolutePath, lsFilesOutput []byte) map[AbsolutePath][]os.DirEntry {
+	tree := make(map[AbsolutePath][]os.DirEntry)
+	tree[dir] = []os.DirEntry{}
+	for gitPath := range bytes.SplitSeq(lsFilesOutput, []byte{0}) {
+		if len(gitPath) == 0 {
+			continue
+		}
+		filePath := dir.Join(filepath.FromSlash(string(gitPath)))
+		info, err := filesystem.Lstat(filePath.Str())
+		if err != nil {
+			// The file is in the index but it has been deleted from the working tree
+			log.Info("Skipping %s: %v", filePath, err)
+			continue
+		}
+		if info.IsDir() {
+			log.Info("Skipping %s because it's a git submodule", filePath)
+			continue
+		}
+		addToGitTree(tree, dir, filePath, info)
+	}
+	for _, entries := range tree {
+		slices.SortFunc(entries, func(a, b os.DirEntry) int {
+			return strings.Compare(a.Name(), b.Name())
+		})
+	}
+	return tree
+}
+
+func addToGitTree(tree map[AbsolutePath][]os.DirEntry, rootDir, path AbsolutePath, info fs.FileInfo) {
+	parent := path.Parent()
+	_, parentAlreadyAdded := tree[parent]
+	tree[parent] = append(tree[parent], fs.FileInfoToDirEntry(info))
+	if parentAlreadyAdded || parent == rootDir {
+		return
+	}
+	parentInfo, err := filesystem.Lstat(parent.Str())
+	if err != nil {
+		log.Error("Error reading directory %s: %v", parent, err)
+		return
+	}
+	addToGitTree(tree, rootDir, parent, parentInfo)
+}
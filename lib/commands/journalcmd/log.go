@@ -0,0 +1,53 @@
+package journalcmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/log"
+)
+
+const TIME_FORMAT string = "2006-01-02 15:04:05"
+
+// Prints the recorded operations, from newest to oldest. In verbose mode, the changes of each operation are listed too.
+func PrintLog() {
+	operations := journal.Load()
+	if len(operations) == 0 {
+		log.Printlnf("No operations have been recorded yet")
+		return
+	}
+	undone := journal.UndoneIds(operations)
+	var sb strings.Builder
+	for i := len(operations) - 1; i >= 0; i-- {
+		op := &operations[i]
+		sb.WriteString(fmt.Sprintf("%4d  %s  ", op.Id, op.Time.Local().Format(TIME_FORMAT)))
+		if op.Command == journal.UNDO_COMMAND {
+			sb.WriteString(fmt.Sprintf("undo (reverted operation %d)\n", op.Undoes))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s: %d %s", describe(op), len(op.Actions), pluralize(len(op.Actions), "change", "changes")))
+		if slices.Contains(undone, op.Id) {
+			sb.WriteString(" (undone)")
+		}
+		sb.WriteString("\n")
+		if log.IsVerbose() {
+			for _, action := range op.Actions {
+				sb.WriteString("        " + describeAction(&action) + "\n")
+			}
+		}
+	}
+	log.Printlnf("%s", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+func describeAction(action *journal.Action) string {
+	switch {
+	case action.Type == journal.Moved:
+		return fmt.Sprintf("moved %s -> %s", action.Source, action.Path)
+	case action.Link != "" && action.Type != journal.Removed:
+		return fmt.Sprintf("%s %s -> %s", action.Type, action.Path, action.Link)
+	default:
+		return fmt.Sprintf("%s %s", action.Type, action.Path)
+	}
+}
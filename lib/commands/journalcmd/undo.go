@@ -0,0 +1,44 @@
+package journalcmd
+
+import (
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Reverts the changes of the most recent operation that has not been undone yet. Returns the undone operation, or nil if
+// there was nothing to undo.
+func Undo() *journal.Operation {
+	op := journal.LastUndoable(journal.Load())
+	if op == nil {
+		log.Printlnf("Nothing to undo")
+		return nil
+	}
+	if _, err := filesystem.Stat(op.BackupDir()); err != nil {
+		log.Fatal("The backups of operation %d (%s) have been deleted, it can't be undone", op.Id, describe(op))
+	}
+
+	unreverted := journal.Revert(op)
+	journal.RestoreCache(op.CacheSnapshot(), op.CacheKeys(), unreverted)
+	journal.AppendUndo(op)
+
+	reverted := len(op.Actions) - len(unreverted)
+	log.Printlnf("Undid operation %d (%s): %d %s reverted", op.Id, describe(op), reverted, pluralize(reverted, "change", "changes"))
+	if len(unreverted) > 0 {
+		log.Warning("%d %s could not be reverted, see the messages above", len(unreverted), pluralize(len(unreverted), "change", "changes"))
+	}
+	return op
+}
+
+func describe(op *journal.Operation) string {
+	return strings.TrimSpace(op.Command + " " + strings.Join(op.Args, " "))
+}
+
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
@@ -1,10 +1,15 @@
 package ls
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/cache"
 	"github.com/pol-rivero/doot/lib/common/config"
 	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
 )
 
 func ListInstalledFiles(asJson bool) {
@@ -16,9 +21,26 @@ func ListInstalledFiles(asJson bool) {
 	installedFilesCache := cache.GetEntry(cacheKey)
 
 	installedLinks := installedFilesCache.GetLinks()
+	linkLayers := installedFilesCache.GetLinkLayers()
 	if asJson {
 		log.Printlnf("%s", installedLinks.ToJson())
+	} else if len(linkLayers) > 0 {
+		log.Printlnf("%s", printListWithLayers(&installedLinks, linkLayers))
 	} else {
 		log.Printlnf("%s", installedLinks.PrintList())
 	}
 }
+
+func printListWithLayers(links *SymlinkCollection, linkLayers map[AbsolutePath]string) string {
+	paths := make([]AbsolutePath, 0, links.Len())
+	for path := range links.Iter() {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var sb strings.Builder
+	for _, path := range paths {
+		sb.WriteString(fmt.Sprintf("%s -> %s [%s]\n", path, links.Iter()[path], linkLayers[path]))
+	}
+	return sb.String()
+}
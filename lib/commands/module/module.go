@@ -0,0 +1,119 @@
+package module
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/layers"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/modules"
+)
+
+// The modules of a layer
+type layerSelection struct {
+	layer     layers.Layer
+	selection modules.Selection
+}
+
+func List() {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+
+	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	cache := cache.Load()
+	allModules := resolveModules(layers.Resolve(dotfilesDir, &config), cache.GetEntry(cacheKey))
+
+	nameWidth := 0
+	for _, entry := range allModules {
+		for _, module := range entry.selection.Modules() {
+			nameWidth = max(nameWidth, len(module.Name))
+		}
+	}
+	if nameWidth == 0 {
+		log.Printlnf("No modules defined. Add a [modules] table or set 'auto_modules = true' in the config file.")
+		return
+	}
+	var sb strings.Builder
+	for _, entry := range allModules {
+		for _, module := range entry.selection.Modules() {
+			status := "disabled"
+			if module.Enabled {
+				status = "enabled"
+			}
+			if module.Reason != "" {
+				status += " (" + module.Reason + ")"
+			}
+			dir := module.Dir
+			if len(allModules) > 1 {
+				dir = entry.layer.Name + ":" + dir
+			}
+			sb.WriteString(fmt.Sprintf("%-*s  %s  [%s]\n", nameWidth, module.Name, status, dir))
+		}
+	}
+	log.Printlnf("%s", strings.TrimSuffix(sb.String(), "\n"))
+}
+
+func Enable(names []string) {
+	setEnabled(names, true)
+}
+
+func Disable(names []string) {
+	setEnabled(names, false)
+}
+
+func setEnabled(names []string, enabled bool) {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+
+	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	cache := cache.Load()
+	installedFilesCache := cache.GetEntry(cacheKey)
+	allModules := resolveModules(layers.Resolve(dotfilesDir, &config), installedFilesCache)
+
+	changed := false
+	for _, name := range names {
+		if !moduleExists(allModules, name) {
+			log.Error("Module '%s' doesn't exist. Run 'doot module list' to see the available modules.", name)
+			continue
+		}
+		installedFilesCache.SetModuleOverride(name, enabled)
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	cache.Save()
+
+	log.Info("Module selection has been updated, now running 'install'...")
+	install.Install(false)
+}
+
+// Local overrides apply to the modules with that name in any layer
+func resolveModules(layerList []layers.Layer, installedFilesCache *cache.InstalledFilesCache) []layerSelection {
+	enabled, disabled := installedFilesCache.GetModuleOverrides()
+	overrides := modules.LocalOverrides{
+		Enabled:  enabled,
+		Disabled: disabled,
+	}
+	result := make([]layerSelection, 0, len(layerList))
+	for _, layer := range layerList {
+		result = append(result, layerSelection{
+			layer:     layer,
+			selection: modules.Resolve(layer.Config, layer.Dir, common.GetHostname(), overrides),
+		})
+	}
+	return result
+}
+
+func moduleExists(allModules []layerSelection, name string) bool {
+	for _, entry := range allModules {
+		if entry.selection.Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
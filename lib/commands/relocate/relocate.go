@@ -0,0 +1,286 @@
+package relocate
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+type relocation struct {
+	oldDir       string
+	newDir       string
+	movedLinks   int
+	updatedLinks int
+	// The changes are recorded in the journal, so that they can be undone
+	journaled bool
+}
+
+// Replaces the old path with the new one in every cache entry that refers to it (as the dotfiles directory, the target
+// directory, or the location of a dotfile or a link), and updates the links on disk.
+func Relocate(oldDirArg string, newDirArg string) {
+	oldDir := RelativeToPWD(oldDirArg)
+	newDir := RelativeToPWD(newDirArg)
+	if oldDir == newDir {
+		log.Fatal("The old and new paths are the same")
+	}
+	if fileInfo, err := filesystem.Stat(newDir.Str()); err != nil || !fileInfo.IsDir() {
+		log.Fatal("%s does not exist or is not a directory", newDir)
+	}
+
+	journal.Begin("relocate", []string{oldDir.Str(), newDir.Str()})
+	defer journal.End()
+	dootCache := cache.Load()
+	r := relocation{oldDir: oldDir.Str(), newDir: newDir.Str(), journaled: true}
+	entryCount := r.relocateCache(&dootCache)
+	if entryCount == 0 {
+		log.Fatal("No installed dotfiles refer to %s. Run 'doot cache show' to see the installed dotfiles.", oldDir)
+	}
+	journal.Atomically(dootCache.Save)
+	updateRegisteredRepos(oldDir, newDir)
+	log.Printlnf("Relocated %s -> %s in %d cache %s (%d links moved, %d links updated)",
+		oldDir, newDir, entryCount, pluralize(entryCount, "entry", "entries"), r.movedLinks, r.updatedLinks)
+}
+
+// If there is no cache entry for the dotfiles directory, but there is one for the same target whose dotfiles directory
+// no longer exists and all of its dotfiles exist in the current directory, the repository has probably been moved.
+// In that case, the old entry is relocated. Returns true if it was.
+func DetectMovedDotfilesDir(dootCache *cache.DootCache, dotfilesDir AbsolutePath, targetDir string) bool {
+	if dootCache.FindEntry(cache.ComputeCacheKey(dotfilesDir, targetDir)) != nil {
+		return false
+	}
+	candidates := make([]AbsolutePath, 0, 1)
+	for _, entry := range dootCache.Entries {
+		oldDotfilesDir, oldTargetDir := cache.SplitCacheKey(entry.CacheKey)
+		if oldTargetDir != targetDir || pathExists(oldDotfilesDir) {
+			continue
+		}
+		if hasSameContents(entry.InstalledFiles, oldDotfilesDir, dotfilesDir) {
+			candidates = append(candidates, oldDotfilesDir)
+		}
+	}
+	if len(candidates) != 1 {
+		return false
+	}
+	log.Printlnf("The dotfiles directory seems to have been moved from %s to %s. Relocating installed links...", candidates[0], dotfilesDir)
+	r := relocation{oldDir: candidates[0].Str(), newDir: dotfilesDir.Str()}
+	r.relocateCache(dootCache)
+	updateRegisteredRepos(candidates[0], dotfilesDir)
+	log.Printlnf("%d links updated", r.updatedLinks)
+	return true
+}
+
+func hasSameContents(entry *cache.InstalledFilesCache, oldDotfilesDir AbsolutePath, newDotfilesDir AbsolutePath) bool {
+	matched := 0
+	for _, link := range entry.Links {
+		relativePath, isInside := strings.CutPrefix(link.Content, oldDotfilesDir.Str()+string(filepath.Separator))
+		if !isInside {
+			continue
+		}
+		if !pathExists(newDotfilesDir.Join(relativePath)) {
+			return false
+		}
+		matched++
+	}
+	return matched > 0
+}
+
+// Returns the number of relocated entries
+func (r *relocation) relocateCache(dootCache *cache.DootCache) int {
+	relocatedCount := 0
+	for _, entry := range slices.Clone(dootCache.Entries) {
+		oldDotfilesDir, oldTargetDir := cache.SplitCacheKey(entry.CacheKey)
+		if !r.refersToOldDir(oldDotfilesDir.Str(), oldTargetDir, entry.InstalledFiles) {
+			continue
+		}
+		newDotfilesDir, _ := r.relocatePath(oldDotfilesDir.Str())
+		newTargetDir, _ := r.relocatePath(oldTargetDir)
+		newKey := cache.ComputeCacheKey(NewAbsolutePath(newDotfilesDir), newTargetDir)
+		if r.journaled {
+			journal.AddCacheKey(entry.CacheKey)
+			journal.AddCacheKey(newKey)
+		}
+		r.relocateEntry(entry.InstalledFiles, NewAbsolutePath(oldTargetDir), NewAbsolutePath(newTargetDir))
+
+		if newKey != entry.CacheKey {
+			if existing := dootCache.FindEntry(newKey); existing != nil {
+				log.Info("Merging cache entry %s into %s", entry.CacheKey, newKey)
+				mergeEntry(existing, entry.InstalledFiles)
+				dootCache.Entries = slices.DeleteFunc(dootCache.Entries, func(e *cache.CacheEntry) bool { return e == entry })
+			} else {
+				entry.CacheKey = newKey
+			}
+		}
+		relocatedCount++
+	}
+	return relocatedCount
+}
+
+func (r *relocation) refersToOldDir(dotfilesDir string, targetDir string, entry *cache.InstalledFilesCache) bool {
+	if _, relocated := r.relocatePath(dotfilesDir); relocated {
+		return true
+	}
+	if _, relocated := r.relocatePath(targetDir); relocated {
+		return true
+	}
+	return slices.ContainsFunc(entry.Links, func(link *cache.InstalledFile) bool {
+		_, pathRelocated := r.relocatePath(link.Path)
+		_, contentRelocated := r.relocatePath(link.Content)
+		return pathRelocated || contentRelocated
+	})
+}
+
+func (r *relocation) relocateEntry(entry *cache.InstalledFilesCache, oldTargetDir AbsolutePath, newTargetDir AbsolutePath) {
+	for _, link := range entry.Links {
+		newPath, pathRelocated := r.relocatePath(link.Path)
+		newContent, contentRelocated := r.relocatePath(link.Content)
+		if pathRelocated && r.moveFile(NewAbsolutePath(link.Path), NewAbsolutePath(newPath), oldTargetDir, newTargetDir) {
+			r.movedLinks++
+		}
+		if contentRelocated && r.updateSymlink(NewAbsolutePath(newPath), link.Content, newContent, newTargetDir) {
+			r.updatedLinks++
+		}
+		link.Path = newPath
+		link.Content = newContent
+	}
+	for _, generated := range entry.Generated {
+		if newPath, relocated := r.relocatePath(generated.Path); relocated {
+			r.moveFile(NewAbsolutePath(generated.Path), NewAbsolutePath(newPath), oldTargetDir, newTargetDir)
+			generated.Path = newPath
+		}
+		for i, source := range generated.Sources {
+			generated.Sources[i], _ = r.relocatePath(source)
+		}
+	}
+	for i, target := range entry.IgnoredTargets {
+		entry.IgnoredTargets[i], _ = r.relocatePath(target)
+	}
+}
+
+// Returns the path with the old directory replaced by the new one, and whether it was inside the old directory
+func (r *relocation) relocatePath(path string) (string, bool) {
+	if path == r.oldDir {
+		return r.newDir, true
+	}
+	if rest, isInside := strings.CutPrefix(path, r.oldDir+string(filepath.Separator)); isInside {
+		return filepath.Join(r.newDir, rest), true
+	}
+	return path, false
+}
+
+// Moves a link or generated file to its new location, unless it has already been moved (for example, if the whole
+// target directory was moved). Returns true if the file was moved.
+func (r *relocation) moveFile(oldPath AbsolutePath, newPath AbsolutePath, oldTargetDir AbsolutePath, newTargetDir AbsolutePath) bool {
+	if _, err := filesystem.Lstat(oldPath.Str()); err != nil {
+		return false
+	}
+	if _, err := filesystem.Lstat(newPath.Str()); err == nil {
+		log.Warning("Both %s and %s exist, leaving %s untouched", oldPath, newPath, oldPath)
+		return false
+	}
+	if !files.EnsureParentDir(newPath) {
+		return false
+	}
+	log.Info("Moving %s -> %s", oldPath, newPath)
+	change := r.prepare(newPath, newTargetDir)
+	err := filesystem.Rename(oldPath.Str(), newPath.Str())
+	change.DoneMoving(err == nil, oldPath)
+	if err != nil {
+		log.Error("Failed to move %s to %s: %v", oldPath, newPath, err)
+		return false
+	}
+	files.CleanupEmptyDir(oldPath.Parent(), oldTargetDir)
+	return true
+}
+
+// Points the symlink to the new location of its dotfile, if it still points to the old one. Hardlinks don't need to
+// be updated. Returns true if the symlink was updated.
+func (r *relocation) updateSymlink(linkPath AbsolutePath, oldContent string, newContent string, targetDir AbsolutePath) bool {
+	fileInfo, err := filesystem.Lstat(linkPath.Str())
+	if err != nil || !common.IsSymlink(fileInfo) {
+		return false
+	}
+	currentContent, err := filesystem.Readlink(linkPath.Str())
+	if err != nil || currentContent != oldContent {
+		return false
+	}
+	log.Info("Updating link %s -> %s", linkPath, newContent)
+	change := r.prepare(linkPath, targetDir)
+	if err := filesystem.Remove(linkPath.Str()); err != nil {
+		change.Done(false)
+		log.Error("Failed to remove %s: %v", linkPath, err)
+		return false
+	}
+	err = filesystem.Symlink(newContent, linkPath.Str())
+	change.Done(true)
+	if err != nil {
+		log.Error("Failed to create link %s -> %s: %v", linkPath, newContent, err)
+		return false
+	}
+	return true
+}
+
+// The moved dotfiles directory detected by install is not part of the install operation, so it isn't journaled
+func (r *relocation) prepare(path AbsolutePath, root AbsolutePath) *journal.Change {
+	if !r.journaled {
+		return &journal.Change{}
+	}
+	return journal.Prepare(path, root)
+}
+
+// Adds the links and settings of src that are not in dst
+func mergeEntry(dst *cache.InstalledFilesCache, src *cache.InstalledFilesCache) {
+	for _, link := range src.Links {
+		if !slices.ContainsFunc(dst.Links, func(l *cache.InstalledFile) bool { return l.Path == link.Path }) {
+			dst.Links = append(dst.Links, link)
+		}
+	}
+	for _, generated := range src.Generated {
+		if !slices.ContainsFunc(dst.Generated, func(g *cache.GeneratedFile) bool { return g.Path == generated.Path }) {
+			dst.Generated = append(dst.Generated, generated)
+		}
+	}
+	if len(dst.EnabledModules) == 0 && len(dst.DisabledModules) == 0 {
+		dst.EnabledModules = src.EnabledModules
+		dst.DisabledModules = src.DisabledModules
+	}
+	for _, target := range src.IgnoredTargets {
+		if !slices.Contains(dst.IgnoredTargets, target) {
+			dst.IgnoredTargets = append(dst.IgnoredTargets, target)
+		}
+	}
+}
+
+func updateRegisteredRepos(oldDir AbsolutePath, newDir AbsolutePath) {
+	userConfig := common.LoadUserConfig()
+	name := userConfig.FindRepo(oldDir.Str())
+	if name == "" && filepath.Clean(userConfig.DotfilesDir) != oldDir.Str() {
+		return
+	}
+	if name != "" {
+		userConfig.Repos[name] = newDir.Str()
+	} else {
+		userConfig.DotfilesDir = newDir.Str()
+	}
+	userConfig.Save()
+	log.Info("Updated %s", common.UserConfigPath())
+}
+
+func pathExists(path AbsolutePath) bool {
+	_, err := filesystem.Lstat(path.Str())
+	return err == nil
+}
+
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+func Add(dirArg string, name string) {
+	dir := RelativeToPWD(dirArg)
+	if fileInfo, err := filesystem.Stat(dir.Str()); err != nil || !fileInfo.IsDir() {
+		log.Fatal("%s does not exist or is not a directory", dir)
+	}
+	userConfig := common.LoadUserConfig()
+	if existingDir, exists := userConfig.Repos[name]; exists && existingDir != dir.Str() {
+		log.Fatal("There is already a repository named '%s' (%s)", name, existingDir)
+	}
+
+	previousName := userConfig.FindRepo(dir.Str())
+	name = userConfig.Register(dir.Str(), name)
+	if userConfig.SelectedDir() == "" {
+		userConfig.Current = name
+	}
+	userConfig.Save()
+	if previousName != "" && previousName != name {
+		log.Printlnf("Renamed repository '%s' to '%s' (%s)", previousName, name, dir)
+	} else {
+		log.Printlnf("Registered repository '%s' (%s)", name, dir)
+	}
+	if userConfig.Current != name {
+		log.Printlnf("Run 'doot repo use %s' to use it by default.", name)
+	}
+}
+
+func List() {
+	userConfig := common.LoadUserConfig()
+	names := userConfig.RepoNames()
+	if len(names) == 0 && userConfig.DotfilesDir == "" {
+		log.Printlnf("No repositories registered. Run 'doot repo add <dir>' to register one.")
+		return
+	}
+
+	nameWidth := 0
+	for _, name := range names {
+		nameWidth = max(nameWidth, len(name))
+	}
+	var sb strings.Builder
+	for _, name := range names {
+		marker := " "
+		if name == userConfig.Current {
+			marker = "*"
+		}
+		sb.WriteString(fmt.Sprintf("%s %-*s  %s%s\n", marker, nameWidth, name, userConfig.Repos[name], missingSuffix(userConfig.Repos[name])))
+	}
+	if userConfig.DotfilesDir != "" {
+		marker := " "
+		if userConfig.Current == "" {
+			marker = "*"
+		}
+		sb.WriteString(fmt.Sprintf("%s %-*s  %s%s\n", marker, nameWidth, "(dotfiles_dir)", userConfig.DotfilesDir, missingSuffix(userConfig.DotfilesDir)))
+	}
+	log.Printlnf("%s", strings.TrimSuffix(sb.String(), "\n"))
+
+	if dootDir := os.Getenv(common.ENV_DOOT_DIR); dootDir != "" {
+		log.Warning("$DOOT_DIR is set to %s, it takes precedence over the selected repository", dootDir)
+	}
+}
+
+func Use(name string) {
+	userConfig := common.LoadUserConfig()
+	dir, exists := userConfig.Repos[name]
+	if !exists {
+		log.Fatal("Repository '%s' is not registered. Run 'doot repo list' to see the registered repositories.", name)
+	}
+	userConfig.Current = name
+	userConfig.Save()
+	log.Printlnf("Now using repository '%s' (%s)", name, dir)
+
+	if dootDir := os.Getenv(common.ENV_DOOT_DIR); dootDir != "" && dootDir != dir {
+		log.Warning("$DOOT_DIR is set to %s, it takes precedence over the selected repository", dootDir)
+	}
+}
+
+// Registers the directory (if it isn't already) and selects it as the default repository
+func RegisterAndUse(dir AbsolutePath) {
+	userConfig := common.LoadUserConfig()
+	name := userConfig.Register(dir.Str(), "")
+	userConfig.Current = name
+	userConfig.Save()
+	log.Info("Registered %s as repository '%s' in %s", dir, name, common.UserConfigPath())
+}
+
+func missingSuffix(dir string) string {
+	if fileInfo, err := filesystem.Stat(dir); err != nil || !fileInfo.IsDir() {
+		return " (missing)"
+	}
+	return ""
+}
@@ -3,40 +3,44 @@ package restore
 import (
 	"errors"
 	"os"
-	"path/filepath"
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/cache"
 	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/journal"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
-func Restore(inputFiles []string) {
+// Returns the files that were restored
+func Restore(inputFiles []string) []AbsolutePath {
+	journal.Begin("restore", inputFiles)
+	defer journal.End()
 	dotfilesDir := common.FindDotfilesDir()
 	config := config.FromDotfilesDir(dotfilesDir)
 
 	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	journal.SetCacheKey(cacheKey)
 	cache := cache.Load()
 	installedFilesCache := cache.GetEntry(cacheKey)
 
 	installedLinks := installedFilesCache.GetLinks()
-	successCount := restoreFiles(inputFiles, installedLinks, dotfilesDir)
+	restored := restoreFiles(inputFiles, installedLinks, dotfilesDir)
 
 	installedFilesCache.SetLinks(installedLinks)
 	cache.Save()
 
-	if successCount == 0 {
-		os.Exit(1)
-	} else {
-		fileOrFiles := map[bool]string{true: "files", false: "file"}[successCount != 1]
-		log.Printlnf("Successfully restored %d %s", successCount, fileOrFiles)
+	if len(restored) > 0 {
+		fileOrFiles := map[bool]string{true: "files", false: "file"}[len(restored) != 1]
+		log.Printlnf("Successfully restored %d %s", len(restored), fileOrFiles)
 	}
+	return restored
 }
 
-func restoreFiles(inputFiles []string, installedLinks SymlinkCollection, dotfilesDir AbsolutePath) int {
-	successCount := 0
+func restoreFiles(inputFiles []string, installedLinks SymlinkCollection, dotfilesDir AbsolutePath) []AbsolutePath {
+	restored := make([]AbsolutePath, 0, len(inputFiles))
 	for _, rawInput := range inputFiles {
 		filePath, err := ensureFileExists(rawInput)
 		if err == nil {
@@ -47,18 +51,18 @@ func restoreFiles(inputFiles []string, installedLinks SymlinkCollection, dotfile
 			log.Error("Failed to restore '%s': %v", rawInput, err)
 		} else {
 			log.Info("Successfully restored '%s'", rawInput)
-			successCount++
+			restored = append(restored, filePath)
 		}
 	}
-	return successCount
+	return restored
 }
 
 func ensureFileExists(rawInput string) (AbsolutePath, error) {
-	cleanAbsFile, err := filepath.Abs(rawInput)
+	cleanAbsFile, err := filesystem.Abs(rawInput)
 	if err != nil {
 		log.Fatal("Failed to get absolute path for '%s': %v", rawInput, err)
 	}
-	info, err := os.Lstat(cleanAbsFile)
+	info, err := filesystem.Lstat(cleanAbsFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return "", errors.New("file not found")
@@ -86,7 +90,10 @@ func restoreFile(filePath AbsolutePath, installedLinks SymlinkCollection, dotfil
 
 func overwriteLink(symlinkPath, dotfilePath, dotfilesDir AbsolutePath) error {
 	log.Info("Moving '%s' -> '%s'", dotfilePath, symlinkPath)
-	if err := files.MoveOrCopyFile(dotfilePath.Str(), symlinkPath.Str(), true); err != nil {
+	change := journal.Prepare(symlinkPath, symlinkPath.Parent())
+	err := files.MoveOrCopyFile(dotfilePath.Str(), symlinkPath.Str(), true)
+	change.DoneMoving(err == nil, dotfilePath)
+	if err != nil {
 		return err
 	}
 	files.CleanupEmptyDir(dotfilePath.Parent(), dotfilesDir)
@@ -0,0 +1,45 @@
+package status
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+)
+
+func PrintStatus() {
+	dotfilesDir := common.FindDotfilesDir()
+	config := config.FromDotfilesDir(dotfilesDir)
+
+	cacheKey := cache.ComputeCacheKey(dotfilesDir, config.TargetDir)
+	cache := cache.Load()
+	installedFilesCache := cache.GetEntry(cacheKey)
+
+	log.Printlnf("Dotfiles directory: %s", dotfilesDir)
+	if len(config.Layers) > 0 {
+		log.Printlnf("Lower layers: %s", strings.Join(config.Layers, ", "))
+	}
+	log.Printlnf("Target directory: %s", config.TargetDir)
+	log.Printlnf("Hostname: %s", common.GetHostname())
+	log.Printlnf("Installed links: %d", len(installedFilesCache.Links))
+	log.Printlnf("Generated files: %d", len(installedFilesCache.Generated))
+
+	enabledModules, disabledModules := installedFilesCache.GetModuleOverrides()
+	printList("Modules enabled in this machine", enabledModules)
+	printList("Modules disabled in this machine", disabledModules)
+	printList("Ignored in this machine", installedFilesCache.IgnoredTargets)
+}
+
+func printList(title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	sorted := slices.Sorted(slices.Values(items))
+	log.Printlnf("\n%s:", title)
+	for _, item := range sorted {
+		log.Printlnf("  %s", item)
+	}
+}
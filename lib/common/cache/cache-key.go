@@ -2,6 +2,7 @@ package cache
 
 import (
 	"path/filepath"
+	"strings"
 
 	. "github.com/pol-rivero/doot/lib/types"
 )
@@ -9,3 +10,40 @@ import (
 func ComputeCacheKey(dotfilesDir AbsolutePath, targetDir string) string {
 	return dotfilesDir.Str() + string(filepath.ListSeparator) + targetDir
 }
+
+// Opposite of ComputeCacheKey
+func SplitCacheKey(cacheKey string) (dotfilesDir AbsolutePath, targetDir string) {
+	dotfilesDirStr, targetDir := SplitCacheKeyString(cacheKey, filepath.ListSeparator)
+	return NewAbsolutePath(dotfilesDirStr), targetDir
+}
+
+// Both paths are absolute, so they are separated by the first list separator followed by the start of an absolute path:
+// "/dots:/home" on Unix, "C:\dots;C:\home" on Windows (where the list separator is ';'). If there is no such separator,
+// targetDir is empty.
+func SplitCacheKeyString(cacheKey string, listSeparator rune) (dotfilesDir string, targetDir string) {
+	windows := listSeparator == ';'
+	for i, char := range cacheKey {
+		if char == listSeparator && startsWithRoot(cacheKey[i+1:], windows) {
+			return cacheKey[:i], cacheKey[i+1:]
+		}
+	}
+	return cacheKey, ""
+}
+
+func startsWithRoot(path string, windows bool) bool {
+	if strings.HasPrefix(path, "/") {
+		return true
+	}
+	if !windows {
+		return false
+	}
+	if strings.HasPrefix(path, `\`) {
+		return true
+	}
+	// Volume name, such as "C:\"
+	return len(path) >= 3 && isAsciiLetter(path[0]) && path[1] == ':' && (path[2] == '\\' || path[2] == '/')
+}
+
+func isAsciiLetter(char byte) bool {
+	return ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
@@ -3,16 +3,18 @@ package cache
 import (
 	"os"
 	"path"
+	"slices"
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 const CURRENT_CACHE_VERSION uint32 = 2
 
 func Load() DootCache {
-	fileContents, err := os.ReadFile(getCachePath())
+	fileContents, err := filesystem.ReadFile(getCachePath())
 	if err != nil {
 		log.Info("Cache read error: %v, creating new cache", err)
 		return DootCache{
@@ -49,8 +51,15 @@ func (cache *DootCache) Save() {
 		return
 	}
 
-	err = os.WriteFile(getCachePath(), marshalledData, 0644)
+	// Write to a temporary file first, so that an interruption never leaves a half-written cache
+	cachePath := getCachePath()
+	tempPath := cachePath + common.DOOT_BACKUP_EXT
+	err = filesystem.WriteFile(tempPath, marshalledData, 0644)
+	if err == nil {
+		err = filesystem.Rename(tempPath, cachePath)
+	}
 	if err != nil {
+		filesystem.Remove(tempPath)
 		log.Error("Error saving cache file: %v", err)
 	}
 }
@@ -70,6 +79,16 @@ func (cache *DootCache) GetEntry(cacheKey string) *InstalledFilesCache {
 	return newEntry.InstalledFiles
 }
 
+// Like GetEntry, but returns nil instead of creating the entry if it doesn't exist
+func (cache *DootCache) FindEntry(cacheKey string) *InstalledFilesCache {
+	for _, entry := range cache.Entries {
+		if entry.CacheKey == cacheKey {
+			return entry.InstalledFiles
+		}
+	}
+	return nil
+}
+
 func (filesCache *InstalledFilesCache) GetLinks() SymlinkCollection {
 	links := NewSymlinkCollection(len(filesCache.Links))
 	for _, link := range filesCache.Links {
@@ -78,30 +97,159 @@ func (filesCache *InstalledFilesCache) GetLinks() SymlinkCollection {
 	return links
 }
 
+// Keeps the layer of the links that were already in the cache
 func (filesCache *InstalledFilesCache) SetLinks(links SymlinkCollection) {
+	previousLayers := filesCache.GetLinkLayers()
 	filesCache.Links = make([]*InstalledFile, 0, links.Len())
 	for path, content := range links.Iter() {
 		filesCache.Links = append(filesCache.Links, &InstalledFile{
 			Path:    path.Str(),
 			Content: content.Str(),
+			Layer:   previousLayers[path],
+		})
+	}
+}
+
+// Returns a map of link path -> name of the layer it was installed from. Links installed without layers are not included.
+func (filesCache *InstalledFilesCache) GetLinkLayers() map[AbsolutePath]string {
+	layers := make(map[AbsolutePath]string)
+	for _, link := range filesCache.Links {
+		if link.Layer != "" {
+			layers[NewAbsolutePath(link.Path)] = link.Layer
+		}
+	}
+	return layers
+}
+
+func (filesCache *InstalledFilesCache) SetLinkLayers(layers map[AbsolutePath]string) {
+	for _, link := range filesCache.Links {
+		link.Layer = layers[NewAbsolutePath(link.Path)]
+	}
+}
+
+// Returns a map of generated file path -> hash of the contents that doot wrote to it
+func (filesCache *InstalledFilesCache) GetGeneratedFiles() map[AbsolutePath]string {
+	generated := make(map[AbsolutePath]string, len(filesCache.Generated))
+	for _, file := range filesCache.Generated {
+		generated[NewAbsolutePath(file.Path)] = file.Hash
+	}
+	return generated
+}
+
+func (filesCache *InstalledFilesCache) SetGeneratedFiles(generated map[AbsolutePath]string) {
+	filesCache.Generated = make([]*GeneratedFile, 0, len(generated))
+	for path, hash := range generated {
+		filesCache.Generated = append(filesCache.Generated, &GeneratedFile{
+			Path: path.Str(),
+			Hash: hash,
 		})
 	}
 }
 
+// Returns a map of generated file path -> dotfiles it was generated from. Files generated before the sources were
+// recorded are not included.
+func (filesCache *InstalledFilesCache) GetGeneratedSources() map[AbsolutePath][]AbsolutePath {
+	sources := make(map[AbsolutePath][]AbsolutePath)
+	for _, file := range filesCache.Generated {
+		if len(file.Sources) == 0 {
+			continue
+		}
+		paths := make([]AbsolutePath, 0, len(file.Sources))
+		for _, source := range file.Sources {
+			paths = append(paths, NewAbsolutePath(source))
+		}
+		sources[NewAbsolutePath(file.Path)] = paths
+	}
+	return sources
+}
+
+func (filesCache *InstalledFilesCache) SetGeneratedSources(sources map[AbsolutePath][]AbsolutePath) {
+	for _, file := range filesCache.Generated {
+		file.Sources = make([]string, 0, len(sources[NewAbsolutePath(file.Path)]))
+		for _, source := range sources[NewAbsolutePath(file.Path)] {
+			file.Sources = append(file.Sources, source.Str())
+		}
+	}
+}
+
+// Returns the modules that have been enabled or disabled in this machine with 'doot module enable/disable'
+func (filesCache *InstalledFilesCache) GetModuleOverrides() (enabled []string, disabled []string) {
+	return filesCache.EnabledModules, filesCache.DisabledModules
+}
+
+func (filesCache *InstalledFilesCache) SetModuleOverride(name string, enabled bool) {
+	filesCache.EnabledModules = slices.DeleteFunc(filesCache.EnabledModules, func(n string) bool { return n == name })
+	filesCache.DisabledModules = slices.DeleteFunc(filesCache.DisabledModules, func(n string) bool { return n == name })
+	if enabled {
+		filesCache.EnabledModules = append(filesCache.EnabledModules, name)
+	} else {
+		filesCache.DisabledModules = append(filesCache.DisabledModules, name)
+	}
+}
+
+// Returns the targets that have been excluded in this machine with 'doot ignore'
+func (filesCache *InstalledFilesCache) GetIgnoredTargets() []AbsolutePath {
+	ignored := make([]AbsolutePath, 0, len(filesCache.IgnoredTargets))
+	for _, target := range filesCache.IgnoredTargets {
+		ignored = append(ignored, NewAbsolutePath(target))
+	}
+	return ignored
+}
+
+// Returns false if the target was already in the requested state
+func (filesCache *InstalledFilesCache) SetTargetIgnored(target AbsolutePath, ignored bool) bool {
+	isIgnored := slices.Contains(filesCache.IgnoredTargets, target.Str())
+	if isIgnored == ignored {
+		return false
+	}
+	if ignored {
+		filesCache.IgnoredTargets = append(filesCache.IgnoredTargets, target.Str())
+	} else {
+		filesCache.IgnoredTargets = slices.DeleteFunc(filesCache.IgnoredTargets, func(t string) bool { return t == target.Str() })
+	}
+	return true
+}
+
 func getCachePath() string {
+	return path.Join(GetCacheDir(), "doot-cache.bin")
+}
+
+// Returns the directory that contains the cache file, creating it if needed
+func GetCacheDir() string {
 	cacheDir := getCacheContainingDir()
-	err := os.MkdirAll(cacheDir, 0755)
+	err := filesystem.MkdirAll(cacheDir, 0755)
 	if err != nil {
 		log.Fatal("Error creating cache directory: %v", err)
 	}
-	return path.Join(cacheDir, "doot-cache.bin")
+	return cacheDir
+}
+
+var cacheDirOverride string
+
+// Used by the --target-dir flag, so that installing into an alternate root doesn't modify the cache of the real home.
+// $DOOT_CACHE_DIR still takes precedence.
+func SetCacheDirOverride(dir string) {
+	cacheDirOverride = dir
+}
+
+var explicitCacheDir string
+
+// Used when doot is embedded in another program. Takes precedence over $DOOT_CACHE_DIR. An empty string removes it.
+func SetExplicitCacheDir(dir string) {
+	explicitCacheDir = dir
 }
 
 func getCacheContainingDir() string {
+	if explicitCacheDir != "" {
+		return explicitCacheDir
+	}
 	cacheDir := os.Getenv(common.ENV_DOOT_CACHE_DIR)
 	if cacheDir != "" {
 		return cacheDir
 	}
+	if cacheDirOverride != "" {
+		return cacheDirOverride
+	}
 
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+// Human-readable representation of the cache, used by 'doot cache export --json' and 'doot cache import'
+type jsonCache struct {
+	Version uint32      `json:"version"`
+	Entries []jsonEntry `json:"entries"`
+}
+
+type jsonEntry struct {
+	DotfilesDir     string          `json:"dotfiles_dir"`
+	TargetDir       string          `json:"target_dir"`
+	Links           []jsonLink      `json:"links"`
+	GeneratedFiles  []jsonGenerated `json:"generated_files"`
+	EnabledModules  []string        `json:"enabled_modules"`
+	DisabledModules []string        `json:"disabled_modules"`
+	IgnoredTargets  []string        `json:"ignored_targets"`
+}
+
+type jsonLink struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+	Layer  string `json:"layer,omitempty"`
+}
+
+type jsonGenerated struct {
+	Path    string   `json:"path"`
+	Hash    string   `json:"hash"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+func (cache *DootCache) MarshalJson() ([]byte, error) {
+	result := jsonCache{
+		Version: cache.Version,
+		Entries: make([]jsonEntry, 0, len(cache.Entries)),
+	}
+	for _, entry := range cache.Entries {
+		dotfilesDir, targetDir := SplitCacheKey(entry.CacheKey)
+		if targetDir == "" {
+			// It couldn't be imported back
+			log.Warning("Skipping the cache entry %s, its key is not valid", entry.CacheKey)
+			continue
+		}
+		files := entry.InstalledFiles
+		jsonEntry := jsonEntry{
+			DotfilesDir:     dotfilesDir.Str(),
+			TargetDir:       targetDir,
+			Links:           make([]jsonLink, 0, len(files.Links)),
+			GeneratedFiles:  make([]jsonGenerated, 0, len(files.Generated)),
+			EnabledModules:  nonNil(files.EnabledModules),
+			DisabledModules: nonNil(files.DisabledModules),
+			IgnoredTargets:  nonNil(files.IgnoredTargets),
+		}
+		for _, link := range files.Links {
+			jsonEntry.Links = append(jsonEntry.Links, jsonLink{Path: link.Path, Target: link.Content, Layer: link.Layer})
+		}
+		for _, generated := range files.Generated {
+			jsonEntry.GeneratedFiles = append(jsonEntry.GeneratedFiles, jsonGenerated{Path: generated.Path, Hash: generated.Hash, Sources: generated.Sources})
+		}
+		result.Entries = append(result.Entries, jsonEntry)
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+func (cache *DootCache) UnmarshalJson(data []byte) error {
+	var parsed jsonCache
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	if parsed.Version != CURRENT_CACHE_VERSION {
+		return fmt.Errorf("unsupported cache version %d (expected %d)", parsed.Version, CURRENT_CACHE_VERSION)
+	}
+	cache.Version = parsed.Version
+	cache.Entries = make([]*CacheEntry, 0, len(parsed.Entries))
+	for _, entry := range parsed.Entries {
+		if entry.DotfilesDir == "" || entry.TargetDir == "" {
+			return fmt.Errorf("cache entries must have a dotfiles_dir and a target_dir")
+		}
+		files := &InstalledFilesCache{
+			Links:           make([]*InstalledFile, 0, len(entry.Links)),
+			Generated:       make([]*GeneratedFile, 0, len(entry.GeneratedFiles)),
+			EnabledModules:  entry.EnabledModules,
+			DisabledModules: entry.DisabledModules,
+			IgnoredTargets:  entry.IgnoredTargets,
+		}
+		for _, link := range entry.Links {
+			files.Links = append(files.Links, &InstalledFile{Path: link.Path, Content: link.Target, Layer: link.Layer})
+		}
+		for _, generated := range entry.GeneratedFiles {
+			files.Generated = append(files.Generated, &GeneratedFile{Path: generated.Path, Hash: generated.Hash, Sources: generated.Sources})
+		}
+		cache.Entries = append(cache.Entries, &CacheEntry{
+			CacheKey:       ComputeCacheKey(NewAbsolutePath(entry.DotfilesDir), entry.TargetDir),
+			InstalledFiles: files,
+		})
+	}
+	return nil
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -1,25 +1,60 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
-	"github.com/pelletier/go-toml/v2"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/glob_collection"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 type Config struct {
-	TargetDir           string            `toml:"target_dir"`
-	ExcludeFiles        []string          `toml:"exclude_files"`
-	IncludeFiles        []string          `toml:"include_files"`
-	ExploreExcludedDirs bool              `toml:"explore_excluded_dirs"`
-	ImplicitDot         bool              `toml:"implicit_dot"`
-	ImplicitDotIgnore   []string          `toml:"implicit_dot_ignore"`
-	DiffCommand         string            `toml:"diff_command"`
-	UseHardlinks        bool              `toml:"use_hardlinks"`
-	Hosts               map[string]string `toml:"hosts"`
+	TargetDir           string              `toml:"target_dir"`
+	ExcludeFiles        []string            `toml:"exclude_files"`
+	IncludeFiles        []string            `toml:"include_files"`
+	Rules               []string            `toml:"rules"`
+	ExploreExcludedDirs bool                `toml:"explore_excluded_dirs"`
+	FollowRepoSymlinks  bool                `toml:"follow_repo_symlinks"`
+	GitTrackedOnly      bool                `toml:"git_tracked_only"`
+	GitIncludeUntracked bool                `toml:"git_include_untracked"`
+	ImplicitDot         bool                `toml:"implicit_dot"`
+	ImplicitDotIgnore   []string            `toml:"implicit_dot_ignore"`
+	ImplicitDotRules    []string            `toml:"implicit_dot_rules"`
+	Transforms          []TransformConfig   `toml:"transforms"`
+	DiffCommand         string              `toml:"diff_command"`
+	UseHardlinks        bool                `toml:"use_hardlinks"`
+	Hosts               map[string]string   `toml:"hosts"`
+	HostGroups          map[string][]string `toml:"host_groups"`
+	Modules             map[string]string   `toml:"modules"`
+	AutoModules         bool                `toml:"auto_modules"`
+	EnabledModules      []string            `toml:"enabled_modules"`
+	HostModules         map[string][]string `toml:"host_modules"`
+	Vars                map[string]string   `toml:"vars"`
+	Layers              []string            `toml:"layers"`
+}
+
+const (
+	TRANSFORM_DOT_PREFIX   = "dot_prefix"
+	TRANSFORM_STRIP_MARKER = "strip_marker"
+	TRANSFORM_REGEX        = "regex"
+)
+
+type TransformConfig struct {
+	Type               string `toml:"type"`
+	Prefix             string `toml:"prefix,omitempty"`
+	Marker             string `toml:"marker,omitempty"`
+	Pattern            string `toml:"pattern,omitempty"`
+	Replacement        string `toml:"replacement,omitempty"`
+	ReversePattern     string `toml:"reverse_pattern,omitempty"`
+	ReverseReplacement string `toml:"reverse_replacement,omitempty"`
 }
 
 func DefaultConfig() Config {
@@ -31,45 +66,215 @@ func DefaultConfig() Config {
 		TargetDir:           homedir,
 		ExcludeFiles:        []string{"**/.*", "LICENSE", "README.md"},
 		IncludeFiles:        []string{},
+		Rules:               []string{},
 		ExploreExcludedDirs: false,
+		FollowRepoSymlinks:  false,
+		GitTrackedOnly:      false,
+		GitIncludeUntracked: false,
 		ImplicitDot:         true,
 		ImplicitDotIgnore:   []string{},
+		ImplicitDotRules:    []string{},
+		Transforms:          []TransformConfig{},
 		DiffCommand:         "diff --unified --color=always",
 		UseHardlinks:        false,
 		Hosts:               map[string]string{},
+		HostGroups:          map[string][]string{},
+		Modules:             map[string]string{},
+		AutoModules:         false,
+		EnabledModules:      []string{},
+		HostModules:         map[string][]string{},
+		Vars:                map[string]string{},
+		Layers:              []string{},
 	}
 }
 
 func FromFile(path AbsolutePath) Config {
-	config := DefaultConfig()
-	fileContents, err := os.ReadFile(path.Str())
-	if err != nil {
+	return FromFiles(path)
+}
+
+// Reads the base config file and applies the overlays on top of it, in order. Overlays that don't exist are skipped.
+// See mergeLayer for the merge semantics. Exits if any of the files is invalid.
+func FromFiles(basePath AbsolutePath, overlayPaths ...AbsolutePath) Config {
+	result := Load(basePath, overlayPaths...)
+	if len(result.Files) == 0 {
 		log.Info("Config file not found or unaccessible, using default config")
-		return config
 	}
-	err = toml.Unmarshal(fileContents, &config)
+	for _, file := range result.Files {
+		log.Info("Using config file %s", file)
+	}
+	if len(result.Problems) > 0 {
+		for _, problem := range result.Problems {
+			log.Error("%s", problem)
+		}
+		log.Fatal("Invalid config. Fix the errors above or run 'doot config validate' for details.")
+	}
+	return result.Config
+}
+
+var targetDirOverride string
+
+// Used by the --target-dir flag. Takes precedence over the target_dir of the config files. An empty string removes the override.
+func SetTargetDirOverride(dir string) {
+	if dir == "" {
+		targetDirOverride = ""
+		return
+	}
+	absDir, err := filesystem.Abs(dir)
 	if err != nil {
-		log.Error("Error parsing config file: %v", err)
+		log.Fatal("Invalid target directory %s: %v", dir, err)
 	}
-	verifyConfig(&config)
-	return config
+	targetDirOverride = absDir
+}
+
+var configOverride *Config
+var configOverrideDir AbsolutePath
+
+// Used when doot is embedded in another program: the config of dotfilesDir is not read from its config files. Pass nil
+// to remove the override.
+func SetConfigOverride(dotfilesDir AbsolutePath, config *Config) {
+	configOverride = config
+	configOverrideDir = dotfilesDir
 }
 
 func FromDotfilesDir(dotfilesDir AbsolutePath) Config {
-	return FromFile(dotfilesDir.Join("doot").Join("config.toml"))
+	if configOverride != nil && dotfilesDir == configOverrideDir {
+		return fromOverride()
+	}
+	basePath, overlays := configPaths(dotfilesDir)
+	return FromFiles(basePath, overlays...)
+}
+
+func fromOverride() Config {
+	config := configOverride.Clone()
+	problems := verifyConfig(&config)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			log.Error("%s", problem)
+		}
+		log.Fatal("Invalid config")
+	}
+	if targetDirOverride != "" {
+		config.TargetDir = targetDirOverride
+	}
+	return config
+}
+
+// Returns doot/config.toml, and the overlays doot/config.<hostname>.toml and doot/config.local.toml (which shouldn't be committed)
+func configPaths(dotfilesDir AbsolutePath) (AbsolutePath, []AbsolutePath) {
+	configDir := dotfilesDir.Join("doot")
+	overlays := make([]AbsolutePath, 0, 2)
+	if hostname := common.GetHostname(); hostname != "" {
+		overlays = append(overlays, configDir.Join("config."+hostname+".toml"))
+	}
+	overlays = append(overlays, configDir.Join(LOCAL_CONFIG_FILE))
+	return configDir.Join("config.toml"), overlays
+}
+
+// Returns a deep copy of the config, so that verifying it doesn't modify the original
+func (config *Config) Clone() Config {
+	clone := *config
+	clone.ExcludeFiles = slices.Clone(config.ExcludeFiles)
+	clone.IncludeFiles = slices.Clone(config.IncludeFiles)
+	clone.Rules = slices.Clone(config.Rules)
+	clone.ImplicitDotIgnore = slices.Clone(config.ImplicitDotIgnore)
+	clone.ImplicitDotRules = slices.Clone(config.ImplicitDotRules)
+	clone.Transforms = slices.Clone(config.Transforms)
+	clone.Hosts = maps.Clone(config.Hosts)
+	clone.HostGroups = cloneListMap(config.HostGroups)
+	clone.Modules = maps.Clone(config.Modules)
+	clone.EnabledModules = slices.Clone(config.EnabledModules)
+	clone.HostModules = cloneListMap(config.HostModules)
+	clone.Vars = maps.Clone(config.Vars)
+	clone.Layers = slices.Clone(config.Layers)
+	return clone
+}
+
+func cloneListMap(values map[string][]string) map[string][]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string][]string, len(values))
+	for key, list := range values {
+		result[key] = slices.Clone(list)
+	}
+	return result
+}
+
+// Returns the ordered include/exclude rules. If `rules` is not set, they are translated from `exclude_files` and `include_files`.
+func (config *Config) FilterRules() []string {
+	if len(config.Rules) > 0 {
+		return config.Rules
+	}
+	return glob_collection.RulesFromExcludeInclude(config.ExcludeFiles, config.IncludeFiles)
 }
 
-func verifyConfig(config *Config) {
-	config.TargetDir = filepath.Clean(os.ExpandEnv(config.TargetDir))
+func verifyConfig(config *Config) []Problem {
+	problems := expandVariables(config)
+	config.TargetDir = filepath.Clean(config.TargetDir)
 	if !filepath.IsAbs(config.TargetDir) {
-		log.Fatal("Invalid config: 'target_dir = %s', must be an absolute path", config.TargetDir)
+		problems = append(problems, Problem{Message: fmt.Sprintf("'target_dir = %s' must be an absolute path", config.TargetDir)})
+	}
+	config.DiffCommand = strings.TrimSpace(config.DiffCommand)
+	for i, transform := range config.Transforms {
+		if err := verifyTransform(transform); err != nil {
+			problems = append(problems, Problem{Message: fmt.Sprintf("'transforms[%d]': %v", i, err)})
+		}
+	}
+	return problems
+}
+
+func verifyTransform(transform TransformConfig) error {
+	switch transform.Type {
+	case TRANSFORM_DOT_PREFIX:
+		return nil
+	case TRANSFORM_STRIP_MARKER:
+		if transform.Marker == "" {
+			return fmt.Errorf("'marker' is required for %s transforms", TRANSFORM_STRIP_MARKER)
+		}
+		return nil
+	case TRANSFORM_REGEX:
+		if _, err := regexp.Compile(transform.Pattern); err != nil || transform.Pattern == "" {
+			return fmt.Errorf("invalid 'pattern' %q: %v", transform.Pattern, err)
+		}
+		if _, err := regexp.Compile(transform.ReversePattern); err != nil {
+			return fmt.Errorf("invalid 'reverse_pattern' %q: %v", transform.ReversePattern, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q, expected one of: %s, %s, %s", transform.Type, TRANSFORM_DOT_PREFIX, TRANSFORM_STRIP_MARKER, TRANSFORM_REGEX)
 	}
-	for _, implicitDotIgnore := range config.ImplicitDotIgnore {
-		if strings.ContainsRune(implicitDotIgnore, filepath.Separator) {
-			topLevelDir := RelativePath(implicitDotIgnore).TopLevelDir()
-			log.Fatal("Invalid config. 'implicit_dot_ignore -> %s' must be a top-level file or directory. Consider adding '%s' instead",
-				implicitDotIgnore, topLevelDir)
+}
+
+func expandVariables(config *Config) []Problem {
+	e := newExpander(config.Vars)
+	config.TargetDir = e.expand("target_dir", config.TargetDir)
+	config.DiffCommand = e.expand("diff_command", config.DiffCommand)
+	config.ExcludeFiles = e.expandList("exclude_files", config.ExcludeFiles)
+	config.IncludeFiles = e.expandList("include_files", config.IncludeFiles)
+	config.Rules = e.expandList("rules", config.Rules)
+	config.ImplicitDotIgnore = e.expandList("implicit_dot_ignore", config.ImplicitDotIgnore)
+	config.ImplicitDotRules = e.expandList("implicit_dot_rules", config.ImplicitDotRules)
+	config.Hosts = e.expandMapValues("hosts", config.Hosts)
+	if config.HostGroups != nil {
+		hostGroups := make(map[string][]string, len(config.HostGroups))
+		for group, patterns := range config.HostGroups {
+			hostGroups[group] = e.expandList("host_groups."+group, patterns)
 		}
+		config.HostGroups = hostGroups
+	}
+	config.Modules = e.expandMapValues("modules", config.Modules)
+	config.Layers = e.expandList("layers", config.Layers)
+	// Environment variables are not expanded in transforms, because "$1" refers to a regex group
+	config.Transforms = slices.Clone(config.Transforms)
+	for i := range config.Transforms {
+		transform := &config.Transforms[i]
+		key := fmt.Sprintf("transforms[%d]", i)
+		transform.Prefix = e.expandVarsOnly(key+".prefix", transform.Prefix)
+		transform.Marker = e.expandVarsOnly(key+".marker", transform.Marker)
+		transform.Pattern = e.expandVarsOnly(key+".pattern", transform.Pattern)
+		transform.Replacement = e.expandVarsOnly(key+".replacement", transform.Replacement)
+		transform.ReversePattern = e.expandVarsOnly(key+".reverse_pattern", transform.ReversePattern)
+		transform.ReverseReplacement = e.expandVarsOnly(key+".reverse_replacement", transform.ReverseReplacement)
 	}
-	config.DiffCommand = strings.TrimSpace(os.ExpandEnv(config.DiffCommand))
+	return e.problems
 }
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+const LOCAL_CONFIG_FILE = "config.local.toml"
+
+// A key with this suffix ("exclude_files+") appends its list to the one from the lower layers, instead of replacing it
+const APPEND_SUFFIX = "+"
+
+// Returns false if the file doesn't exist. If the file can't be parsed, the returned layer is nil.
+func readRawConfig(path AbsolutePath) (map[string]any, []Problem, bool) {
+	fileContents, err := filesystem.ReadFile(path.Str())
+	if err != nil {
+		return nil, nil, false
+	}
+	layer := make(map[string]any)
+	err = toml.Unmarshal(fileContents, &layer)
+	if err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, column := decodeErr.Position()
+			return nil, []Problem{{path, line, column, trimTomlPrefix(decodeErr.Error())}}, true
+		}
+		return nil, []Problem{{File: path, Message: trimTomlPrefix(err.Error())}}, true
+	}
+	return layer, checkLayer(path, fileContents), true
+}
+
+// The default config, as if it was read from a file. This allows the first layer to append to the default lists.
+func defaultRawConfig() map[string]any {
+	defaultBytes, err := toml.Marshal(DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	layer := make(map[string]any)
+	err = toml.Unmarshal(defaultBytes, &layer)
+	if err != nil {
+		panic(err)
+	}
+	return layer
+}
+
+// Applies the upper layer on top of the lower one:
+//   - Tables (such as [hosts]) are merged recursively, so an upper layer can add or replace single entries.
+//   - Lists and other values are replaced, unless the key ends with APPEND_SUFFIX.
+func mergeLayer(lower, upper map[string]any, problems *[]Problem) map[string]any {
+	for key, upperValue := range upper {
+		if listKey, isAppend := strings.CutSuffix(key, APPEND_SUFFIX); isAppend {
+			lower[listKey] = appendToList(listKey, lower[listKey], upperValue, problems)
+			continue
+		}
+		lowerTable, lowerIsTable := lower[key].(map[string]any)
+		upperTable, upperIsTable := upperValue.(map[string]any)
+		if lowerIsTable && upperIsTable {
+			lower[key] = mergeLayer(lowerTable, upperTable, problems)
+		} else {
+			lower[key] = upperValue
+		}
+	}
+	return lower
+}
+
+func appendToList(key string, lowerValue, upperValue any, problems *[]Problem) any {
+	upperList, upperIsList := upperValue.([]any)
+	if !upperIsList {
+		*problems = append(*problems, Problem{Message: fmt.Sprintf("'%s%s' must be a list", key, APPEND_SUFFIX)})
+		return lowerValue
+	}
+	if lowerValue == nil {
+		return upperList
+	}
+	lowerList, lowerIsList := lowerValue.([]any)
+	if !lowerIsList {
+		*problems = append(*problems, Problem{Message: fmt.Sprintf("can't append to '%s' because it's not a list", key)})
+		return lowerValue
+	}
+	result := make([]any, 0, len(lowerList)+len(upperList))
+	result = append(result, lowerList...)
+	return append(result, upperList...)
+}
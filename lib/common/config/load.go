@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+type Problem struct {
+	// Empty if the problem comes from the combination of all the config files
+	File AbsolutePath
+	// 0 if the position is unknown
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.File == "" {
+		return "Invalid config: " + p.Message
+	}
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+type LoadResult struct {
+	Config Config
+	// The config files that were found, in the order they were applied
+	Files    []AbsolutePath
+	Problems []Problem
+}
+
+// Same as FromFiles, but returns the problems found in the config files instead of exiting
+func Load(basePath AbsolutePath, overlayPaths ...AbsolutePath) LoadResult {
+	result := LoadResult{
+		Files:    make([]AbsolutePath, 0, len(overlayPaths)+1),
+		Problems: make([]Problem, 0),
+	}
+	merged := defaultRawConfig()
+	for _, path := range append([]AbsolutePath{basePath}, overlayPaths...) {
+		layer, problems, found := readRawConfig(path)
+		if !found {
+			continue
+		}
+		result.Files = append(result.Files, path)
+		result.Problems = append(result.Problems, problems...)
+		if layer != nil {
+			merged = mergeLayer(merged, layer, &result.Problems)
+		}
+	}
+
+	result.Config = DefaultConfig()
+	mergedBytes, err := toml.Marshal(merged)
+	if err == nil {
+		err = toml.Unmarshal(mergedBytes, &result.Config)
+	}
+	// Type errors are already reported with their position when checking each file
+	if err != nil && len(result.Problems) == 0 {
+		result.Problems = append(result.Problems, Problem{Message: trimTomlPrefix(err.Error())})
+	}
+	result.Problems = append(result.Problems, verifyConfig(&result.Config)...)
+	if targetDirOverride != "" {
+		result.Config.TargetDir = targetDirOverride
+	}
+	return result
+}
+
+func LoadFromDotfilesDir(dotfilesDir AbsolutePath) LoadResult {
+	basePath, overlays := configPaths(dotfilesDir)
+	return Load(basePath, overlays...)
+}
+
+// Decodes the file into a Config, reporting unknown keys and values of the wrong type
+func checkLayer(path AbsolutePath, contents []byte) []Problem {
+	problems := make([]Problem, 0)
+	decoder := toml.NewDecoder(strings.NewReader(string(contents)))
+	decoder.DisallowUnknownFields()
+	var config Config
+	err := decoder.Decode(&config)
+
+	var strictErr *toml.StrictMissingError
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &strictErr) {
+		for _, keyErr := range strictErr.Errors {
+			key := keyErr.Key()
+			if isAppendKey(key) {
+				continue
+			}
+			line, column := keyErr.Position()
+			problems = append(problems, Problem{path, line, column, fmt.Sprintf("unknown key '%s'", strings.Join(key, "."))})
+		}
+	} else if errors.As(err, &decodeErr) {
+		line, column := decodeErr.Position()
+		problems = append(problems, Problem{path, line, column, trimTomlPrefix(decodeErr.Error())})
+	} else if err != nil {
+		problems = append(problems, Problem{File: path, Message: trimTomlPrefix(err.Error())})
+	}
+	return problems
+}
+
+// Top-level keys like "exclude_files+" are valid if they refer to a list
+func isAppendKey(key []string) bool {
+	if len(key) != 1 {
+		return false
+	}
+	listKey, isAppend := strings.CutSuffix(key[0], APPEND_SUFFIX)
+	return isAppend && listKeys()[listKey]
+}
+
+func listKeys() map[string]bool {
+	keys := make(map[string]bool)
+	configType := reflect.TypeFor[Config]()
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+		if field.Type.Kind() == reflect.Slice {
+			keys[tomlKey(field)] = true
+		}
+	}
+	return keys
+}
+
+func tomlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	return name
+}
+
+func trimTomlPrefix(message string) string {
+	return strings.TrimPrefix(message, "toml: ")
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+const SCHEMA_URL = "https://json-schema.org/draft-07/schema#"
+
+// Generates a JSON schema of the config file from the Config struct, with the default values. It can be used by
+// editors (for example, with the "#:schema ./schema.json" directive of Taplo) for completion and validation.
+func JSONSchema() []byte {
+	defaults := DefaultConfig()
+	defaults.TargetDir = "$HOME"
+	defaultValues := reflect.ValueOf(defaults)
+
+	properties := make(map[string]any)
+	configType := reflect.TypeFor[Config]()
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+		key := tomlKey(field)
+		property := typeSchema(field.Type)
+		property["default"] = defaultValues.Field(i).Interface()
+		properties[key] = property
+		if field.Type.Kind() == reflect.Slice {
+			properties[key+APPEND_SUFFIX] = typeSchema(field.Type)
+		}
+	}
+	schema := map[string]any{
+		"$schema":              SCHEMA_URL,
+		"title":                "doot config",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	schemaBytes, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return schemaBytes
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		for i := range t.NumField() {
+			properties[tomlKey(t.Field(i))] = typeSchema(t.Field(i).Type)
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	default:
+		panic("Unsupported config field type: " + t.String())
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+)
+
+// Expands "{{name}}" (a variable from [vars] or a built-in) and "$NAME" / "${NAME}" (an environment variable).
+// "$$" is a literal "$". Undefined variables are reported as problems instead of expanding to an empty string.
+type expander struct {
+	vars     map[string]string
+	problems []Problem
+}
+
+func newExpander(vars map[string]string) *expander {
+	e := &expander{
+		vars:     builtinVars(),
+		problems: make([]Problem, 0),
+	}
+	for name, value := range vars {
+		// Variables can refer to environment variables and built-ins, but not to other variables
+		e.vars[name] = e.expandEnv("vars."+name, value)
+	}
+	return e
+}
+
+func builtinVars() map[string]string {
+	username := os.Getenv("USER")
+	if currentUser, err := user.Current(); err == nil {
+		username = currentUser.Username
+	}
+	return map[string]string{
+		"hostname": common.GetHostname(),
+		"os":       runtime.GOOS,
+		"arch":     runtime.GOARCH,
+		"user":     username,
+	}
+}
+
+func (e *expander) expand(key, value string) string {
+	return e.expandVars(key, value, e.expandEnv)
+}
+
+// Only expands "{{name}}", leaving "$" untouched (for regular expressions)
+func (e *expander) expandVarsOnly(key, value string) string {
+	return e.expandVars(key, value, func(key, text string) string { return text })
+}
+
+func (e *expander) expandVars(key, value string, expandEnv func(key, text string) string) string {
+	var result strings.Builder
+	for {
+		start := strings.Index(value, "{{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(value[start:], "}}")
+		if end == -1 {
+			e.addProblem("unterminated '{{' in '%s'", key)
+			break
+		}
+		result.WriteString(expandEnv(key, value[:start]))
+		name := strings.TrimSpace(value[start+2 : start+end])
+		if varValue, exists := e.vars[name]; exists {
+			result.WriteString(varValue)
+		} else {
+			e.addProblem("undefined variable '%s' in '%s'", name, key)
+		}
+		value = value[start+end+2:]
+	}
+	result.WriteString(expandEnv(key, value))
+	return result.String()
+}
+
+func (e *expander) expandEnv(key, value string) string {
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i == len(value)-1 {
+			result.WriteByte(value[i])
+			continue
+		}
+		name, length := parseEnvName(value[i+1:])
+		switch {
+		case value[i+1] == '$':
+			result.WriteByte('$')
+			i++
+		case name == "":
+			result.WriteByte('$')
+		default:
+			if envValue, exists := os.LookupEnv(name); exists {
+				result.WriteString(envValue)
+			} else {
+				e.addProblem("environment variable '%s' is not set, but it's used in '%s'", name, key)
+			}
+			i += length
+		}
+	}
+	return result.String()
+}
+
+// Parses "NAME..." or "{NAME}...", returns the name and the number of bytes it takes
+func parseEnvName(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end == -1 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+	length := 0
+	for length < len(s) && isEnvNameChar(s[length], length == 0) {
+		length++
+	}
+	return s[:length], length
+}
+
+func isEnvNameChar(c byte, isFirst bool) bool {
+	isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	return isLetter || (!isFirst && c >= '0' && c <= '9')
+}
+
+// Returns a new list, values is not modified
+func (e *expander) expandList(key string, values []string) []string {
+	if values == nil {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = e.expand(fmt.Sprintf("%s[%d]", key, i), value)
+	}
+	return result
+}
+
+// Returns a new map, values is not modified
+func (e *expander) expandMapValues(key string, values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		result[name] = e.expand(key+"."+name, value)
+	}
+	return result
+}
+
+func (e *expander) addProblem(format string, v ...any) {
+	e.problems = append(e.problems, Problem{Message: fmt.Sprintf(format, v...)})
+}
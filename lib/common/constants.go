@@ -5,6 +5,9 @@ import "path/filepath"
 const DOOT_CRYPT_EXT_WITHOUT_DOT string = "doot-crypt"
 const DOOT_CRYPT_EXT string = "." + DOOT_CRYPT_EXT_WITHOUT_DOT
 const DOOT_BACKUP_EXT string = ".doot-backup"
+const DOOT_FRAGMENTS_EXT string = ".doot-fragments"
+const DOOT_PATCH_EXT string = ".doot-patch"
+const DOOT_IGNORE_FILE string = ".dootignore"
 const HOOKS_DIR string = "doot" + string(filepath.Separator) + "hooks"
 const CUSTOM_COMMANDS_DIR string = "doot" + string(filepath.Separator) + "commands"
 
@@ -12,4 +15,6 @@ const IGNORE_HIDDEN_FILES_GLOB string = "**/.*"
 
 const ENV_DOOT_DIR string = "DOOT_DIR"
 const ENV_DOOT_CACHE_DIR string = "DOOT_CACHE_DIR"
+const ENV_DOOT_HOSTNAME string = "DOOT_HOSTNAME"
 const ENV_XDG_DATA_HOME string = "XDG_DATA_HOME"
+const ENV_XDG_CONFIG_HOME string = "XDG_CONFIG_HOME"
@@ -7,6 +7,8 @@ import (
 
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 func FindDotfilesDir() AbsolutePath {
@@ -21,38 +23,96 @@ func FindDotfilesDir() AbsolutePath {
 	return NewAbsolutePath(dotfilesDir)
 }
 
+var dotfilesDirOverride string
+
+// Used by the --dotfiles-dir flag and bootstrap. Takes precedence over $DOOT_DIR and the default locations.
+// $DOOT_DIR is also updated, so that hooks and custom commands use the same directory. An empty string removes the override.
+func SetDotfilesDirOverride(dir string) {
+	if dir == "" {
+		dotfilesDirOverride = ""
+		return
+	}
+	absDir, err := filesystem.Abs(dir)
+	if err != nil {
+		log.Fatal("Invalid dotfiles directory %s: %v", dir, err)
+	}
+	dotfilesDirOverride = absDir
+	err = os.Setenv(ENV_DOOT_DIR, absDir)
+	if err != nil {
+		log.Fatal("Error setting DOOT_DIR environment variable: %v", err)
+	}
+}
+
+// Like SetDotfilesDirOverride, but $DOOT_DIR is only set for the hooks and custom commands, the environment of the
+// process is not modified. Used when doot is embedded in another program.
+func SetEmbeddedDotfilesDir(dir string) {
+	if dir == "" {
+		dotfilesDirOverride = ""
+		utils.SetCommandEnv(ENV_DOOT_DIR, "")
+		return
+	}
+	absDir, err := filesystem.Abs(dir)
+	if err != nil {
+		log.Fatal("Invalid dotfiles directory %s: %v", dir, err)
+	}
+	dotfilesDirOverride = absDir
+	utils.SetCommandEnv(ENV_DOOT_DIR, absDir)
+}
+
 func findDotfilesDir() (string, error) {
+	if dotfilesDirOverride != "" {
+		fileInfo, err := filesystem.Stat(dotfilesDirOverride)
+		if err != nil || !fileInfo.IsDir() {
+			return "", fmt.Errorf("the dotfiles directory %s does not exist or is not a directory", dotfilesDirOverride)
+		}
+		return dotfilesDirOverride, nil
+	}
+
 	// 1. Try $DOOT_DIR if defined
 	if dootDir := os.Getenv(ENV_DOOT_DIR); dootDir != "" {
-		fileInfo, err := os.Stat(dootDir)
+		fileInfo, err := filesystem.Stat(dootDir)
 		if err == nil && fileInfo.IsDir() {
 			return dootDir, nil
 		}
 	}
 
+	// 2. Try the directory selected in $XDG_CONFIG_HOME/doot/doot.toml
+	userConfig, err := readUserConfig()
+	if err != nil {
+		return "", err
+	}
+	if selectedDir := userConfig.SelectedDir(); selectedDir != "" {
+		fileInfo, err := filesystem.Stat(selectedDir)
+		if err == nil && fileInfo.IsDir() {
+			return selectedDir, nil
+		}
+		log.Warning("The dotfiles directory %s (set in %s) does not exist", selectedDir, UserConfigPath())
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("error retrieving home directory: %v", err)
 	}
 
-	// 2. Try $XDG_DATA_HOME/dotfiles (or ~/.local/share/dotfiles)
+	// 3. Try $XDG_DATA_HOME/dotfiles (or ~/.local/share/dotfiles)
 	xdgDataHome := os.Getenv(ENV_XDG_DATA_HOME)
 	if xdgDataHome == "" {
 		xdgDataHome = filepath.Join(homeDir, ".local", "share")
 	}
 	dotfilesDir := filepath.Join(xdgDataHome, "dotfiles")
-	if fileInfo, err := os.Stat(dotfilesDir); err == nil && fileInfo.IsDir() {
+	if fileInfo, err := filesystem.Stat(dotfilesDir); err == nil && fileInfo.IsDir() {
 		return dotfilesDir, nil
 	}
 
-	// 3. Try ~/.dotfiles
+	// 4. Try ~/.dotfiles
 	dotfilesDir = filepath.Join(homeDir, ".dotfiles")
-	if fileInfo, err := os.Stat(dotfilesDir); err == nil && fileInfo.IsDir() {
+	if fileInfo, err := filesystem.Stat(dotfilesDir); err == nil && fileInfo.IsDir() {
 		return dotfilesDir, nil
 	}
 
-	err = fmt.Errorf("none of the candidate dotfiles directories exist:\n  - $DOOT_DIR = '%s'\n  - %s\n  - %s",
+	err = fmt.Errorf("none of the candidate dotfiles directories exist:\n  - $DOOT_DIR = '%s'\n  - %s = '%s'\n  - %s\n  - %s",
 		os.Getenv(ENV_DOOT_DIR),
+		UserConfigPath(), userConfig.SelectedDir(),
 		filepath.Join(xdgDataHome, "dotfiles"),
 		filepath.Join(homeDir, ".dotfiles"))
 	return "", err
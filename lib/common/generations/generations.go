@@ -0,0 +1,259 @@
+package generations
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Older generations are deleted, along with the stored files that only they refer to
+const KEPT_GENERATIONS int = 50
+
+const STORE_DIR string = "store"
+
+// The links and generated files installed by a successful install, so that they can be restored later
+type Generation struct {
+	Number int       `json:"number"`
+	Time   time.Time `json:"time"`
+	// Commit of the dotfiles directory (empty if it's not a git repository), and whether it had uncommitted changes
+	Commit    string          `json:"commit,omitempty"`
+	Dirty     bool            `json:"dirty,omitempty"`
+	Hardlinks bool            `json:"hardlinks,omitempty"`
+	Links     []Link          `json:"links"`
+	Generated []GeneratedFile `json:"generated_files"`
+}
+
+type Link struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+	Layer  string `json:"layer,omitempty"`
+	// Only for hardlinks: hash of the contents, which are kept in the store
+	Hash string `json:"hash,omitempty"`
+}
+
+type GeneratedFile struct {
+	Path string      `json:"path"`
+	Hash string      `json:"hash"`
+	Mode os.FileMode `json:"mode"`
+}
+
+// Records the links and generated files of the cache entry as a new generation, unless they are the same as in the
+// latest one. Returns the number of the latest generation.
+func Record(cacheKey string, dotfilesDir AbsolutePath, installedFiles *cache.InstalledFilesCache, hardlinks bool) int {
+	generation := Generation{
+		Time:      time.Now(),
+		Hardlinks: hardlinks,
+		Links:     make([]Link, 0, len(installedFiles.Links)),
+		Generated: make([]GeneratedFile, 0, len(installedFiles.Generated)),
+	}
+	generation.Commit, generation.Dirty = CurrentCommit(dotfilesDir)
+	dir := generationsDir(cacheKey)
+	for _, link := range installedFiles.Links {
+		entry := Link{Path: link.Path, Source: link.Content, Layer: link.Layer}
+		if hardlinks {
+			// The contents of a hardlink are lost when the dotfile is replaced (for example, by git)
+			entry.Hash = storeFile(dir, NewAbsolutePath(link.Content))
+		}
+		generation.Links = append(generation.Links, entry)
+	}
+	for _, generated := range installedFiles.Generated {
+		entry := GeneratedFile{Path: generated.Path, Hash: generated.Hash, Mode: 0644}
+		if fileInfo, err := filesystem.Stat(generated.Path); err == nil {
+			entry.Mode = fileInfo.Mode().Perm()
+		}
+		if storeFile(dir, NewAbsolutePath(generated.Path)) != generated.Hash {
+			log.Warning("%s has been modified or removed since it was generated, it won't be restored by 'doot rollback'", generated.Path)
+			entry.Hash = ""
+		}
+		generation.Generated = append(generation.Generated, entry)
+	}
+	sortGeneration(&generation)
+
+	existing := List(cacheKey)
+	if len(existing) > 0 {
+		latest := existing[len(existing)-1]
+		if latest.sameAs(&generation) {
+			return latest.Number
+		}
+		generation.Number = latest.Number + 1
+	} else {
+		generation.Number = 1
+	}
+	if err := save(dir, &generation); err != nil {
+		log.Error("Failed to save generation %d: %v", generation.Number, err)
+		return generation.Number
+	}
+	log.Info("Recorded generation %d", generation.Number)
+	prune(dir, append(existing, generation))
+	return generation.Number
+}
+
+// Returns the generations of the cache entry, from oldest to newest
+func List(cacheKey string) []Generation {
+	dir := generationsDir(cacheKey)
+	dirEntries, err := filesystem.ReadDir(dir)
+	if err != nil {
+		return []Generation{}
+	}
+	generations := make([]Generation, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if _, isGeneration := parseFileName(dirEntry.Name()); !isGeneration {
+			continue
+		}
+		data, err := filesystem.ReadFile(filepath.Join(dir, dirEntry.Name()))
+		var generation Generation
+		if err == nil {
+			err = json.Unmarshal(data, &generation)
+		}
+		if err != nil {
+			log.Warning("Skipping malformed generation %s: %v", dirEntry.Name(), err)
+			continue
+		}
+		generations = append(generations, generation)
+	}
+	slices.SortFunc(generations, func(a, b Generation) int { return a.Number - b.Number })
+	return generations
+}
+
+// Returns the contents of a file kept in the store
+func ReadStoredFile(cacheKey string, hash string) ([]byte, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("the file was not stored")
+	}
+	return filesystem.ReadFile(StoredFilePath(cacheKey, hash).Str())
+}
+
+func StoredFilePath(cacheKey string, hash string) AbsolutePath {
+	return NewAbsolutePath(filepath.Join(generationsDir(cacheKey), STORE_DIR, hash))
+}
+
+// Returns the contents of a file of the dotfiles directory at the given commit
+func ReadFileAtCommit(dotfilesDir AbsolutePath, commit string, file AbsolutePath) ([]byte, error) {
+	relativePath, err := filepath.Rel(dotfilesDir.Str(), file.Str())
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return nil, fmt.Errorf("%s is not inside %s", file, dotfilesDir)
+	}
+	return utils.RunCommandOutput(dotfilesDir, "git", "show", commit+":"+filepath.ToSlash(relativePath))
+}
+
+// Returns the current commit of the dotfiles directory ("" if it's not a git repository), and whether it has uncommitted
+// changes
+func CurrentCommit(dotfilesDir AbsolutePath) (string, bool) {
+	output, err := utils.RunCommandOutput(dotfilesDir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", false
+	}
+	status, err := utils.RunCommandOutput(dotfilesDir, "git", "status", "--porcelain")
+	return strings.TrimSpace(string(output)), err == nil && len(strings.TrimSpace(string(status))) > 0
+}
+
+func ShortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// Copies the file into the store and returns its hash, or "" if it can't be read
+func storeFile(dir string, path AbsolutePath) string {
+	contents, err := filesystem.ReadFile(path.Str())
+	if err != nil {
+		log.Info("Could not store %s: %v", path, err)
+		return ""
+	}
+	hash := files.HashContents(contents)
+	storedPath := filepath.Join(dir, STORE_DIR, hash)
+	if _, err := filesystem.Stat(storedPath); err == nil {
+		return hash
+	}
+	if err := filesystem.MkdirAll(filepath.Dir(storedPath), 0755); err != nil {
+		log.Warning("Could not store %s: %v", path, err)
+		return ""
+	}
+	if err := files.WriteFileAtomic(NewAbsolutePath(storedPath), contents, 0600); err != nil {
+		log.Warning("Could not store %s: %v", path, err)
+		return ""
+	}
+	return hash
+}
+
+func save(dir string, generation *Generation) error {
+	data, err := json.MarshalIndent(generation, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := filesystem.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return files.WriteFileAtomic(NewAbsolutePath(filepath.Join(dir, fileName(generation.Number))), data, 0644)
+}
+
+// Deletes the oldest generations and the stored files that are no longer referenced
+func prune(dir string, generations []Generation) {
+	if len(generations) <= KEPT_GENERATIONS {
+		return
+	}
+	removed, kept := generations[:len(generations)-KEPT_GENERATIONS], generations[len(generations)-KEPT_GENERATIONS:]
+	for _, generation := range removed {
+		log.Info("Removing generation %d", generation.Number)
+		filesystem.Remove(filepath.Join(dir, fileName(generation.Number)))
+	}
+	referenced := make(map[string]bool)
+	for _, generation := range kept {
+		for _, link := range generation.Links {
+			referenced[link.Hash] = true
+		}
+		for _, generated := range generation.Generated {
+			referenced[generated.Hash] = true
+		}
+	}
+	storedFiles, err := filesystem.ReadDir(filepath.Join(dir, STORE_DIR))
+	if err != nil {
+		return
+	}
+	for _, storedFile := range storedFiles {
+		if !referenced[storedFile.Name()] {
+			filesystem.Remove(filepath.Join(dir, STORE_DIR, storedFile.Name()))
+		}
+	}
+}
+
+func (g *Generation) sameAs(other *Generation) bool {
+	return g.Commit == other.Commit && g.Dirty == other.Dirty && g.Hardlinks == other.Hardlinks &&
+		slices.Equal(g.Links, other.Links) && slices.Equal(g.Generated, other.Generated)
+}
+
+func sortGeneration(generation *Generation) {
+	slices.SortFunc(generation.Links, func(a, b Link) int { return strings.Compare(a.Path, b.Path) })
+	slices.SortFunc(generation.Generated, func(a, b GeneratedFile) int { return strings.Compare(a.Path, b.Path) })
+}
+
+// Each cache entry (dotfiles directory and target directory) has its own generations
+func generationsDir(cacheKey string) string {
+	return filepath.Join(cache.GetCacheDir(), "generations", files.HashContents([]byte(cacheKey))[:16])
+}
+
+func fileName(number int) string {
+	return fmt.Sprintf("%d.json", number)
+}
+
+func parseFileName(name string) (int, bool) {
+	numberStr, isJson := strings.CutSuffix(name, ".json")
+	if !isJson {
+		return 0, false
+	}
+	number, err := strconv.Atoi(numberStr)
+	return number, err == nil
+}
@@ -0,0 +1,140 @@
+package glob_collection
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+type IgnoreVerdict int
+
+const (
+	NOT_MATCHED IgnoreVerdict = iota
+	IGNORED
+	UNIGNORED
+)
+
+type ignoreRule struct {
+	glob    glob.Glob
+	negated bool
+	dirOnly bool
+}
+
+// The rules of a single ignore file, which follow the gitignore syntax. Patterns are relative to the directory that
+// contains the ignore file.
+type IgnoreFile struct {
+	baseDir RelativePath
+	rules   []ignoreRule
+}
+
+// baseDir is the directory that contains the ignore file, relative to the scanned root ("" for the root itself)
+func NewIgnoreFile(baseDir RelativePath, contents string) IgnoreFile {
+	rules := make([]ignoreRule, 0, 8)
+	for line := range strings.Lines(contents) {
+		rule, ok := parseIgnoreLine(line)
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return IgnoreFile{baseDir, rules}
+}
+
+// Returns the verdict of the last rule that matches the path (relative to the scanned root)
+func (f *IgnoreFile) Match(path RelativePath, isDir bool) IgnoreVerdict {
+	relativePath := path.Str()
+	if f.baseDir != "" {
+		var isInside bool
+		relativePath, isInside = strings.CutPrefix(relativePath, f.baseDir.Str()+string(filepath.Separator))
+		if !isInside {
+			return NOT_MATCHED
+		}
+	}
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		rule := f.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.glob.Match(relativePath) {
+			if rule.negated {
+				return UNIGNORED
+			}
+			return IGNORED
+		}
+	}
+	return NOT_MATCHED
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	line = trimUnescapedTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// A pattern with a separator at the beginning or in the middle is relative to the ignore file.
+	// Otherwise, it can match at any level below it.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if !anchored {
+		line = "**/" + line
+	}
+	pattern := preprocessPattern(filepath.FromSlash(escapeBraces(line)))
+	g, err := glob.Compile(pattern, filepath.Separator)
+	if err != nil {
+		log.Warning("Ignoring invalid ignore pattern '%s': %v", line, err)
+		return ignoreRule{}, false
+	}
+	rule.glob = g
+	return rule, true
+}
+
+func trimUnescapedTrailingSpaces(line string) string {
+	trimmed := strings.TrimRight(line, " ")
+	if len(trimmed) < len(line) && strings.HasSuffix(trimmed, `\`) {
+		// Keep one escaped space
+		return trimmed[:len(trimmed)-1] + " "
+	}
+	return trimmed
+}
+
+// Braces have no special meaning in gitignore, but they are alternatives in our glob syntax
+func escapeBraces(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, "{", `\{`)
+	pattern = strings.ReplaceAll(pattern, "}", `\}`)
+	return pattern
+}
+
+// A stack of ignore files, from the outermost directory to the innermost one
+type IgnoreStack []*IgnoreFile
+
+func (s IgnoreStack) Push(ignoreFile *IgnoreFile) IgnoreStack {
+	// Clip the capacity so that sibling directories don't overwrite each other's entries
+	return append(s[:len(s):len(s)], ignoreFile)
+}
+
+// Rules in deeper ignore files take precedence over the ones in their parent directories
+func (s IgnoreStack) Match(path RelativePath, isDir bool) IgnoreVerdict {
+	for i := len(s) - 1; i >= 0; i-- {
+		verdict := s[i].Match(path, isDir)
+		if verdict != NOT_MATCHED {
+			return verdict
+		}
+	}
+	return NOT_MATCHED
+}
@@ -0,0 +1,116 @@
+package glob_collection
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+const NEGATION_PREFIX = "!"
+
+// Since ** should also match 0-depth directories, we make all instances of **/ optional
+const SUPER_GLOB = "**" + string(filepath.Separator)
+const SUPER_GLOB_REPLACEMENT = "{,**" + string(filepath.Separator) + "}"
+
+func preprocessPattern(pattern string) string {
+	return strings.ReplaceAll(pattern, SUPER_GLOB, SUPER_GLOB_REPLACEMENT)
+}
+
+type rule struct {
+	glob     glob.Glob // nil for the hidden files rule, which is matched by name for performance
+	negated  bool
+	fixedDir string // Literal part of the pattern, up to the last separator before the first wildcard
+}
+
+// An ordered list of exclusion rules. Patterns prefixed with "!" re-include the matching paths. When several rules
+// match the same path, the last one wins. Paths that don't match any rule inherit the verdict of their parent directory.
+type RuleList struct {
+	rules []rule
+}
+
+func NewRuleList(patterns []string) RuleList {
+	rules := make([]rule, 0, len(patterns))
+	for _, pattern := range patterns {
+		r := rule{}
+		if strings.HasPrefix(pattern, NEGATION_PREFIX) {
+			r.negated = true
+			pattern = pattern[len(NEGATION_PREFIX):]
+		}
+		if pattern != common.IGNORE_HIDDEN_FILES_GLOB {
+			g, err := glob.Compile(preprocessPattern(pattern), filepath.Separator)
+			if err != nil {
+				log.Warning("Ignoring invalid glob pattern '%s': %v", pattern, err)
+				continue
+			}
+			r.glob = g
+		}
+		r.fixedDir = fixedDirOf(pattern)
+		rules = append(rules, r)
+	}
+	return RuleList{rules}
+}
+
+// Translates the legacy exclude_files and include_files lists into an equivalent rule list
+func RulesFromExcludeInclude(excludeFiles, includeFiles []string) []string {
+	rules := make([]string, 0, len(excludeFiles)+len(includeFiles))
+	rules = append(rules, excludeFiles...)
+	for _, includePattern := range includeFiles {
+		rules = append(rules, NEGATION_PREFIX+includePattern)
+	}
+	return rules
+}
+
+// Returns whether the path is excluded. fileName must be the last component of the path.
+func (rl *RuleList) IsExcluded(path RelativePath, fileName string, parentExcluded bool) bool {
+	return rl.Evaluate(path, fileName, parentExcluded)
+}
+
+// Returns true if the last matching rule is a regular pattern, false if it's a negated pattern, or defaultValue if no
+// rule matches. fileName must be the last component of the path.
+func (rl *RuleList) Evaluate(path RelativePath, fileName string, defaultValue bool) bool {
+	for i := len(rl.rules) - 1; i >= 0; i-- {
+		if rl.rules[i].matches(path, fileName) {
+			return !rl.rules[i].negated
+		}
+	}
+	return defaultValue
+}
+
+// Returns whether a negated rule explicitly refers to a path inside the given directory, which means that the
+// directory needs to be explored even if it's excluded.
+func (rl *RuleList) MayIncludeBelow(dir RelativePath) bool {
+	dirPrefix := dir.Str() + string(filepath.Separator)
+	for _, r := range rl.rules {
+		if r.negated && strings.HasPrefix(r.fixedDir+string(filepath.Separator), dirPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rl *RuleList) Len() int {
+	return len(rl.rules)
+}
+
+func (r *rule) matches(path RelativePath, fileName string) bool {
+	if r.glob == nil {
+		return fileName[0] == '.'
+	}
+	return r.glob.Match(path.Str())
+}
+
+func fixedDirOf(pattern string) string {
+	wildcardIndex := strings.IndexAny(pattern, "*?[{\\")
+	if wildcardIndex == -1 {
+		return pattern
+	}
+	lastSeparator := strings.LastIndex(pattern[:wildcardIndex], string(filepath.Separator))
+	if lastSeparator == -1 {
+		return ""
+	}
+	return pattern[:lastSeparator]
+}
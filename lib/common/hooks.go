@@ -6,11 +6,12 @@ import (
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 func RunHooks(dotfilesDir AbsolutePath, hookName string) {
 	hookDir := dotfilesDir.Join(HOOKS_DIR).Join(hookName)
-	dirEntries, err := os.ReadDir(hookDir.Str())
+	dirEntries, err := filesystem.ReadDir(hookDir.Str())
 	if err != nil {
 		log.Info("No hooks found for %s", hookName)
 		return
@@ -21,6 +22,11 @@ func RunHooks(dotfilesDir AbsolutePath, hookName string) {
 			continue
 		}
 		hookPath := hookDir.Join(entry.Name())
+		if !filesystem.IsReal() {
+			// Hooks run on the real disk, so they would not see the changes
+			log.Printlnf("Skipping %s hook %s, the changes are not being written to disk", hookName, hookPath)
+			continue
+		}
 		err := utils.RunCommand(dotfilesDir, hookPath.Str())
 		if err != nil {
 			handleError(err, hookName, hookPath)
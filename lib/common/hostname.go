@@ -0,0 +1,19 @@
+package common
+
+import (
+	"os"
+
+	"github.com/pol-rivero/doot/lib/common/log"
+)
+
+func GetHostname() string {
+	if hostname := os.Getenv(ENV_DOOT_HOSTNAME); hostname != "" {
+		return hostname
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Error("Failed to get hostname: %v", err)
+		return ""
+	}
+	return hostname
+}
@@ -0,0 +1,140 @@
+package hosts
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+)
+
+const GROUP_PREFIX = "@"
+
+// Host-specific directories are stacked in this order: group directories < pattern directories < exact hostname directories.
+const (
+	tierGroup = iota
+	tierPattern
+	tierExact
+)
+
+type HostDir struct {
+	Dir string
+	// Directories with a higher priority override the ones with a lower priority. The base dotfiles directory has priority 0.
+	Priority int
+}
+
+type hostEntry struct {
+	key  string
+	dir  string
+	tier int
+}
+
+// Returns the host-specific directories that apply to the given hostname, ordered from lowest to highest priority,
+// and the directories that belong to other hosts and should be ignored.
+func ResolveHostDirs(config *config.Config, hostname string) ([]HostDir, []string) {
+	matching := make([]hostEntry, 0, len(config.Hosts))
+	otherDirs := make([]string, 0, len(config.Hosts))
+	for key, dir := range config.Hosts {
+		tier, matches := matchHostKey(key, hostname, config.HostGroups)
+		if matches {
+			matching = append(matching, hostEntry{key, dir, tier})
+		} else {
+			otherDirs = append(otherDirs, dir)
+		}
+	}
+	slices.SortFunc(matching, func(a, b hostEntry) int {
+		if a.tier != b.tier {
+			return a.tier - b.tier
+		}
+		return strings.Compare(a.key, b.key)
+	})
+
+	activeDirs := make([]HostDir, 0, len(matching))
+	for _, entry := range matching {
+		if containsDir(activeDirs, entry.dir) {
+			continue
+		}
+		activeDirs = append(activeDirs, HostDir{
+			Dir:      entry.dir,
+			Priority: len(activeDirs) + 1,
+		})
+	}
+	ignoredDirs := make([]string, 0, len(otherDirs))
+	for _, dir := range otherDirs {
+		if !containsDir(activeDirs, dir) && !slices.Contains(ignoredDirs, dir) {
+			ignoredDirs = append(ignoredDirs, dir)
+		}
+	}
+	slices.Sort(ignoredDirs)
+	return activeDirs, ignoredDirs
+}
+
+// Returns true if the key of a [hosts] or [host_modules] entry (hostname, pattern or @group) applies to the given hostname
+func MatchesHostKey(config *config.Config, key, hostname string) bool {
+	_, matches := matchHostKey(key, hostname, config.HostGroups)
+	return matches
+}
+
+func matchHostKey(key, hostname string, groups map[string][]string) (int, bool) {
+	if groupName, isGroup := strings.CutPrefix(key, GROUP_PREFIX); isGroup {
+		patterns, exists := groups[groupName]
+		if !exists {
+			log.Warning("Host group '%s' is used in [hosts] but it's not defined in [host_groups]", groupName)
+			return tierGroup, false
+		}
+		for _, pattern := range patterns {
+			if matchesHostPattern(pattern, hostname) {
+				return tierGroup, true
+			}
+		}
+		return tierGroup, false
+	}
+	if isHostPattern(key) {
+		return tierPattern, matchesHostPattern(key, hostname)
+	}
+	return tierExact, key == hostname
+}
+
+// A hostname pattern can be an exact hostname, a glob ("ci-*", "*.corp.example") or a regex surrounded by slashes ("/^ci-[0-9]+$/").
+func matchesHostPattern(pattern, hostname string) bool {
+	if isRegexPattern(pattern) {
+		regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			log.Warning("Ignoring invalid hostname regex '%s': %v", pattern, err)
+			return false
+		}
+		return regex.MatchString(hostname)
+	}
+	if isGlobPattern(pattern) {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			log.Warning("Ignoring invalid hostname glob '%s': %v", pattern, err)
+			return false
+		}
+		return g.Match(hostname)
+	}
+	return pattern == hostname
+}
+
+func isHostPattern(pattern string) bool {
+	return isRegexPattern(pattern) || isGlobPattern(pattern)
+}
+
+func isRegexPattern(pattern string) bool {
+	return len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[{")
+}
+
+func containsDir(hostDirs []HostDir, dir string) bool {
+	for _, hostDir := range hostDirs {
+		if hostDir.Dir == dir {
+			return true
+		}
+	}
+	return false
+}
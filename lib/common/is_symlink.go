@@ -5,6 +5,7 @@ import (
 	"os"
 
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 func IsSymlink(fileInfo fs.FileInfo) bool {
@@ -16,6 +17,6 @@ func DirEntryIsSymlink(dirEntry fs.DirEntry) bool {
 }
 
 func IsSymlinkWithTarget(possiblySymlinkPath AbsolutePath, expectedTarget string) bool {
-	linkSource, err := os.Readlink(possiblySymlinkPath.Str())
+	linkSource, err := filesystem.Readlink(possiblySymlinkPath.Str())
 	return err == nil && linkSource == expectedTarget
 }
@@ -0,0 +1,317 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+const JOURNAL_FILE string = "journal.jsonl"
+const CACHE_BACKUP_FILE string = "doot-cache.bin"
+const UNDO_COMMAND string = "undo"
+
+// The backups of older operations are deleted, so they can no longer be undone
+const KEPT_BACKUPS int = 20
+
+type ActionType string
+
+const (
+	Created  ActionType = "created"
+	Modified ActionType = "modified"
+	Removed  ActionType = "removed"
+	Moved    ActionType = "moved"
+)
+
+// A change made to a single file. The fields describe the state of Path after the change, so that undo can check that
+// it hasn't been modified since.
+type Action struct {
+	Type ActionType `json:"type"`
+	Path string     `json:"path"`
+	// For moved files, the location before the move
+	Source string `json:"source,omitempty"`
+	// Path is a link to this file
+	Link     string `json:"link,omitempty"`
+	Hardlink bool   `json:"hardlink,omitempty"`
+	// Hash of the contents of Path, for files that are not links
+	Hash string `json:"hash,omitempty"`
+	// Copy of Path before the change, inside the backup directory of the operation
+	Backup string `json:"backup,omitempty"`
+	// Empty directories left behind when undoing are removed up to this directory
+	Root string `json:"root,omitempty"`
+}
+
+// A single invocation of a command that modified files
+type Operation struct {
+	Id      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Args    []string  `json:"args,omitempty"`
+	Actions []Action  `json:"actions,omitempty"`
+	// For undo operations, the id of the reverted operation
+	Undoes int `json:"undoes,omitempty"`
+	// Cache entry of the dotfiles directory and target directory of the command
+	CacheKey string `json:"cache_key,omitempty"`
+	// Other cache entries modified by the command (for example, the entries renamed by 'relocate')
+	OtherCacheKeys []string `json:"other_cache_keys,omitempty"`
+}
+
+var (
+	// Cleared by the signal handler from another goroutine, so it's only accessed through currentTransaction and
+	// setCurrentTransaction
+	current     *transaction
+	currentLock sync.Mutex
+)
+
+func currentTransaction() *transaction {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+	return current
+}
+
+func setCurrentTransaction(tx *transaction) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+	current = tx
+}
+
+// Starts recording the changes of a command. If the command fails fatally or is interrupted, the changes recorded so
+// far are rolled back. Nested calls (for example, 'add' running 'install') are recorded as part of the outermost command.
+func Begin(command string, args []string) {
+	if tx := currentTransaction(); tx != nil {
+		tx.depth++
+		return
+	}
+	dootCache := cache.Load()
+	snapshot, err := dootCache.MarshalBinary()
+	if err != nil {
+		log.Warning("Failed to back up the cache, the operation won't be undoable: %v", err)
+		return
+	}
+	setCurrentTransaction(newTransaction(&Operation{
+		Id:      nextId(),
+		Time:    time.Now(),
+		Command: command,
+		Args:    args,
+		Actions: []Action{},
+	}, snapshot))
+}
+
+// Appends the operation to the journal, if it changed any file
+func End() {
+	tx := currentTransaction()
+	if tx == nil {
+		return
+	}
+	if tx.depth > 0 {
+		tx.depth--
+		return
+	}
+	tx.lock.Lock()
+	if currentTransaction() != tx {
+		// Rolled back by the signal handler
+		tx.lock.Unlock()
+		return
+	}
+	setCurrentTransaction(nil)
+	tx.stop()
+	tx.lock.Unlock()
+	op := tx.op
+	if len(op.Actions) == 0 {
+		filesystem.RemoveAll(op.BackupDir())
+		return
+	}
+	if err := writeBackup(op, CACHE_BACKUP_FILE, tx.cacheSnapshot); err != nil {
+		log.Warning("Failed to back up the cache: %v", err)
+	}
+	Append(op)
+	pruneBackups(op.Id)
+}
+
+// Takes a new snapshot of the cache, for changes made outside of the operation that must not be rolled back
+func RefreshCacheSnapshot() {
+	tx := currentTransaction()
+	if tx == nil {
+		return
+	}
+	dootCache := cache.Load()
+	if snapshot, err := dootCache.MarshalBinary(); err == nil {
+		tx.lock.Lock()
+		tx.cacheSnapshot = snapshot
+		tx.lock.Unlock()
+	}
+}
+
+// Sets the cache entry that the recorded command modifies
+func SetCacheKey(cacheKey string) {
+	if tx := currentTransaction(); tx != nil {
+		tx.lock.Lock()
+		defer tx.lock.Unlock()
+		tx.op.CacheKey = cacheKey
+	}
+}
+
+// Adds a cache entry that the recorded command modifies, besides the one of SetCacheKey. Undoing the command restores
+// the entry as it was in the snapshot, or removes it if it didn't exist.
+func AddCacheKey(cacheKey string) {
+	tx := currentTransaction()
+	if tx == nil {
+		return
+	}
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	op := tx.op
+	if op.CacheKey == "" {
+		op.CacheKey = cacheKey
+	} else if op.CacheKey != cacheKey && !slices.Contains(op.OtherCacheKeys, cacheKey) {
+		op.OtherCacheKeys = append(op.OtherCacheKeys, cacheKey)
+	}
+}
+
+// Returns the cache entries modified by the operation, starting with the main one
+func (op *Operation) CacheKeys() []string {
+	if op.CacheKey == "" {
+		return op.OtherCacheKeys
+	}
+	return append([]string{op.CacheKey}, op.OtherCacheKeys...)
+}
+
+func Append(op *Operation) {
+	data, err := json.Marshal(op)
+	if err != nil {
+		log.Error("Failed to serialize the journal entry: %v", err)
+		return
+	}
+	if err := filesystem.MkdirAll(journalDir(), 0755); err != nil {
+		log.Error("Failed to create the journal directory: %v", err)
+		return
+	}
+	if err := filesystem.AppendFile(journalPath(), append(data, '\n'), 0644); err != nil {
+		log.Error("Failed to write the journal: %v", err)
+	}
+}
+
+// Records that the operation has been reverted, and deletes its backups
+func AppendUndo(undone *Operation) {
+	Append(&Operation{
+		Id:      nextId(),
+		Time:    time.Now(),
+		Command: UNDO_COMMAND,
+		Undoes:  undone.Id,
+	})
+	filesystem.RemoveAll(undone.BackupDir())
+}
+
+// Returns all the recorded operations, from oldest to newest
+func Load() []Operation {
+	data, err := filesystem.ReadFile(journalPath())
+	if os.IsNotExist(err) {
+		return []Operation{}
+	} else if err != nil {
+		log.Fatal("Failed to read the journal: %v", err)
+	}
+	operations := make([]Operation, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var op Operation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			log.Warning("Skipping malformed journal entry: %v", err)
+			continue
+		}
+		operations = append(operations, op)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal("Failed to read the journal: %v", err)
+	}
+	return operations
+}
+
+// Returns the ids of the operations that have been reverted with 'doot undo'
+func UndoneIds(operations []Operation) []int {
+	undone := make([]int, 0)
+	for _, op := range operations {
+		if op.Command == UNDO_COMMAND {
+			undone = append(undone, op.Undoes)
+		}
+	}
+	return undone
+}
+
+// Returns the most recent operation that has not been undone, or nil if there is none
+func LastUndoable(operations []Operation) *Operation {
+	undone := UndoneIds(operations)
+	for i := len(operations) - 1; i >= 0; i-- {
+		op := &operations[i]
+		if op.Command != UNDO_COMMAND && !slices.Contains(undone, op.Id) {
+			return op
+		}
+	}
+	return nil
+}
+
+func (op *Operation) BackupDir() string {
+	return filepath.Join(journalDir(), strconv.Itoa(op.Id))
+}
+
+func (op *Operation) BackupPath(name string) string {
+	return filepath.Join(op.BackupDir(), name)
+}
+
+// Returns the contents of the cache before the operation, or nil if they weren't backed up
+func (op *Operation) CacheSnapshot() []byte {
+	data, err := filesystem.ReadFile(op.BackupPath(CACHE_BACKUP_FILE))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func nextId() int {
+	operations := Load()
+	if len(operations) == 0 {
+		return 1
+	}
+	return operations[len(operations)-1].Id + 1
+}
+
+func writeBackup(op *Operation, name string, data []byte) error {
+	if err := filesystem.MkdirAll(op.BackupDir(), 0755); err != nil {
+		return err
+	}
+	return filesystem.WriteFile(op.BackupPath(name), data, 0600)
+}
+
+func pruneBackups(lastId int) {
+	entries, err := filesystem.ReadDir(journalDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, err := strconv.Atoi(entry.Name())
+		if err == nil && entry.IsDir() && id <= lastId-KEPT_BACKUPS {
+			log.Info("Removing the backups of operation %d", id)
+			filesystem.RemoveAll(filepath.Join(journalDir(), entry.Name()))
+		}
+	}
+}
+
+func journalDir() string {
+	return filepath.Join(cache.GetCacheDir(), "journal")
+}
+
+func journalPath() string {
+	return filepath.Join(journalDir(), JOURNAL_FILE)
+}
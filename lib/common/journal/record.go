@@ -0,0 +1,127 @@
+package journal
+
+import (
+	"strconv"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/log"
+	hardlink "github.com/pol-rivero/doot/lib/linkmode/hardlink"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// A change to a file that is about to be made. It does nothing if no command is being recorded.
+type Change struct {
+	tx      *transaction
+	action  *Action
+	existed bool
+}
+
+// Backs up the path (if it exists) before it's created, modified or removed. Call Done after making the change; an
+// interruption is not handled until then.
+func Prepare(path AbsolutePath, root AbsolutePath) *Change {
+	tx := currentTransaction()
+	if tx == nil {
+		return &Change{}
+	}
+	tx.beginChange()
+	change := &Change{tx: tx, action: &Action{Path: path.Str(), Root: root.Str()}}
+	if _, err := filesystem.Lstat(path.Str()); err != nil {
+		return change
+	}
+	change.existed = true
+	tx.backupCount++
+	backupName := strconv.Itoa(tx.backupCount)
+	if err := files.CopyFile(path.Str(), tx.op.BackupPath(backupName), true); err != nil {
+		log.Warning("Failed to back up %s, this change can't be undone: %v", path, err)
+		return change
+	}
+	change.action.Backup = backupName
+	return change
+}
+
+// Indicates that the path will be a link to the source. Needed to recognize hardlinks when undoing the change.
+func (c *Change) Linking(source AbsolutePath, isHardlink bool) *Change {
+	if c.action != nil {
+		c.action.Link = source.Str()
+		c.action.Hardlink = isHardlink
+	}
+	return c
+}
+
+// Records the change if it was successful, or discards the backup otherwise
+func (c *Change) Done(success bool) {
+	if c.action == nil {
+		return
+	}
+	defer c.tx.endChange()
+	if !success {
+		c.discard()
+		return
+	}
+	_, err := filesystem.Lstat(c.action.Path)
+	switch {
+	case err != nil:
+		c.action.Type = Removed
+	case c.existed:
+		c.action.Type = Modified
+	default:
+		c.action.Type = Created
+	}
+	c.record()
+}
+
+// Records that the file at source was moved to the path of the change
+func (c *Change) DoneMoving(success bool, source AbsolutePath) {
+	if c.action == nil {
+		return
+	}
+	defer c.tx.endChange()
+	if !success {
+		c.discard()
+		return
+	}
+	c.action.Type = Moved
+	c.action.Source = source.Str()
+	c.record()
+}
+
+// Returns true if the path is still in the state left by the action
+func (action *Action) IsUnchanged() bool {
+	fileInfo, err := filesystem.Lstat(action.Path)
+	if err != nil {
+		return false
+	}
+	if action.Hardlink {
+		return hardlink.IsHardlink(action.Path, action.Link)
+	}
+	if common.IsSymlink(fileInfo) {
+		content, err := filesystem.Readlink(action.Path)
+		return err == nil && action.Link == content
+	}
+	if action.Hash == "" {
+		return action.Link == ""
+	}
+	currentHash, err := files.HashFile(NewAbsolutePath(action.Path))
+	return err == nil && currentHash == action.Hash
+}
+
+func (c *Change) record() {
+	path := NewAbsolutePath(c.action.Path)
+	if fileInfo, err := filesystem.Lstat(path.Str()); err == nil && !c.action.Hardlink {
+		if common.IsSymlink(fileInfo) {
+			c.action.Link, _ = filesystem.Readlink(path.Str())
+		} else if fileInfo.Mode().IsRegular() {
+			c.action.Link = ""
+			c.action.Hash, _ = files.HashFile(path)
+		}
+	}
+	c.tx.op.Actions = append(c.tx.op.Actions, *c.action)
+}
+
+func (c *Change) discard() {
+	if c.action.Backup != "" {
+		filesystem.Remove(c.tx.op.BackupPath(c.action.Backup))
+	}
+}
@@ -0,0 +1,167 @@
+package journal
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Reverts the actions of the operation, from last to first. Returns the actions that could not be reverted.
+func Revert(op *Operation) []Action {
+	unreverted := make([]Action, 0)
+	for i := len(op.Actions) - 1; i >= 0; i-- {
+		if !revert(op, &op.Actions[i]) {
+			unreverted = append(unreverted, op.Actions[i])
+		}
+	}
+	return unreverted
+}
+
+// Restores the links and generated files of the cache entries with the given keys from the snapshot. Entries that
+// were not in the snapshot are emptied, or removed if they have no settings either. The other entries, and the settings
+// changed since the snapshot (such as the enabled modules), are kept. The first entry is updated to include the
+// actions that could not be reverted, so that the cache matches the files on disk.
+func RestoreCache(snapshot []byte, cacheKeys []string, unreverted []Action) {
+	if len(cacheKeys) == 0 {
+		return
+	}
+	var previous cache.DootCache
+	if snapshot == nil || previous.UnmarshalBinary(snapshot) != nil {
+		log.Warning("The cache could not be restored, run 'doot cache rebuild' to update it")
+		return
+	}
+	dootCache := cache.Load()
+	for _, cacheKey := range cacheKeys {
+		restoreEntry(&dootCache, &previous, cacheKey)
+	}
+	if entry := dootCache.FindEntry(cacheKeys[0]); entry != nil && len(unreverted) > 0 {
+		links := entry.GetLinks()
+		for _, action := range unreverted {
+			keepInCache(&links, &action)
+		}
+		entry.SetLinks(links)
+	}
+	dootCache.Save()
+}
+
+func restoreEntry(dootCache *cache.DootCache, previous *cache.DootCache, cacheKey string) {
+	previousFiles := previous.FindEntry(cacheKey)
+	installedFiles := dootCache.FindEntry(cacheKey)
+	switch {
+	case previousFiles != nil && installedFiles == nil:
+		dootCache.Entries = append(dootCache.Entries, &cache.CacheEntry{CacheKey: cacheKey, InstalledFiles: previousFiles})
+	case previousFiles != nil:
+		installedFiles.Links = previousFiles.Links
+		installedFiles.Generated = previousFiles.Generated
+	case installedFiles != nil && !hasSettings(installedFiles):
+		dootCache.Entries = slices.DeleteFunc(dootCache.Entries, func(e *cache.CacheEntry) bool { return e.CacheKey == cacheKey })
+	case installedFiles != nil:
+		installedFiles.Links = []*cache.InstalledFile{}
+		installedFiles.Generated = []*cache.GeneratedFile{}
+	}
+}
+
+func hasSettings(installedFiles *cache.InstalledFilesCache) bool {
+	return len(installedFiles.EnabledModules) > 0 || len(installedFiles.DisabledModules) > 0 || len(installedFiles.IgnoredTargets) > 0
+}
+
+func keepInCache(links *SymlinkCollection, action *Action) {
+	path := NewAbsolutePath(action.Path)
+	switch {
+	case action.Type == Moved:
+		links.Remove(path)
+	case action.Type == Removed:
+		if _, err := filesystem.Lstat(path.Str()); err != nil {
+			links.Remove(path)
+		}
+	case action.Link != "" && action.IsUnchanged():
+		links.Add(path, NewAbsolutePath(action.Link))
+	}
+}
+
+func revert(op *Operation, action *Action) bool {
+	path := NewAbsolutePath(action.Path)
+	switch action.Type {
+	case Created:
+		if !action.IsUnchanged() {
+			log.Warning("%s has been modified since it was created, leaving it untouched", path)
+			return false
+		}
+		log.Info("Removing %s", path)
+		return files.RemoveAndCleanup(path, cleanupRoot(action))
+	case Modified:
+		if !action.IsUnchanged() {
+			log.Warning("%s has been modified since, leaving it untouched", path)
+			return false
+		}
+		return restoreBackup(op, action)
+	case Removed:
+		if _, err := filesystem.Lstat(path.Str()); err == nil {
+			log.Warning("%s has been created again since it was removed, leaving it untouched", path)
+			return false
+		}
+		return restoreBackup(op, action)
+	case Moved:
+		return revertMove(op, action)
+	}
+	log.Warning("Unknown change '%s' to %s, skipping", action.Type, path)
+	return false
+}
+
+func revertMove(op *Operation, action *Action) bool {
+	path := NewAbsolutePath(action.Path)
+	source := NewAbsolutePath(action.Source)
+	if !action.IsUnchanged() {
+		log.Warning("%s has been modified since it was moved from %s, leaving it untouched", path, source)
+		return false
+	}
+	if _, err := filesystem.Lstat(source.Str()); err == nil {
+		log.Warning("Can't move %s back to %s because it already exists", path, source)
+		return false
+	}
+	if !files.EnsureParentDir(source) {
+		return false
+	}
+	log.Info("Moving %s -> %s", path, source)
+	if err := files.MoveOrCopyFile(path.Str(), source.Str(), false); err != nil {
+		log.Error("Failed to move %s to %s: %v", path, source, err)
+		return false
+	}
+	if action.Backup != "" {
+		return restoreBackup(op, action)
+	}
+	files.CleanupEmptyDir(path.Parent(), cleanupRoot(action))
+	return true
+}
+
+func restoreBackup(op *Operation, action *Action) bool {
+	path := NewAbsolutePath(action.Path)
+	if action.Backup == "" {
+		log.Warning("There is no backup of %s, leaving it untouched", path)
+		return false
+	}
+	if !files.EnsureParentDir(path) {
+		return false
+	}
+	log.Info("Restoring %s", path)
+	if err := filesystem.Rename(op.BackupPath(action.Backup), path.Str()); err != nil {
+		log.Error("Failed to restore %s: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// Avoids removing empty directories outside of the directory that contained the file
+func cleanupRoot(action *Action) AbsolutePath {
+	path := NewAbsolutePath(action.Path)
+	if action.Root == "" || !strings.HasPrefix(action.Path, action.Root+string(os.PathSeparator)) {
+		return path.Parent()
+	}
+	return NewAbsolutePath(action.Root)
+}
@@ -0,0 +1,143 @@
+package journal
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Exit code used when the command is interrupted by a signal (128 + SIGINT)
+const INTERRUPTED_EXIT_CODE int = 130
+
+var signalHandlingEnabled = true
+
+// Called after rolling back an interrupted command. Tests replace it to keep running.
+var ExitOnSignal = os.Exit
+
+// Whether SIGINT/SIGTERM roll back the operation in progress and exit. Disabled when doot is embedded in another
+// program, which handles its own signals.
+func SetSignalHandling(enabled bool) {
+	signalHandlingEnabled = enabled
+}
+
+// The operation being recorded. Its changes are rolled back if the command fails fatally or receives SIGINT/SIGTERM.
+type transaction struct {
+	op            *Operation
+	cacheSnapshot []byte
+	backupCount   int
+	depth         int
+
+	// Held while changes are being made, so that an interruption never rolls back a half-made change
+	lock           sync.Mutex
+	pendingChanges int
+	rollbackOnce   sync.Once
+	stopOnce       sync.Once
+	signals        chan os.Signal
+	done           chan struct{}
+}
+
+func newTransaction(op *Operation, cacheSnapshot []byte) *transaction {
+	tx := &transaction{
+		op:            op,
+		cacheSnapshot: cacheSnapshot,
+		signals:       make(chan os.Signal, 1),
+		done:          make(chan struct{}),
+	}
+	log.SetFatalHandler(tx.rollback)
+	if signalHandlingEnabled {
+		signal.Notify(tx.signals, os.Interrupt, syscall.SIGTERM)
+		go tx.handleSignals()
+	}
+	return tx
+}
+
+// Runs fn (for example, saving the cache) without letting an interruption roll back the operation halfway through it
+func Atomically(fn func()) {
+	tx := currentTransaction()
+	if tx == nil {
+		fn()
+		return
+	}
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	fn()
+}
+
+// Changes can be nested (for example, when adopting a file both the dotfile and the target are changed). Only called
+// from the main goroutine.
+func (tx *transaction) beginChange() {
+	if tx.pendingChanges == 0 {
+		tx.lock.Lock()
+	}
+	tx.pendingChanges++
+}
+
+func (tx *transaction) endChange() {
+	tx.pendingChanges--
+	if tx.pendingChanges == 0 {
+		tx.lock.Unlock()
+	}
+}
+
+func (tx *transaction) handleSignals() {
+	select {
+	case sig := <-tx.signals:
+		// Wait for the change in progress to finish, and don't let any other change start
+		tx.lock.Lock()
+		defer tx.lock.Unlock()
+		log.Error("Received %v, stopping", sig)
+		func() {
+			// Inside an engine, log.Fatal panics instead of exiting. The panic can't be caught by the engine from this
+			// goroutine, and the process exits anyway.
+			defer func() { recover() }()
+			tx.rollback()
+		}()
+		ExitOnSignal(INTERRUPTED_EXIT_CODE)
+	case <-tx.done:
+	}
+}
+
+// Reverts the recorded changes and restores the cache. The operation is not added to the journal.
+func (tx *transaction) rollback() {
+	tx.rollbackOnce.Do(func() {
+		currentLock.Lock()
+		if current == tx {
+			current = nil
+		}
+		currentLock.Unlock()
+		tx.stop()
+		op := tx.op
+		if len(op.Actions) == 0 {
+			filesystem.RemoveAll(op.BackupDir())
+			return
+		}
+		log.Printlnf("Rolling back %d %s...", len(op.Actions), pluralize(len(op.Actions), "change", "changes"))
+		unreverted := Revert(op)
+		RestoreCache(tx.cacheSnapshot, op.CacheKeys(), unreverted)
+		if len(unreverted) > 0 {
+			log.Warning("%d %s could not be rolled back. The backups of the modified files are in %s", len(unreverted), pluralize(len(unreverted), "change", "changes"), op.BackupDir())
+			return
+		}
+		filesystem.RemoveAll(op.BackupDir())
+		log.Printlnf("All changes have been rolled back")
+	})
+}
+
+func (tx *transaction) stop() {
+	tx.stopOnce.Do(func() {
+		signal.Stop(tx.signals)
+		close(tx.done)
+		log.SetFatalHandler(nil)
+	})
+}
+
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
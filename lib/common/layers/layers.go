@@ -0,0 +1,95 @@
+package layers
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// A dotfiles directory installed onto the target. When several layers map to the same target, the highest one wins.
+type Layer struct {
+	// Name of the repository registered with 'doot repo add', or the name of the directory
+	Name string
+	Dir  AbsolutePath
+	// The config of the layer. Only the settings that affect which files are installed and their names are used from
+	// lower layers; the target directory and link mode always come from the highest layer.
+	Config *config.Config
+}
+
+// Returns the layers declared in the 'layers' key of the config, followed by the dotfiles directory itself (the highest
+// layer). Without 'layers', it returns a single layer.
+func Resolve(dotfilesDir AbsolutePath, topConfig *config.Config) []Layer {
+	userConfig := common.LoadUserConfig()
+	result := make([]Layer, 0, len(topConfig.Layers)+1)
+	for _, entry := range topConfig.Layers {
+		dir, name := resolveEntry(entry, dotfilesDir, &userConfig)
+		if fileInfo, err := filesystem.Stat(dir.Str()); err != nil || !fileInfo.IsDir() {
+			log.Fatal("Layer '%s' (%s) does not exist or is not a directory", entry, dir)
+		}
+		for _, layer := range result {
+			if layer.Dir == dir {
+				log.Fatal("Layer %s is declared twice", dir)
+			}
+		}
+		if dir == dotfilesDir {
+			log.Fatal("The dotfiles directory %s cannot be a layer of itself", dir)
+		}
+		layerConfig := config.FromDotfilesDir(dir)
+		if len(layerConfig.Layers) > 0 {
+			log.Warning("Ignoring the 'layers' of %s, only the highest layer can declare layers", dir)
+		}
+		result = append(result, Layer{Name: name, Dir: dir, Config: &layerConfig})
+	}
+	result = append(result, Layer{
+		Name:   layerName(dotfilesDir, &userConfig),
+		Dir:    dotfilesDir,
+		Config: topConfig,
+	})
+	checkNotNested(result)
+	return result
+}
+
+// The files of a layer inside another one would also be installed as files of the enclosing layer
+func checkNotNested(layers []Layer) {
+	for _, inner := range layers {
+		for _, outer := range layers {
+			if strings.HasPrefix(inner.Dir.Str(), outer.Dir.Str()+string(filepath.Separator)) {
+				log.Fatal("Layer %s is inside layer %s. Layers can't be nested, move it outside of %s", inner.Dir, outer.Dir, outer.Dir)
+			}
+		}
+	}
+}
+
+// Returns the directories of all the layers, from lowest to highest
+func Dirs(layers []Layer) []AbsolutePath {
+	dirs := make([]AbsolutePath, 0, len(layers))
+	for _, layer := range layers {
+		dirs = append(dirs, layer.Dir)
+	}
+	return dirs
+}
+
+// An entry is the name of a registered repository, or a directory (relative to the dotfiles directory)
+func resolveEntry(entry string, dotfilesDir AbsolutePath, userConfig *common.UserConfig) (AbsolutePath, string) {
+	if registeredDir, isRegistered := userConfig.Repos[entry]; isRegistered {
+		return NewAbsolutePath(registeredDir), entry
+	}
+	dir := entry
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(dotfilesDir.Str(), dir)
+	}
+	absDir := NewAbsolutePath(filepath.Clean(dir))
+	return absDir, layerName(absDir, userConfig)
+}
+
+func layerName(dir AbsolutePath, userConfig *common.UserConfig) string {
+	if name := userConfig.FindRepo(dir.Str()); name != "" {
+		return name
+	}
+	return filepath.Base(dir.Str())
+}
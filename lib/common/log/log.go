@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 
 	"github.com/fatih/color"
 )
@@ -15,8 +16,28 @@ var (
 	isQuiet            bool
 	isVerbose          bool
 	PanicInsteadOfExit bool
+	fatalHandler       func()
+	customLogger       Logger
+	catchDepth         int
 )
 
+// Receives all the messages, regardless of the verbose and quiet flags. Used when doot is embedded in another program.
+type Logger interface {
+	Info(message string)
+	Print(message string)
+	Warning(message string)
+	Error(message string)
+}
+
+// The error returned by Catch when the function fails fatally. The message has already been logged.
+type FatalError struct {
+	Message string
+}
+
+func (e *FatalError) Error() string {
+	return e.Message
+}
+
 func Init(verbose, quiet bool) {
 	if verbose {
 		infolnLogger = log.New(color.Output, "INFO: ", 0)
@@ -30,32 +51,88 @@ func Init(verbose, quiet bool) {
 }
 
 func Info(format string, v ...interface{}) {
-	if infolnLogger != nil {
+	if customLogger != nil {
+		customLogger.Info(fmt.Sprintf(format, v...))
+	} else if infolnLogger != nil {
 		infolnLogger.Printf(format, v...)
 	}
 }
 
 func Printlnf(format string, v ...interface{}) {
-	if !isQuiet {
+	if customLogger != nil {
+		customLogger.Print(fmt.Sprintf(format, v...))
+	} else if !isQuiet {
 		fmt.Printf(format, v...)
 		fmt.Println()
 	}
 }
 
 func Warning(format string, v ...interface{}) {
-	warningLogger.Printf(format, v...)
+	if customLogger != nil {
+		customLogger.Warning(fmt.Sprintf(format, v...))
+	} else {
+		warningLogger.Printf(format, v...)
+	}
 }
 
 func Error(format string, v ...interface{}) {
-	errorLogger.Printf(format, v...)
+	if customLogger != nil {
+		customLogger.Error(fmt.Sprintf(format, v...))
+	} else {
+		errorLogger.Printf(format, v...)
+	}
 }
 
 func Fatal(format string, v ...interface{}) {
+	Error(format, v...)
+	runFatalHandler()
+	if catchDepth > 0 {
+		panic(&FatalError{Message: fmt.Sprintf(format, v...)})
+	}
 	if PanicInsteadOfExit {
-		Error(format, v...)
 		panic(fmt.Sprintf(format, v...))
 	}
-	errorLogger.Fatalf(format, v...)
+	os.Exit(1)
+}
+
+// Sets a function that runs after a fatal error is printed and before exiting, for example to roll back the changes made
+// so far. Pass nil to remove it.
+func SetFatalHandler(handler func()) {
+	fatalHandler = handler
+}
+
+// Sends all the messages to the logger instead of the terminal, and returns the previous one. Pass nil to print to the
+// terminal again.
+func SetLogger(logger Logger) Logger {
+	previous := customLogger
+	customLogger = logger
+	return previous
+}
+
+// Runs fn and returns the fatal error that stopped it, if any, instead of exiting
+func Catch(fn func()) (err error) {
+	catchDepth++
+	defer func() {
+		catchDepth--
+		if recovered := recover(); recovered != nil {
+			fatalErr, isFatal := recovered.(*FatalError)
+			if !isFatal {
+				panic(recovered)
+			}
+			err = fatalErr
+		}
+	}()
+	fn()
+	return nil
+}
+
+func runFatalHandler() {
+	handler := fatalHandler
+	// The handler may fail fatally too
+	fatalHandler = nil
+	if handler != nil {
+		handler()
+	}
 }
 
 func IsQuiet() bool {
@@ -0,0 +1,193 @@
+package modules
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/hosts"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+type Module struct {
+	Name string
+	// Directory of the module, relative to the dotfiles directory. Its contents are installed as if they were in the root.
+	Dir     string
+	Enabled bool
+	// Why the module is enabled or disabled (for example "host_modules.laptop" or "enabled locally")
+	Reason string
+}
+
+// The zero value is a valid selection with no modules
+type Selection struct {
+	modules []Module
+}
+
+type LocalOverrides struct {
+	Enabled  []string
+	Disabled []string
+}
+
+// Returns the modules declared in [modules] (and the top-level directories, if auto_modules is set),
+// and whether they are enabled for the given hostname. Local overrides take precedence over the config file.
+func Resolve(config *config.Config, dotfilesDir AbsolutePath, hostname string, overrides LocalOverrides) Selection {
+	selection := Selection{
+		modules: declaredModules(config),
+	}
+	if config.AutoModules {
+		selection.addTopLevelModules(config, dotfilesDir)
+	}
+	slices.SortFunc(selection.modules, func(a, b Module) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, name := range config.EnabledModules {
+		selection.setEnabled(name, true, "enabled_modules", "enabled_modules")
+	}
+	hostKeys := make([]string, 0, len(config.HostModules))
+	for key := range config.HostModules {
+		hostKeys = append(hostKeys, key)
+	}
+	slices.Sort(hostKeys)
+	for _, key := range hostKeys {
+		if !hosts.MatchesHostKey(config, key, hostname) {
+			continue
+		}
+		for _, name := range config.HostModules[key] {
+			selection.setEnabled(name, true, "host_modules."+key, "host_modules")
+		}
+	}
+	for _, name := range overrides.Enabled {
+		selection.setEnabled(name, true, "enabled locally", "")
+	}
+	for _, name := range overrides.Disabled {
+		selection.setEnabled(name, false, "disabled locally", "")
+	}
+	return selection
+}
+
+func declaredModules(config *config.Config) []Module {
+	modules := make([]Module, 0, len(config.Modules))
+	for name, dir := range config.Modules {
+		dir = filepath.Clean(dir)
+		if filepath.IsAbs(dir) || dir == "." || strings.HasPrefix(dir, "..") {
+			log.Fatal("Invalid config: module '%s' has directory '%s', it must be a subdirectory of the dotfiles directory", name, dir)
+		}
+		modules = append(modules, Module{Name: name, Dir: dir})
+	}
+	return modules
+}
+
+func (s *Selection) addTopLevelModules(config *config.Config, dotfilesDir AbsolutePath) {
+	entries, err := filesystem.ReadDir(dotfilesDir.Str())
+	if err != nil {
+		log.Error("Error reading directory %s: %v", dotfilesDir, err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") || name == "doot" {
+			continue
+		}
+		if s.Lookup(name) != nil || s.containsDir(name) || isHostDir(config, name) {
+			continue
+		}
+		s.modules = append(s.modules, Module{Name: name, Dir: name})
+	}
+}
+
+func (s *Selection) setEnabled(name string, enabled bool, reason string, configKey string) {
+	module := s.Lookup(name)
+	if module == nil {
+		// Unknown local overrides are reported by WarnUnknownOverrides, since they may refer to the modules of another layer
+		if configKey != "" {
+			log.Warning("Module '%s' is used in %s but it's not defined", name, configKey)
+		}
+		return
+	}
+	module.Enabled = enabled
+	module.Reason = reason
+}
+
+// Warns about the local overrides that don't match a module in any of the selections
+func WarnUnknownOverrides(overrides LocalOverrides, selections ...Selection) {
+	for _, name := range slices.Concat(overrides.Enabled, overrides.Disabled) {
+		known := slices.ContainsFunc(selections, func(s Selection) bool {
+			return s.Lookup(name) != nil
+		})
+		if !known {
+			log.Warning("Ignoring local override for unknown module '%s'", name)
+		}
+	}
+}
+
+func (s *Selection) Lookup(name string) *Module {
+	for i := range s.modules {
+		if s.modules[i].Name == name {
+			return &s.modules[i]
+		}
+	}
+	return nil
+}
+
+// Returns all the modules, sorted by name
+func (s *Selection) Modules() []Module {
+	return s.modules
+}
+
+// Returns true if the path is the directory of a module that is not enabled
+func (s *Selection) IsDisabledDir(path RelativePath) bool {
+	for _, module := range s.modules {
+		if !module.Enabled && module.Dir == path.Str() {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the path is inside a module that is not enabled
+func (s *Selection) IsInDisabledModule(path RelativePath) bool {
+	return s.innermostModule(path).isDisabled()
+}
+
+// Returns the length of the prefix that should be removed from the path (0 if it's not inside an enabled module)
+func (s *Selection) EnabledPrefixLen(path RelativePath) int {
+	module := s.innermostModule(path)
+	if module == nil || !module.Enabled {
+		return 0
+	}
+	return len(module.Dir) + len(string(filepath.Separator))
+}
+
+func (s *Selection) innermostModule(path RelativePath) *Module {
+	var result *Module
+	for i, module := range s.modules {
+		prefix := module.Dir + string(filepath.Separator)
+		if strings.HasPrefix(path.Str(), prefix) && (result == nil || len(module.Dir) > len(result.Dir)) {
+			result = &s.modules[i]
+		}
+	}
+	return result
+}
+
+func (m *Module) isDisabled() bool {
+	return m != nil && !m.Enabled
+}
+
+func (s *Selection) containsDir(dir string) bool {
+	return slices.ContainsFunc(s.modules, func(module Module) bool {
+		return module.Dir == dir
+	})
+}
+
+func isHostDir(config *config.Config, dir string) bool {
+	for _, hostDir := range config.Hosts {
+		if filepath.Clean(hostDir) == dir {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package name_mapping
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/glob_collection"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+// Decides which components of a path should be prefixed with a dot when installing.
+// By default, only the top-level component is prefixed (if implicit_dot is enabled). implicit_dot_rules can override
+// this for any path: a matching pattern adds the dot, and a matching "!" pattern prevents it. The last match wins.
+type ImplicitDot struct {
+	enabled bool
+	rules   glob_collection.RuleList
+}
+
+func NewImplicitDot(config *config.Config) ImplicitDot {
+	patterns := make([]string, 0, len(config.ImplicitDotIgnore)+len(config.ImplicitDotRules))
+	for _, ignored := range config.ImplicitDotIgnore {
+		patterns = append(patterns, glob_collection.NEGATION_PREFIX+ignored)
+	}
+	patterns = append(patterns, config.ImplicitDotRules...)
+	return ImplicitDot{
+		enabled: config.ImplicitDot,
+		rules:   glob_collection.NewRuleList(patterns),
+	}
+}
+
+// Converts a path relative to the dotfiles directory (without the host-specific prefix) into a path relative to the
+// target directory, by prefixing a dot to the relevant components.
+func (d *ImplicitDot) Apply(path RelativePath) RelativePath {
+	sourceComponents := splitComponents(path)
+	targetComponents := make([]string, len(sourceComponents))
+	for i, component := range sourceComponents {
+		sourcePrefix := RelativePath(filepath.Join(sourceComponents[:i+1]...))
+		if !strings.HasPrefix(component, ".") && d.shouldAddDot(sourcePrefix, component, i) {
+			targetComponents[i] = "." + component
+		} else {
+			targetComponents[i] = component
+		}
+	}
+	return RelativePath(filepath.Join(targetComponents...))
+}
+
+// Inverse of Apply. Returns an error if no source path produces the given target path.
+func (d *ImplicitDot) Reverse(targetPath RelativePath) (RelativePath, error) {
+	targetComponents := splitComponents(targetPath)
+	sourceComponents := make([]string, 0, len(targetComponents))
+	for i, component := range targetComponents {
+		if undotted, isHidden := strings.CutPrefix(component, "."); isHidden && undotted != "" {
+			candidate := RelativePath(filepath.Join(append(sourceComponents, undotted)...))
+			if d.shouldAddDot(candidate, undotted, i) {
+				sourceComponents = append(sourceComponents, undotted)
+				continue
+			}
+		} else {
+			candidate := RelativePath(filepath.Join(append(sourceComponents, component)...))
+			if d.shouldAddDot(candidate, component, i) {
+				return "", fmt.Errorf("its current filename is impossible because '%s' would be prefixed with a dot. Add '!%s' to implicit_dot_rules to fix this", candidate, candidate)
+			}
+		}
+		sourceComponents = append(sourceComponents, component)
+	}
+	return RelativePath(filepath.Join(sourceComponents...)), nil
+}
+
+func (d *ImplicitDot) shouldAddDot(sourcePrefix RelativePath, component string, depth int) bool {
+	isTopLevel := depth == 0
+	return d.rules.Evaluate(sourcePrefix, component, d.enabled && isTopLevel)
+}
+
+func splitComponents(path RelativePath) []string {
+	return strings.Split(path.Str(), string(filepath.Separator))
+}
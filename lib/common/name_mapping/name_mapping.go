@@ -0,0 +1,59 @@
+package name_mapping
+
+import (
+	"fmt"
+
+	"github.com/pol-rivero/doot/lib/common/config"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+// Converts paths inside the dotfiles directory into paths inside the target directory. The steps are:
+// configured transforms -> implicit dot -> removal of the '.doot-crypt' extension
+type NameMapping struct {
+	transforms  Transforms
+	implicitDot ImplicitDot
+	crypt       dootCryptTransform
+}
+
+func NewNameMapping(config *config.Config) NameMapping {
+	return NameMapping{
+		transforms:  NewTransforms(config.Transforms),
+		implicitDot: NewImplicitDot(config),
+	}
+}
+
+// path must be relative to the dotfiles directory, without the host-specific prefix
+func (nm *NameMapping) SourceToTarget(path RelativePath) RelativePath {
+	target := nm.transforms.Apply(path)
+	target = nm.implicitDot.Apply(target)
+	return nm.crypt.apply(target)
+}
+
+// Inverse of SourceToTarget. The '.doot-crypt' extension is added to the file name if encrypt is true, and the
+// extensions already in the path (of encrypted directories) are preserved.
+func (nm *NameMapping) TargetToSource(path RelativePath, encrypt bool) (RelativePath, error) {
+	source, err := nm.reverseNames(path)
+	if err != nil {
+		return "", err
+	}
+	source = nm.crypt.reverse(source, encrypt)
+	if roundTrip := nm.SourceToTarget(source); roundTrip != nm.crypt.apply(path) {
+		return "", fmt.Errorf("the transforms in the config file can't produce this filename (%s would be installed as %s)", source, roundTrip)
+	}
+	return source, nil
+}
+
+func (nm *NameMapping) reverseNames(path RelativePath) (RelativePath, error) {
+	if nm.transforms.hasDotPrefix() {
+		// "dot-bashrc" is preferred over "bashrc" (with implicit_dot), both are installed as ".bashrc"
+		source := nm.transforms.Reverse(path)
+		if nm.SourceToTarget(source) == nm.crypt.apply(path) {
+			return source, nil
+		}
+	}
+	source, err := nm.implicitDot.Reverse(path)
+	if err != nil {
+		return "", err
+	}
+	return nm.transforms.Reverse(source), nil
+}
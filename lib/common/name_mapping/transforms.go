@@ -0,0 +1,183 @@
+package name_mapping
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+)
+
+const DEFAULT_DOT_PREFIX = "dot-"
+
+// Rewrites a single path component (file or directory name)
+type transform interface {
+	apply(component string) string
+	reverse(component string) string
+}
+
+// GNU stow convention: "dot-bashrc" -> ".bashrc"
+type dotPrefixTransform struct {
+	prefix string
+}
+
+func (t *dotPrefixTransform) apply(component string) string {
+	if rest, found := strings.CutPrefix(component, t.prefix); found && rest != "" {
+		return "." + rest
+	}
+	return component
+}
+
+func (t *dotPrefixTransform) reverse(component string) string {
+	if rest, found := strings.CutPrefix(component, "."); found && rest != "" {
+		return t.prefix + rest
+	}
+	return component
+}
+
+// Removes a marker suffix: "config.tmpl" -> "config". The reverse is the identity, since a name without the marker
+// maps to the same target.
+type stripMarkerTransform struct {
+	marker string
+}
+
+func (t *stripMarkerTransform) apply(component string) string {
+	if rest, found := strings.CutSuffix(component, t.marker); found && rest != "" {
+		return rest
+	}
+	return component
+}
+
+func (t *stripMarkerTransform) reverse(component string) string {
+	return component
+}
+
+type regexTransform struct {
+	pattern            *regexp.Regexp
+	replacement        string
+	reversePattern     *regexp.Regexp // nil if the reverse is the identity
+	reverseReplacement string
+}
+
+func (t *regexTransform) apply(component string) string {
+	return t.pattern.ReplaceAllString(component, t.replacement)
+}
+
+func (t *regexTransform) reverse(component string) string {
+	if t.reversePattern == nil {
+		return component
+	}
+	return t.reversePattern.ReplaceAllString(component, t.reverseReplacement)
+}
+
+// The transforms configured in config.toml, applied in order to each component of a path
+type Transforms struct {
+	steps []transform
+}
+
+// The configs are validated when the config is loaded, but an invalid config can still be built in code
+func NewTransforms(configs []config.TransformConfig) Transforms {
+	steps := make([]transform, 0, len(configs))
+	for _, transformConfig := range configs {
+		steps = append(steps, newTransform(transformConfig))
+	}
+	return Transforms{steps}
+}
+
+func newTransform(transformConfig config.TransformConfig) transform {
+	switch transformConfig.Type {
+	case config.TRANSFORM_DOT_PREFIX:
+		prefix := transformConfig.Prefix
+		if prefix == "" {
+			prefix = DEFAULT_DOT_PREFIX
+		}
+		return &dotPrefixTransform{prefix}
+	case config.TRANSFORM_STRIP_MARKER:
+		return &stripMarkerTransform{transformConfig.Marker}
+	case config.TRANSFORM_REGEX:
+		t := &regexTransform{
+			pattern:            compilePattern("pattern", transformConfig.Pattern),
+			replacement:        transformConfig.Replacement,
+			reverseReplacement: transformConfig.ReverseReplacement,
+		}
+		if transformConfig.ReversePattern != "" {
+			t.reversePattern = compilePattern("reverse_pattern", transformConfig.ReversePattern)
+		}
+		return t
+	default:
+		log.Fatal("Unknown transform type '%s'", transformConfig.Type)
+		return nil
+	}
+}
+
+func compilePattern(field string, pattern string) *regexp.Regexp {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Fatal("Invalid %s '%s' in transform: %v", field, pattern, err)
+	}
+	return compiled
+}
+
+// Whether the dotfiles are named with the GNU stow convention, which is preferred over implicit_dot when adding files
+func (t *Transforms) hasDotPrefix() bool {
+	return slices.ContainsFunc(t.steps, func(step transform) bool {
+		_, isDotPrefix := step.(*dotPrefixTransform)
+		return isDotPrefix
+	})
+}
+
+func (t *Transforms) Apply(path RelativePath) RelativePath {
+	if len(t.steps) == 0 {
+		return path
+	}
+	components := splitComponents(path)
+	for i := range components {
+		for _, step := range t.steps {
+			components[i] = step.apply(components[i])
+		}
+	}
+	return RelativePath(filepath.Join(components...))
+}
+
+func (t *Transforms) Reverse(path RelativePath) RelativePath {
+	if len(t.steps) == 0 {
+		return path
+	}
+	components := splitComponents(path)
+	for i := range components {
+		for j := len(t.steps) - 1; j >= 0; j-- {
+			components[i] = t.steps[j].reverse(components[i])
+		}
+	}
+	return RelativePath(filepath.Join(components...))
+}
+
+// Removes the '.doot-crypt' extension of encrypted files and directories: "secret.doot-crypt.txt" -> "secret.txt". It's
+// implicitly applied after the configured transforms and implicit_dot.
+type dootCryptTransform struct{}
+
+func (t dootCryptTransform) apply(path RelativePath) RelativePath {
+	return path.Replace(common.DOOT_CRYPT_EXT, "")
+}
+
+// Adds the extension to the file name if encrypt is true and the path isn't already encrypted (for example, because it's
+// inside an encrypted directory). Otherwise, the path is kept as is.
+func (t dootCryptTransform) reverse(path RelativePath, encrypt bool) RelativePath {
+	if !encrypt || strings.Contains(path.Str(), common.DOOT_CRYPT_EXT) {
+		return path
+	}
+	dir, file := path.Split()
+	parts := strings.Split(file, ".")
+	if len(parts) == 1 || (len(parts) == 2 && parts[0] == "") {
+		// file.DOOT-CRYPT or .file.DOOT-CRYPT
+		parts = append(parts, common.DOOT_CRYPT_EXT_WITHOUT_DOT)
+	} else {
+		// some.file.DOOT-CRYPT.ext
+		parts = append(parts[:len(parts)-1], common.DOOT_CRYPT_EXT_WITHOUT_DOT, parts[len(parts)-1])
+	}
+	return RelativePath(filepath.Join(dir.Str(), strings.Join(parts, ".")))
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+const USER_CONFIG_FILE string = "doot.toml"
+
+// Machine-wide settings stored in $XDG_CONFIG_HOME/doot/doot.toml, outside of any dotfiles repository.
+// It's used to find the dotfiles directory without having to export $DOOT_DIR.
+type UserConfig struct {
+	// Path of the dotfiles directory. Ignored if 'current' is set.
+	DotfilesDir string `toml:"dotfiles_dir,omitempty"`
+	// Name of the registered repository in use.
+	Current string `toml:"current,omitempty"`
+	// Registered repositories, by name.
+	Repos map[string]string `toml:"repos,omitempty"`
+}
+
+func UserConfigPath() string {
+	xdgConfigHome := os.Getenv(ENV_XDG_CONFIG_HOME)
+	if xdgConfigHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatal("Error retrieving home directory: %v", err)
+		}
+		xdgConfigHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(xdgConfigHome, "doot", USER_CONFIG_FILE)
+}
+
+// Returns an empty config if the file doesn't exist
+func LoadUserConfig() UserConfig {
+	userConfig, err := readUserConfig()
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	return userConfig
+}
+
+func readUserConfig() (UserConfig, error) {
+	userConfig := UserConfig{Repos: make(map[string]string)}
+	path := UserConfigPath()
+	fileContents, err := filesystem.ReadFile(path)
+	if os.IsNotExist(err) {
+		return userConfig, nil
+	}
+	if err != nil {
+		return userConfig, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	if err := toml.Unmarshal(fileContents, &userConfig); err != nil {
+		return userConfig, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if userConfig.Repos == nil {
+		userConfig.Repos = make(map[string]string)
+	}
+	return userConfig, nil
+}
+
+func (uc *UserConfig) Save() {
+	path := UserConfigPath()
+	contents, err := toml.Marshal(uc)
+	if err != nil {
+		log.Fatal("Error serializing %s: %v", path, err)
+	}
+	if err := filesystem.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Fatal("Error creating directory %s: %v", filepath.Dir(path), err)
+	}
+	if err := filesystem.WriteFile(path, contents, 0644); err != nil {
+		log.Fatal("Error writing %s: %v", path, err)
+	}
+}
+
+// Returns the dotfiles directory the file points to, or an empty string
+func (uc *UserConfig) SelectedDir() string {
+	if uc.Current != "" {
+		return uc.Repos[uc.Current]
+	}
+	return uc.DotfilesDir
+}
+
+// Returns the registered repository names, sorted
+func (uc *UserConfig) RepoNames() []string {
+	names := make([]string, 0, len(uc.Repos))
+	for name := range uc.Repos {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Returns the name under which the directory is registered, or an empty string
+func (uc *UserConfig) FindRepo(dir string) string {
+	for _, name := range uc.RepoNames() {
+		if filepath.Clean(uc.Repos[name]) == filepath.Clean(dir) {
+			return name
+		}
+	}
+	return ""
+}
+
+// Registers the directory with the given name (or its base name, if empty) and returns the name that was used.
+// If the directory is already registered, its existing name is returned, or the entry is renamed if a different name is given.
+func (uc *UserConfig) Register(dir string, name string) string {
+	existing := uc.FindRepo(dir)
+	if existing != "" && (name == "" || name == existing) {
+		return existing
+	}
+	if existing != "" {
+		delete(uc.Repos, existing)
+		if uc.Current == existing {
+			uc.Current = name
+		}
+	}
+	if name == "" {
+		name = uniqueRepoName(uc.Repos, strings.TrimPrefix(filepath.Base(dir), "."))
+	}
+	uc.Repos[name] = dir
+	return name
+}
+
+func uniqueRepoName(repos map[string]string, base string) string {
+	if base == "" {
+		base = "dotfiles"
+	}
+	name := base
+	for i := 2; ; i++ {
+		if _, exists := repos[name]; !exists {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
@@ -0,0 +1,217 @@
+// Package engine runs some doot commands (install, clean, add, restore, undo, log, rollback and generations) from other
+// Go programs. Instead of exiting or reading stdin, they return their results and errors, and use the given logger and
+// prompter. The other commands are only available from the command line.
+//
+// The engine is process-global and single-threaded: the commands keep their settings in package variables, so each
+// call applies the options to them, runs the command and restores them. Calls are serialized (also across different
+// Engines), and the engine must not be used while other code calls the lib/commands packages directly. Fatal errors
+// are turned into returned errors with panic/recover, which only works on the goroutine of the call.
+package engine
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/pol-rivero/doot/lib/commands/add"
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/journalcmd"
+	"github.com/pol-rivero/doot/lib/commands/restore"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/generations"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+type Changes = install.Changes
+type Config = config.Config
+type Logger = log.Logger
+type Prompter = utils.Prompter
+
+// The settings of an Engine. Empty fields keep the behavior of the doot command.
+type Options struct {
+	// Defaults to $DOOT_DIR or the default locations
+	DotfilesDir string
+	// Takes precedence over the target_dir of the config
+	TargetDir string
+	// Used instead of the config files of the dotfiles directory (but not those of its layers)
+	Config *Config
+	// Directory of the cache, journal and generations. Defaults to $DOOT_CACHE_DIR or ~/.cache/doot
+	CacheDir string
+	// Receives all the messages. Defaults to printing to the terminal.
+	Logger Logger
+	// Answers the questions, for example whether to overwrite an existing file. Defaults to reading stdin.
+	Prompter Prompter
+	// Roll back the operation in progress and exit the whole process (with code 130) on SIGINT/SIGTERM, like the doot
+	// command does
+	HandleSignals bool
+	// Make the changes on a copy of the file system kept in memory, so that nothing is written to disk. Everything else
+	// (conflicts, prompts, returned changes...) works like a real run, but hooks are not run.
+	DryRun bool
+}
+
+type Engine struct {
+	options Options
+}
+
+type InstallOptions struct {
+	// Only update the targets or dotfiles that match these paths or globs. Empty means everything.
+	Paths []string
+	// Search the target directory for links to the dotfiles directory, even if they are not in the cache
+	FullClean bool
+}
+
+type AddOptions struct {
+	Crypt        bool
+	HostSpecific bool
+}
+
+// doot keeps its settings in package variables, so only one command can run at a time
+var runLock sync.Mutex
+
+func New(options Options) *Engine {
+	return &Engine{options: options}
+}
+
+func (e *Engine) Install(options InstallOptions) (changes Changes, err error) {
+	err = e.run(func() {
+		if len(options.Paths) > 0 {
+			changes = install.InstallPaths(options.FullClean, options.Paths)
+		} else {
+			changes = install.Install(options.FullClean)
+		}
+	})
+	return changes, err
+}
+
+func (e *Engine) Clean(options InstallOptions) (changes Changes, err error) {
+	err = e.run(func() {
+		if len(options.Paths) > 0 {
+			changes = install.CleanPaths(options.FullClean, options.Paths)
+		} else {
+			changes = install.Clean(options.FullClean)
+		}
+	})
+	return changes, err
+}
+
+// Copies the files into the dotfiles directory and installs them
+func (e *Engine) Add(files []string, options AddOptions) (changes Changes, err error) {
+	err = e.run(func() {
+		changes = add.Add(files, options.Crypt, options.HostSpecific)
+	})
+	return changes, err
+}
+
+// Replaces the links with the dotfiles they point to. Returns the files that were restored.
+func (e *Engine) Restore(files []string) (restored []AbsolutePath, err error) {
+	err = e.run(func() {
+		restored = restore.Restore(files)
+	})
+	return restored, err
+}
+
+// Reverts the last operation that has not been undone yet. Returns nil if there was nothing to undo.
+func (e *Engine) Undo() (op *journal.Operation, err error) {
+	err = e.run(func() {
+		op = journalcmd.Undo()
+	})
+	return op, err
+}
+
+// Returns the operations recorded in the journal, from oldest to newest
+func (e *Engine) Log() (operations []journal.Operation, err error) {
+	err = e.run(func() {
+		operations = journal.Load()
+	})
+	return operations, err
+}
+
+// Restores a previous generation. If number is 0, the generation before the current one is restored.
+func (e *Engine) Rollback(number int) (changes Changes, err error) {
+	err = e.run(func() {
+		changes = install.Rollback(number)
+	})
+	return changes, err
+}
+
+// Returns the generations of the dotfiles directory, from oldest to newest
+func (e *Engine) Generations() (list []generations.Generation, err error) {
+	err = e.run(func() {
+		dotfilesDir := common.FindDotfilesDir()
+		config := config.FromDotfilesDir(dotfilesDir)
+		list = generations.List(cache.ComputeCacheKey(dotfilesDir, config.TargetDir))
+	})
+	return list, err
+}
+
+// Returns the dotfiles directory used by the engine
+func (e *Engine) DotfilesDir() (dir AbsolutePath, err error) {
+	err = e.run(func() {
+		dir = common.FindDotfilesDir()
+	})
+	return dir, err
+}
+
+// Applies the options, runs fn and restores the previous settings. Fatal errors are returned instead of exiting.
+func (e *Engine) run(fn func()) error {
+	runLock.Lock()
+	defer runLock.Unlock()
+	restoreFuncs := make([]func(), 0, 8)
+	defer func() {
+		for i := len(restoreFuncs) - 1; i >= 0; i-- {
+			restoreFuncs[i]()
+		}
+	}()
+	return log.Catch(func() {
+		e.apply(&restoreFuncs)
+		fn()
+		if e.options.DryRun {
+			log.Printlnf("Dry run: no files were modified")
+		}
+	})
+}
+
+// Adds the functions that restore the previous settings to restoreFuncs as the options are applied
+func (e *Engine) apply(restoreFuncs *[]func()) {
+	options := &e.options
+	if options.Logger != nil {
+		previous := log.SetLogger(options.Logger)
+		*restoreFuncs = append(*restoreFuncs, func() { log.SetLogger(previous) })
+	}
+	if options.Prompter != nil {
+		previous := utils.SetPrompter(options.Prompter)
+		*restoreFuncs = append(*restoreFuncs, func() { utils.SetPrompter(previous) })
+	}
+	if options.DotfilesDir != "" {
+		common.SetEmbeddedDotfilesDir(options.DotfilesDir)
+		*restoreFuncs = append(*restoreFuncs, func() { common.SetEmbeddedDotfilesDir("") })
+	}
+	if options.TargetDir != "" {
+		config.SetTargetDirOverride(options.TargetDir)
+		*restoreFuncs = append(*restoreFuncs, func() { config.SetTargetDirOverride("") })
+	}
+	if options.Config != nil {
+		dotfilesDir := common.FindDotfilesDir()
+		config.SetConfigOverride(dotfilesDir, options.Config)
+		*restoreFuncs = append(*restoreFuncs, func() { config.SetConfigOverride("", nil) })
+	}
+	if options.CacheDir != "" {
+		cacheDir, err := filepath.Abs(options.CacheDir)
+		if err != nil {
+			log.Fatal("Invalid cache directory %s: %v", options.CacheDir, err)
+		}
+		cache.SetExplicitCacheDir(cacheDir)
+		*restoreFuncs = append(*restoreFuncs, func() { cache.SetExplicitCacheDir("") })
+	}
+	if options.DryRun {
+		previous := filesystem.Use(filesystem.NewOverlay(filesystem.Current()))
+		*restoreFuncs = append(*restoreFuncs, func() { filesystem.Use(previous) })
+	}
+	journal.SetSignalHandling(options.HandleSignals)
+	*restoreFuncs = append(*restoreFuncs, func() { journal.SetSignalHandling(true) })
+}
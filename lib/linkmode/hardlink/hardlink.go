@@ -1,10 +1,9 @@
 package linkmode_hardlink
 
 import (
-	"os"
-
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 type HardlinkLinkMode struct{}
@@ -15,7 +14,7 @@ type OsStatResult struct {
 }
 
 func (l *HardlinkLinkMode) CreateLink(dotfilesSource, target AbsolutePath) error {
-	return os.Link(dotfilesSource.Str(), target.Str())
+	return filesystem.Link(dotfilesSource.Str(), target.Str())
 }
 
 func (l *HardlinkLinkMode) IsInstalledLinkOf(maybeInstalledLinkPath string, dotfilePath AbsolutePath) bool {
@@ -1,12 +1,12 @@
 package linkmode_hardlink
 
 import (
-	"os"
 	"path/filepath"
 
 	"github.com/pol-rivero/doot/lib/common/cache"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 type HardlinkMap map[HardlinkId]AbsolutePath
@@ -20,7 +20,7 @@ func (l *HardlinkLinkMode) RecalculateCache(dotfilesDir AbsolutePath, scanPath s
 }
 
 func fullCleanScanRecursive(result *[]*cache.InstalledFile, dotfilesDirHardlinks *HardlinkMap, scanPath string) {
-	entries, err := os.ReadDir(scanPath)
+	entries, err := filesystem.ReadDir(scanPath)
 	if err != nil {
 		log.Warning("Skipping '%s' due to error: %v", scanPath, err)
 		return
@@ -48,7 +48,7 @@ func fullCleanScanRecursive(result *[]*cache.InstalledFile, dotfilesDirHardlinks
 }
 
 func getHardlinksInfoRecursive(dotfilesDirHardlinks *HardlinkMap, dotfilesDir AbsolutePath) {
-	entries, err := os.ReadDir(dotfilesDir.Str())
+	entries, err := filesystem.ReadDir(dotfilesDir.Str())
 	if err != nil {
 		log.Warning("Skipping '%s' due to error: %v", dotfilesDir, err)
 		return
@@ -1,22 +1,22 @@
 package linkmode_symlink
 
 import (
-	"os"
 	"strings"
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 type SymlinkLinkMode struct{}
 
 func (l *SymlinkLinkMode) CreateLink(dotfilesSource, target AbsolutePath) error {
-	return os.Symlink(dotfilesSource.Str(), target.Str())
+	return filesystem.Symlink(dotfilesSource.Str(), target.Str())
 }
 
 func (l *SymlinkLinkMode) IsInstalledLinkOf(maybeInstalledLinkPath string, dotfilePath AbsolutePath) bool {
-	fileInfo, err := os.Lstat(maybeInstalledLinkPath)
+	fileInfo, err := filesystem.Lstat(maybeInstalledLinkPath)
 	if err != nil {
 		log.Info("Failed to stat %s: %v", maybeInstalledLinkPath, err)
 		return false
@@ -25,7 +25,7 @@ func (l *SymlinkLinkMode) IsInstalledLinkOf(maybeInstalledLinkPath string, dotfi
 }
 
 func getSymlinkTarget(linkPath string) string {
-	linkSource, linkErr := os.Readlink(linkPath)
+	linkSource, linkErr := filesystem.Readlink(linkPath)
 	if linkErr != nil {
 		log.Fatal("Failed to read link %s: %v", linkPath, linkErr)
 	}
@@ -33,7 +33,7 @@ func getSymlinkTarget(linkPath string) string {
 }
 
 func (l *SymlinkLinkMode) CanBeSafelyRemoved(linkPath AbsolutePath, expectedDestinationDir string) bool {
-	linkSource, linkErr := os.Readlink(linkPath.Str())
+	linkSource, linkErr := filesystem.Readlink(linkPath.Str())
 	if linkErr != nil {
 		return false
 	}
@@ -1,7 +1,6 @@
 package linkmode_symlink
 
 import (
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -9,6 +8,7 @@ import (
 	"github.com/pol-rivero/doot/lib/common/cache"
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 func (l *SymlinkLinkMode) RecalculateCache(dotfilesDir AbsolutePath, scanPath string) []*cache.InstalledFile {
@@ -18,7 +18,7 @@ func (l *SymlinkLinkMode) RecalculateCache(dotfilesDir AbsolutePath, scanPath st
 }
 
 func fullCleanScanRecursive(result *[]*cache.InstalledFile, dotfilesDir AbsolutePath, scanPath string) {
-	entries, err := os.ReadDir(scanPath)
+	entries, err := filesystem.ReadDir(scanPath)
 	if err != nil {
 		log.Warning("Skipping '%s' due to error: %v", scanPath, err)
 		return
@@ -29,7 +29,7 @@ func fullCleanScanRecursive(result *[]*cache.InstalledFile, dotfilesDir Absolute
 		if entry.IsDir() {
 			fullCleanScanRecursive(result, dotfilesDir, entryPath)
 		} else if common.DirEntryIsSymlink(entry) {
-			target, err := os.Readlink(entryPath)
+			target, err := filesystem.Readlink(entryPath)
 			if err != nil {
 				log.Warning("Failed to read symlink %s: %v", entryPath, err)
 				continue
@@ -1,8 +1,8 @@
 package lib
 
 import (
-	"github.com/pol-rivero/doot/lib/commands/install"
 	"github.com/pol-rivero/doot/lib/customcmd"
+	"github.com/pol-rivero/doot/lib/engine"
 	"github.com/spf13/cobra"
 )
 
@@ -11,14 +11,15 @@ func ExecuteRootCmd(cmd *cobra.Command, rawArgs []string) {
 	if isCustomCommand {
 		customcmd.CustomCommand(rawArgs[0], rawArgs[1:])
 	} else {
-		ExecuteInstall(cmd)
+		ExecuteInstall(cmd, nil)
 	}
 }
 
-func ExecuteInstall(cmd *cobra.Command) {
+func ExecuteInstall(cmd *cobra.Command, paths []string) {
 	fullClean, err := cmd.Flags().GetBool("full-clean")
 	if err != nil {
 		panic(err)
 	}
-	install.Install(fullClean)
+	_, err = NewCliEngine(cmd).Install(engine.InstallOptions{Paths: paths, FullClean: fullClean})
+	ExitOnError(err)
 }
@@ -3,6 +3,8 @@ package types
 import (
 	"path/filepath"
 	"strings"
+
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 type RelativePath string
@@ -26,6 +28,11 @@ func (rp RelativePath) RemoveBaseDir(baseDirLen int) RelativePath {
 	return RelativePath(rp.Str()[baseDirLen:])
 }
 
+func (rp RelativePath) CutSuffix(suffix string) (RelativePath, bool) {
+	before, found := strings.CutSuffix(rp.Str(), suffix)
+	return RelativePath(before), found
+}
+
 func (rp RelativePath) AppendLeft(left string) RelativePath {
 	return RelativePath(filepath.Join(left, rp.Str()))
 }
@@ -72,7 +79,7 @@ func NewAbsolutePath(path string) AbsolutePath {
 }
 
 func RelativeToPWD(relativePath string) AbsolutePath {
-	absPath, err := filepath.Abs(relativePath)
+	absPath, err := filesystem.Abs(relativePath)
 	if err != nil {
 		panic(err)
 	}
@@ -7,12 +7,13 @@ import (
 	"github.com/pol-rivero/doot/lib/common/log"
 	"github.com/pol-rivero/doot/lib/linkmode"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 // https://stackoverflow.com/a/58148921
 func ReplaceWithLink(target AbsolutePath, dotfilesSource AbsolutePath, linkMode linkmode.LinkMode) error {
 	tempLocation := target.AppendExtension(common.DOOT_BACKUP_EXT)
-	if err := os.Remove(tempLocation.Str()); err != nil && !os.IsNotExist(err) {
+	if err := filesystem.Remove(tempLocation.Str()); err != nil && !os.IsNotExist(err) {
 		log.Error("Failed to remove temporary file %s, consider removing it manually.\n%s", tempLocation, err)
 		return err
 	}
@@ -22,9 +23,9 @@ func ReplaceWithLink(target AbsolutePath, dotfilesSource AbsolutePath, linkMode
 		return err
 	}
 
-	if err := os.Rename(tempLocation.Str(), target.Str()); err != nil {
+	if err := filesystem.Rename(tempLocation.Str(), target.Str()); err != nil {
 		log.Error("Failed to update %s: %s", target, err)
-		os.Remove(tempLocation.Str())
+		filesystem.Remove(tempLocation.Str())
 		return err
 	}
 
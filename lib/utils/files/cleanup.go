@@ -5,10 +5,11 @@ import (
 
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 func RemoveAndCleanup(removeFile, stopAt AbsolutePath) bool {
-	err := os.Remove(removeFile.Str())
+	err := filesystem.Remove(removeFile.Str())
 	if err == nil {
 		CleanupEmptyDir(removeFile.Parent(), stopAt)
 		return true
@@ -24,7 +25,7 @@ func CleanupEmptyDir(dir, stopAt AbsolutePath) {
 	if dir == stopAt {
 		return
 	}
-	dirEntries, err := os.ReadDir(dir.Str())
+	dirEntries, err := filesystem.ReadDir(dir.Str())
 	if err != nil {
 		log.Warning("Could not clean up %s: %s", dir, err)
 		return
@@ -32,7 +33,7 @@ func CleanupEmptyDir(dir, stopAt AbsolutePath) {
 	if len(dirEntries) > 0 {
 		return
 	}
-	err = os.Remove(dir.Str())
+	err = filesystem.Remove(dir.Str())
 	if err != nil {
 		log.Warning("Could not clean up %s: %s", dir, err)
 	} else {
@@ -2,17 +2,17 @@ package files
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/log"
 	hardlink "github.com/pol-rivero/doot/lib/linkmode/hardlink"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
 func HardlinkOrCopyFile(sourcePath, destinationPath string, allowOverwrite bool) error {
-	err := os.Link(sourcePath, destinationPath)
+	err := filesystem.Link(sourcePath, destinationPath)
 	if err == nil {
 		return nil
 	}
@@ -24,10 +24,10 @@ func HardlinkOrCopyFile(sourcePath, destinationPath string, allowOverwrite bool)
 func MoveOrCopyFile(sourcePath, destinationPath string, allowOverwrite bool) error {
 	if hardlink.IsHardlink(sourcePath, destinationPath) {
 		// Just need to delete the source path, as both paths point to the same inode
-		return os.Remove(sourcePath)
+		return filesystem.Remove(sourcePath)
 	}
 
-	err := os.Rename(sourcePath, destinationPath)
+	err := filesystem.Rename(sourcePath, destinationPath)
 	if err == nil {
 		return nil
 	}
@@ -36,14 +36,14 @@ func MoveOrCopyFile(sourcePath, destinationPath string, allowOverwrite bool) err
 	if err = CopyFile(sourcePath, destinationPath, allowOverwrite); err != nil {
 		return err
 	}
-	if err = os.Remove(sourcePath); err != nil {
+	if err = filesystem.Remove(sourcePath); err != nil {
 		return fmt.Errorf("failed to remove %q: %w", sourcePath, err)
 	}
 	return nil
 }
 
 func CopyFile(sourcePath, destinationPath string, allowOverwrite bool) error {
-	info, err := os.Lstat(sourcePath)
+	info, err := filesystem.Lstat(sourcePath)
 	if err != nil {
 		return err
 	}
@@ -51,7 +51,7 @@ func CopyFile(sourcePath, destinationPath string, allowOverwrite bool) error {
 		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(destinationPath), 0o755); err != nil {
+	if err := filesystem.MkdirAll(filepath.Dir(destinationPath), 0o755); err != nil {
 		return fmt.Errorf("failed to create parent directory for %q: %w", destinationPath, err)
 	}
 
@@ -68,7 +68,7 @@ func checkOverwriteError(destinationPath string, allowOverwrite bool) error {
 	if allowOverwrite {
 		return nil // no need to check if destination exists, we can overwrite if it does
 	}
-	_, err := os.Lstat(destinationPath)
+	_, err := filesystem.Lstat(destinationPath)
 	if err == nil {
 		return os.ErrExist
 	} else if os.IsNotExist(err) {
@@ -79,47 +79,35 @@ func checkOverwriteError(destinationPath string, allowOverwrite bool) error {
 }
 
 func copySymlink(sourcePath, destinationPath string) error {
-	target, err := os.Readlink(sourcePath)
+	target, err := filesystem.Readlink(sourcePath)
 	if err != nil {
 		return fmt.Errorf("failed to read symlink %q: %w", sourcePath, err)
 	}
 
-	err = os.Remove(destinationPath)
+	err = filesystem.Remove(destinationPath)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove %q: %w", destinationPath, err)
 	}
 
-	return os.Symlink(target, destinationPath)
+	return filesystem.Symlink(target, destinationPath)
 }
 
 func copyRegularFile(sourcePath, destinationPath string, fileMode os.FileMode) error {
-	in, err := os.Open(sourcePath)
+	contents, err := filesystem.ReadFile(sourcePath)
 	if err != nil {
-		return fmt.Errorf("failed to open source file %q: %w", sourcePath, err)
+		return fmt.Errorf("failed to read source file %q: %w", sourcePath, err)
 	}
-	defer in.Close()
 
 	if err := removeIfSymlink(destinationPath); err != nil {
 		return fmt.Errorf("failed to remove existing symlink %q: %w", destinationPath, err)
 	}
 
 	// Create with a safe default, permissions will be fixed after copy
-	out, err := os.OpenFile(destinationPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
-	if err != nil {
-		return fmt.Errorf("failed to open or create destination file %q: %w", destinationPath, err)
-	}
-	defer func() {
-		_ = out.Close()
-	}()
-
-	if _, err := io.Copy(out, in); err != nil {
+	if err := filesystem.WriteFile(destinationPath, contents, 0o600); err != nil {
 		return fmt.Errorf("failed to copy file contents from %q to %q: %w", sourcePath, destinationPath, err)
 	}
-	if err := out.Sync(); err != nil {
-		return fmt.Errorf("failed to flush file %q: %w", destinationPath, err)
-	}
 
-	if err := os.Chmod(destinationPath, fileMode.Perm()); err != nil {
+	if err := filesystem.Chmod(destinationPath, fileMode.Perm()); err != nil {
 		return fmt.Errorf("failed to change file mode for %q: %w", destinationPath, err)
 	}
 	return nil
@@ -127,8 +115,8 @@ func copyRegularFile(sourcePath, destinationPath string, fileMode os.FileMode) e
 
 func removeIfSymlink(path string) error {
 	// Delete symlink so that it will be recreated on copy.
-	// Otherwise, OpenFile will open and overwrite the symlink target instead of the symlink itself.
-	info, err := os.Lstat(path)
+	// Otherwise, WriteFile will open and overwrite the symlink target instead of the symlink itself.
+	info, err := filesystem.Lstat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -136,7 +124,7 @@ func removeIfSymlink(path string) error {
 		return err
 	}
 	if common.IsSymlink(info) {
-		return os.Remove(path)
+		return filesystem.Remove(path)
 	}
 	return nil
 }
@@ -0,0 +1,17 @@
+//go:build darwin || linux || freebsd || openbsd || dragonfly || netbsd
+
+package files
+
+import (
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Uniquely identifies a file or directory, regardless of the path used to reach it
+type FileId = filesystem.FileId
+
+// Returns the id of the file, following symlinks
+func GetFileId(path AbsolutePath) (FileId, error) {
+	fileId, _, err := filesystem.GetFileId(path.Str(), true)
+	return fileId, err
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package files
+
+import (
+	"path/filepath"
+
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Uniquely identifies a file or directory, regardless of the path used to reach it
+type FileId struct {
+	resolvedPath string
+	// Windows doesn't expose inode numbers through os.Stat, so they are only used for in-memory file systems
+	memoryId filesystem.FileId
+}
+
+// Returns the id of the file, following symlinks
+func GetFileId(path AbsolutePath) (FileId, error) {
+	if !filesystem.IsReal() {
+		memoryId, _, err := filesystem.GetFileId(path.Str(), true)
+		return FileId{memoryId: memoryId}, err
+	}
+	resolvedPath, err := filepath.EvalSymlinks(path.Str())
+	if err != nil {
+		return FileId{}, err
+	}
+	return FileId{resolvedPath: resolvedPath}, nil
+}
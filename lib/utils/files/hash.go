@@ -0,0 +1,22 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+func HashContents(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+func HashFile(path AbsolutePath) (string, error) {
+	contents, err := filesystem.ReadFile(path.Str())
+	if err != nil {
+		return "", err
+	}
+	return HashContents(contents), nil
+}
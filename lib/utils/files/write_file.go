@@ -0,0 +1,30 @@
+package files
+
+import (
+	"os"
+
+	"github.com/pol-rivero/doot/lib/common"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+// Writes the file to a temporary location first and then renames it, so that the target is never left half-written.
+// If the target is a symlink, the symlink itself is replaced (its destination is left untouched).
+func WriteFileAtomic(target AbsolutePath, contents []byte, perm os.FileMode) error {
+	tempLocation := target.AppendExtension(common.DOOT_BACKUP_EXT)
+	if err := filesystem.Remove(tempLocation.Str()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := filesystem.WriteFile(tempLocation.Str(), contents, perm); err != nil {
+		return err
+	}
+	if err := filesystem.Chmod(tempLocation.Str(), perm); err != nil {
+		filesystem.Remove(tempLocation.Str())
+		return err
+	}
+	if err := filesystem.Rename(tempLocation.Str(), target.Str()); err != nil {
+		filesystem.Remove(tempLocation.Str())
+		return err
+	}
+	return nil
+}
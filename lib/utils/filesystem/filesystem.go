@@ -0,0 +1,139 @@
+// Package filesystem routes the file operations of doot through a FileSystem, so that they can run on the real disk or
+// in memory (for dry runs and tests).
+package filesystem
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+)
+
+type FileSystem interface {
+	Lstat(path string) (fs.FileInfo, error)
+	Stat(path string) (fs.FileInfo, error)
+	// Follows symlinks, like os.ReadFile and os.WriteFile
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte, perm fs.FileMode) error
+	// Creates the file if it doesn't exist
+	AppendFile(path string, data []byte, perm fs.FileMode) error
+	// Returns the entries sorted by name
+	ReadDir(path string) ([]fs.DirEntry, error)
+	Readlink(path string) (string, error)
+	Symlink(target string, path string) error
+	Link(existingPath string, path string) error
+	Rename(oldPath string, newPath string) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Chmod(path string, mode fs.FileMode) error
+	// Returns the id of the file and its number of hard links. The last component is only followed if it's a symlink and
+	// followSymlinks is true.
+	FileId(path string, followSymlinks bool) (FileId, uint64, error)
+	// Relative paths are relative to this directory
+	Getwd() (string, error)
+}
+
+// Uniquely identifies a file or directory, regardless of the path used to reach it
+type FileId struct {
+	Inode uint64
+	Dev   uint64
+}
+
+var (
+	lock    sync.RWMutex
+	current FileSystem = OS{}
+)
+
+// Routes all the file operations to the file system, and returns the previous one
+func Use(fileSystem FileSystem) FileSystem {
+	lock.Lock()
+	defer lock.Unlock()
+	previous := current
+	current = fileSystem
+	return previous
+}
+
+func Current() FileSystem {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current
+}
+
+// Returns false if the changes are not being written to disk. Processes (hooks, git, ...) only see the real disk.
+func IsReal() bool {
+	_, isOS := Current().(OS)
+	return isOS
+}
+
+func Lstat(path string) (fs.FileInfo, error) {
+	return Current().Lstat(path)
+}
+
+func Stat(path string) (fs.FileInfo, error) {
+	return Current().Stat(path)
+}
+
+func ReadFile(path string) ([]byte, error) {
+	return Current().ReadFile(path)
+}
+
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return Current().WriteFile(path, data, perm)
+}
+
+func AppendFile(path string, data []byte, perm fs.FileMode) error {
+	return Current().AppendFile(path, data, perm)
+}
+
+func ReadDir(path string) ([]fs.DirEntry, error) {
+	return Current().ReadDir(path)
+}
+
+func Readlink(path string) (string, error) {
+	return Current().Readlink(path)
+}
+
+func Symlink(target string, path string) error {
+	return Current().Symlink(target, path)
+}
+
+func Link(existingPath string, path string) error {
+	return Current().Link(existingPath, path)
+}
+
+func Rename(oldPath string, newPath string) error {
+	return Current().Rename(oldPath, newPath)
+}
+
+func Remove(path string) error {
+	return Current().Remove(path)
+}
+
+func RemoveAll(path string) error {
+	return Current().RemoveAll(path)
+}
+
+func MkdirAll(path string, perm fs.FileMode) error {
+	return Current().MkdirAll(path, perm)
+}
+
+func Chmod(path string, mode fs.FileMode) error {
+	return Current().Chmod(path, mode)
+}
+
+func GetFileId(path string, followSymlinks bool) (FileId, uint64, error) {
+	return Current().FileId(path, followSymlinks)
+}
+
+// Same as filepath.Abs, but relative to the working directory of the current file system
+func Abs(path string) (string, error) {
+	fileSystem := Current()
+	if _, isOS := fileSystem.(OS); isOS || filepath.IsAbs(path) {
+		return filepath.Abs(path)
+	}
+	workingDir, err := fileSystem.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(workingDir, path), nil
+}
@@ -0,0 +1,718 @@
+package filesystem
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Device of the files created in memory. Mount points get the following numbers.
+const MEMORY_DEVICE uint64 = 0xd007
+
+const MAX_SYMLINK_DEPTH int = 40
+
+// A file system kept in memory. If it has a base, it's an overlay: the files of the base can be read, but all the
+// changes are kept in memory.
+type Memory struct {
+	lock      sync.Mutex
+	base      FileSystem
+	entries   map[string]*memoryEntry
+	removed   map[string]bool // Paths of the base that have been removed
+	mounts    []string
+	nextInode uint64
+	workDir   string
+}
+
+type memoryEntry struct {
+	// For regular files, the permissions are stored in the inode (shared by all its hard links)
+	mode    fs.FileMode
+	modTime time.Time
+	target  string       // Only for symlinks
+	inode   *memoryInode // Only for regular files and symlinks
+	id      FileId
+}
+
+type memoryInode struct {
+	data  []byte
+	perm  fs.FileMode
+	links uint64
+	id    FileId
+	// The contents are read from the base when they are first needed
+	basePath string
+	baseSize int64
+}
+
+// Returns an empty file system that only contains the root directory
+func NewMemory() *Memory {
+	return NewOverlay(nil)
+}
+
+// Returns a file system that reads from base and keeps the changes in memory. base is never modified.
+func NewOverlay(base FileSystem) *Memory {
+	m := &Memory{
+		base:    base,
+		entries: make(map[string]*memoryEntry),
+		removed: make(map[string]bool),
+		workDir: string(filepath.Separator),
+	}
+	if base == nil {
+		root := string(filepath.Separator)
+		m.entries[root] = m.newEntry(root, fs.ModeDir|0755)
+		return m
+	}
+	// Don't reuse the inode numbers of the base
+	m.nextInode = 1 << 62
+	if workDir, err := base.Getwd(); err == nil {
+		m.workDir = workDir
+	}
+	return m
+}
+
+// Makes dir and everything inside it a separate device, so that files can't be hard linked or renamed across it
+func (m *Memory) Mount(dir string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	dir = filepath.Clean(dir)
+	m.mounts = append(m.mounts, dir)
+	return m.mkdirAll(dir, 0755)
+}
+
+// Changes the directory that relative paths are relative to
+func (m *Memory) Chdir(dir string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("chdir", dir, true)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return pathError("chdir", dir, syscall.ENOENT)
+	}
+	if !entry.mode.IsDir() {
+		return pathError("chdir", dir, syscall.ENOTDIR)
+	}
+	m.workDir = resolved
+	return nil
+}
+
+func (m *Memory) Getwd() (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.workDir, nil
+}
+
+func (m *Memory) Lstat(path string) (fs.FileInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("lstat", path, false)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, pathError("lstat", path, syscall.ENOENT)
+	}
+	return entry.info(resolved), nil
+}
+
+func (m *Memory) Stat(path string) (fs.FileInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("stat", path, true)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, pathError("stat", path, syscall.ENOENT)
+	}
+	return entry.info(resolved), nil
+}
+
+func (m *Memory) ReadFile(path string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, entry, err := m.resolve("open", path, true)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, pathError("open", path, syscall.ENOENT)
+	}
+	if entry.mode.IsDir() {
+		return nil, pathError("read", path, syscall.EISDIR)
+	}
+	if err := m.load(entry.inode); err != nil {
+		return nil, err
+	}
+	return slices.Clone(entry.inode.data), nil
+}
+
+func (m *Memory) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("open", path, true)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		if err := m.checkParentDir("open", resolved); err != nil {
+			return err
+		}
+		entry = m.newEntry(resolved, perm.Perm())
+		m.add(resolved, entry)
+	} else if entry.mode.IsDir() {
+		return pathError("open", path, syscall.EISDIR)
+	}
+	entry.inode.data = slices.Clone(data)
+	entry.inode.basePath = ""
+	entry.modTime = time.Now()
+	return nil
+}
+
+func (m *Memory) AppendFile(path string, data []byte, perm fs.FileMode) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("open", path, true)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		if err := m.checkParentDir("open", resolved); err != nil {
+			return err
+		}
+		entry = m.newEntry(resolved, perm.Perm())
+		m.add(resolved, entry)
+	} else if entry.mode.IsDir() {
+		return pathError("open", path, syscall.EISDIR)
+	}
+	if err := m.load(entry.inode); err != nil {
+		return err
+	}
+	entry.inode.data = append(entry.inode.data, data...)
+	entry.modTime = time.Now()
+	return nil
+}
+
+func (m *Memory) ReadDir(path string) ([]fs.DirEntry, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("open", path, true)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, pathError("open", path, syscall.ENOENT)
+	}
+	if !entry.mode.IsDir() {
+		return nil, pathError("readdirent", path, syscall.ENOTDIR)
+	}
+	names, err := m.childNames(resolved)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]fs.DirEntry, 0, len(names))
+	for _, name := range names {
+		childPath := filepath.Join(resolved, name)
+		if child, err := m.lookup(childPath); err == nil && child != nil {
+			result = append(result, fs.FileInfoToDirEntry(child.info(childPath)))
+		}
+	}
+	return result, nil
+}
+
+func (m *Memory) Readlink(path string) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, entry, err := m.resolve("readlink", path, false)
+	if err != nil {
+		return "", err
+	}
+	if entry == nil {
+		return "", pathError("readlink", path, syscall.ENOENT)
+	}
+	if entry.mode&fs.ModeSymlink == 0 {
+		return "", pathError("readlink", path, syscall.EINVAL)
+	}
+	return entry.target, nil
+}
+
+func (m *Memory) Symlink(target string, path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, err := m.prepareNew("symlink", path)
+	if err != nil {
+		return err
+	}
+	entry := m.newEntry(resolved, fs.ModeSymlink|0777)
+	entry.target = target
+	m.add(resolved, entry)
+	return nil
+}
+
+func (m *Memory) Link(existingPath string, path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	existingResolved, existing, err := m.resolve("link", existingPath, false)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return linkError("link", existingPath, path, syscall.ENOENT)
+	}
+	if existing.inode == nil {
+		return linkError("link", existingPath, path, syscall.EPERM)
+	}
+	resolved, err := m.prepareNew("link", path)
+	if err != nil {
+		return err
+	}
+	if m.device(existingResolved) != m.device(resolved) {
+		return linkError("link", existingPath, path, syscall.EXDEV)
+	}
+	existing.inode.links++
+	m.add(resolved, &memoryEntry{mode: existing.mode, modTime: existing.modTime, target: existing.target, inode: existing.inode})
+	return nil
+}
+
+func (m *Memory) Rename(oldPath string, newPath string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	oldResolved, entry, err := m.resolve("rename", oldPath, false)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return linkError("rename", oldPath, newPath, syscall.ENOENT)
+	}
+	newResolved, existing, err := m.resolve("rename", newPath, false)
+	if err != nil {
+		return err
+	}
+	if err := m.checkParentDir("rename", newResolved); err != nil {
+		return err
+	}
+	if m.device(oldResolved) != m.device(newResolved) {
+		return linkError("rename", oldPath, newPath, syscall.EXDEV)
+	}
+	if oldResolved == newResolved {
+		return nil
+	}
+	if existing != nil && existing.mode.IsDir() {
+		return linkError("rename", oldPath, newPath, syscall.EEXIST)
+	}
+	if entry.mode.IsDir() {
+		return m.renameDir(oldResolved, newResolved, entry)
+	}
+	if existing != nil && existing.inode != nil {
+		existing.inode.links--
+	}
+	m.add(newResolved, entry)
+	m.delete(oldResolved)
+	return nil
+}
+
+func (m *Memory) Remove(path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("remove", path, false)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return pathError("remove", path, syscall.ENOENT)
+	}
+	if entry.mode.IsDir() {
+		names, err := m.childNames(resolved)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return pathError("remove", path, syscall.ENOTEMPTY)
+		}
+	} else if entry.inode != nil {
+		entry.inode.links--
+	}
+	m.delete(resolved)
+	return nil
+}
+
+func (m *Memory) RemoveAll(path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	resolved, entry, err := m.resolve("removeall", path, false)
+	if err != nil || entry == nil {
+		// Like os.RemoveAll, a path that doesn't exist is not an error
+		return nil
+	}
+	return m.removeAll(resolved, entry)
+}
+
+func (m *Memory) MkdirAll(path string, perm fs.FileMode) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.mkdirAll(path, perm)
+}
+
+func (m *Memory) Chmod(path string, mode fs.FileMode) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, entry, err := m.resolve("chmod", path, true)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return pathError("chmod", path, syscall.ENOENT)
+	}
+	if entry.mode.IsRegular() {
+		entry.inode.perm = mode.Perm()
+	} else {
+		entry.mode = entry.mode.Type() | mode.Perm()
+	}
+	return nil
+}
+
+func (m *Memory) FileId(path string, followSymlinks bool) (FileId, uint64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, entry, err := m.resolve("stat", path, followSymlinks)
+	if err != nil {
+		return FileId{}, 0, err
+	}
+	if entry == nil {
+		return FileId{}, 0, pathError("stat", path, syscall.ENOENT)
+	}
+	if entry.inode != nil {
+		return entry.inode.id, entry.inode.links, nil
+	}
+	return entry.id, 1, nil
+}
+
+// Resolves the symlinks in the path (and in its last component, if followLast is true). Returns the resolved path and
+// its entry, which is nil if it doesn't exist (but its parent directory does).
+func (m *Memory) resolve(op string, path string, followLast bool) (string, *memoryEntry, error) {
+	return m.resolveDepth(op, path, followLast, 0)
+}
+
+func (m *Memory) resolveDepth(op string, path string, followLast bool, depth int) (string, *memoryEntry, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.workDir, path)
+	}
+	root := string(filepath.Separator)
+	components := strings.Split(strings.TrimPrefix(filepath.Clean(path), root), string(filepath.Separator))
+	current := root
+	entry, err := m.lookup(root)
+	if err != nil {
+		return "", nil, err
+	}
+	for i, component := range components {
+		if component == "" {
+			continue
+		}
+		isLast := i == len(components)-1
+		if entry == nil {
+			return "", nil, pathError(op, path, syscall.ENOENT)
+		}
+		if !entry.mode.IsDir() {
+			return "", nil, pathError(op, path, syscall.ENOTDIR)
+		}
+		next := filepath.Join(current, component)
+		entry, err = m.lookup(next)
+		if err != nil {
+			return "", nil, err
+		}
+		if entry != nil && entry.mode&fs.ModeSymlink != 0 && (!isLast || followLast) {
+			if depth >= MAX_SYMLINK_DEPTH {
+				return "", nil, pathError(op, path, syscall.ELOOP)
+			}
+			target := entry.target
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(current, target)
+			}
+			rest := filepath.Join(append([]string{target}, components[i+1:]...)...)
+			return m.resolveDepth(op, rest, followLast, depth+1)
+		}
+		current = next
+	}
+	return current, entry, nil
+}
+
+// Returns the entry at the resolved path, reading it from the base if needed. Returns nil if it doesn't exist.
+func (m *Memory) lookup(path string) (*memoryEntry, error) {
+	if entry, exists := m.entries[path]; exists {
+		return entry, nil
+	}
+	if m.base == nil || m.removed[path] {
+		return nil, nil
+	}
+	info, err := m.base.Lstat(path)
+	if err != nil {
+		if isNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entry := &memoryEntry{mode: info.Mode(), modTime: info.ModTime()}
+	id, links, idErr := m.base.FileId(path, false)
+	if idErr != nil {
+		id = m.nextId(path)
+		links = 1
+	}
+	entry.id = id
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		if entry.target, err = m.base.Readlink(path); err != nil {
+			return nil, err
+		}
+		entry.inode = &memoryInode{links: links, id: id}
+	case info.Mode().IsRegular():
+		entry.mode = 0
+		entry.inode = &memoryInode{perm: info.Mode().Perm(), links: links, id: id, basePath: path, baseSize: info.Size()}
+	}
+	m.entries[path] = entry
+	return entry, nil
+}
+
+func (m *Memory) load(inode *memoryInode) error {
+	if inode.basePath == "" {
+		return nil
+	}
+	data, err := m.base.ReadFile(inode.basePath)
+	if err != nil {
+		return err
+	}
+	inode.data = data
+	inode.basePath = ""
+	return nil
+}
+
+// Returns the names of the entries of the resolved directory, sorted
+func (m *Memory) childNames(dir string) ([]string, error) {
+	names := make(map[string]bool)
+	if m.base != nil {
+		if baseEntries, err := m.base.ReadDir(dir); err == nil {
+			for _, baseEntry := range baseEntries {
+				names[baseEntry.Name()] = true
+			}
+		}
+	}
+	for path := range m.entries {
+		if path != dir && filepath.Dir(path) == dir {
+			names[filepath.Base(path)] = true
+		}
+	}
+	result := make([]string, 0, len(names))
+	for name := range names {
+		if !m.removed[filepath.Join(dir, name)] {
+			result = append(result, name)
+		}
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// Checks that the parent of the resolved path is an existing directory
+func (m *Memory) checkParentDir(op string, path string) error {
+	_, parent, err := m.resolve(op, filepath.Dir(path), true)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return pathError(op, path, syscall.ENOENT)
+	}
+	if !parent.mode.IsDir() {
+		return pathError(op, path, syscall.ENOTDIR)
+	}
+	return nil
+}
+
+// Returns the resolved path where a new entry can be created
+func (m *Memory) prepareNew(op string, path string) (string, error) {
+	resolved, existing, err := m.resolve(op, path, false)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", pathError(op, path, syscall.EEXIST)
+	}
+	if err := m.checkParentDir(op, resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+func (m *Memory) mkdirAll(path string, perm fs.FileMode) error {
+	resolved, entry, err := m.resolve("mkdir", path, true)
+	if err == nil && entry != nil {
+		if entry.mode.IsDir() {
+			return nil
+		}
+		return pathError("mkdir", path, syscall.ENOTDIR)
+	}
+	parent := filepath.Dir(filepath.Clean(path))
+	if parent != filepath.Clean(path) {
+		if err := m.mkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	resolved, entry, err = m.resolve("mkdir", path, true)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		return nil
+	}
+	m.add(resolved, m.newEntry(resolved, fs.ModeDir|perm.Perm()))
+	return nil
+}
+
+func (m *Memory) removeAll(path string, entry *memoryEntry) error {
+	if entry.mode.IsDir() {
+		names, err := m.childNames(path)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			childPath := filepath.Join(path, name)
+			child, err := m.lookup(childPath)
+			if err != nil {
+				return err
+			}
+			if child != nil {
+				if err := m.removeAll(childPath, child); err != nil {
+					return err
+				}
+			}
+		}
+	} else if entry.inode != nil {
+		entry.inode.links--
+	}
+	m.delete(path)
+	return nil
+}
+
+func (m *Memory) renameDir(oldPath string, newPath string, entry *memoryEntry) error {
+	if strings.HasPrefix(newPath, oldPath+string(filepath.Separator)) {
+		return linkError("rename", oldPath, newPath, syscall.EINVAL)
+	}
+	names, err := m.childNames(oldPath)
+	if err != nil {
+		return err
+	}
+	m.add(newPath, entry)
+	for _, name := range names {
+		child, err := m.lookup(filepath.Join(oldPath, name))
+		if err != nil {
+			return err
+		}
+		if child == nil {
+			continue
+		}
+		if child.mode.IsDir() {
+			if err := m.renameDir(filepath.Join(oldPath, name), filepath.Join(newPath, name), child); err != nil {
+				return err
+			}
+			continue
+		}
+		m.add(filepath.Join(newPath, name), child)
+		m.delete(filepath.Join(oldPath, name))
+	}
+	m.delete(oldPath)
+	return nil
+}
+
+func (m *Memory) add(path string, entry *memoryEntry) {
+	m.entries[path] = entry
+	delete(m.removed, path)
+}
+
+func (m *Memory) delete(path string) {
+	delete(m.entries, path)
+	if m.base != nil {
+		m.removed[path] = true
+	}
+}
+
+func (m *Memory) newEntry(path string, mode fs.FileMode) *memoryEntry {
+	entry := &memoryEntry{mode: mode, modTime: time.Now(), id: m.nextId(path)}
+	if mode.IsRegular() {
+		entry.mode = 0
+		entry.inode = &memoryInode{perm: mode.Perm(), links: 1, id: entry.id}
+	} else if mode&fs.ModeSymlink != 0 {
+		entry.inode = &memoryInode{links: 1, id: entry.id}
+	}
+	return entry
+}
+
+func (m *Memory) nextId(path string) FileId {
+	m.nextInode++
+	return FileId{Inode: m.nextInode, Dev: m.device(path)}
+}
+
+// Returns the device of the resolved path: the one of the innermost mount point that contains it
+func (m *Memory) device(path string) uint64 {
+	device := MEMORY_DEVICE
+	longest := -1
+	for i, mount := range m.mounts {
+		if (path == mount || strings.HasPrefix(path, mount+string(filepath.Separator))) && len(mount) > longest {
+			device = MEMORY_DEVICE + uint64(i) + 1
+			longest = len(mount)
+		}
+	}
+	if longest < 0 && m.base != nil {
+		// New files are in the same device as their closest existing parent in the base
+		for dir := path; ; dir = filepath.Dir(dir) {
+			if id, _, err := m.base.FileId(dir, false); err == nil {
+				return id.Dev
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+	return device
+}
+
+func (entry *memoryEntry) info(path string) fs.FileInfo {
+	info := &memoryFileInfo{name: filepath.Base(path), mode: entry.mode, modTime: entry.modTime}
+	if entry.mode.IsRegular() {
+		info.mode = entry.inode.perm
+		info.size = int64(len(entry.inode.data))
+		if entry.inode.basePath != "" {
+			info.size = entry.inode.baseSize
+		}
+	} else if entry.mode&fs.ModeSymlink != 0 {
+		info.size = int64(len(entry.target))
+	}
+	return info
+}
+
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *memoryFileInfo) Name() string       { return i.name }
+func (i *memoryFileInfo) Size() int64        { return i.size }
+func (i *memoryFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memoryFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memoryFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memoryFileInfo) Sys() any           { return nil }
+
+func pathError(op string, path string, err error) error {
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
+
+func linkError(op string, oldPath string, newPath string, err error) error {
+	return &os.LinkError{Op: op, Old: oldPath, New: newPath, Err: err}
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+)
+
+// The real file system
+type OS struct{}
+
+func (OS) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (OS) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (OS) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (OS) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+func (OS) AppendFile(path string, data []byte, perm fs.FileMode) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (OS) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
+func (OS) Readlink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+func (OS) Symlink(target string, path string) error {
+	return os.Symlink(target, path)
+}
+
+func (OS) Link(existingPath string, path string) error {
+	return os.Link(existingPath, path)
+}
+
+func (OS) Rename(oldPath string, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (OS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (OS) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (OS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OS) Chmod(path string, mode fs.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+func (OS) FileId(path string, followSymlinks bool) (FileId, uint64, error) {
+	var info fs.FileInfo
+	var err error
+	if followSymlinks {
+		info, err = os.Stat(path)
+	} else {
+		info, err = os.Lstat(path)
+	}
+	if err != nil {
+		return FileId{}, 0, err
+	}
+	return fileIdFromInfo(path, info)
+}
+
+func (OS) Getwd() (string, error) {
+	return os.Getwd()
+}
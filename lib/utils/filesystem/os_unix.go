@@ -0,0 +1,17 @@
+//go:build darwin || linux || freebsd || openbsd || dragonfly || netbsd
+
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+func fileIdFromInfo(path string, info fs.FileInfo) (FileId, uint64, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileId{}, 0, fmt.Errorf("failed to cast info.Sys() to *syscall.Stat_t for path: %s", path)
+	}
+	return FileId{Inode: stat.Ino, Dev: uint64(stat.Dev)}, uint64(stat.Nlink), nil
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+func fileIdFromInfo(path string, _ fs.FileInfo) (FileId, uint64, error) {
+	return FileId{}, 0, fmt.Errorf("file ids are not supported on Windows: %s", path)
+}
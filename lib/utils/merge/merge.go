@@ -0,0 +1,167 @@
+package merge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Format int
+
+const (
+	FORMAT_JSON Format = iota
+	FORMAT_TOML
+	FORMAT_YAML
+)
+
+// Returns the structured format of a file based on its extension
+func FormatFromPath(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FORMAT_JSON, true
+	case ".toml":
+		return FORMAT_TOML, true
+	case ".yaml", ".yml":
+		return FORMAT_YAML, true
+	default:
+		return 0, false
+	}
+}
+
+func Parse(format Format, contents []byte) (map[string]any, error) {
+	document := make(map[string]any)
+	var err error
+	switch format {
+	case FORMAT_JSON:
+		decoder := json.NewDecoder(bytes.NewReader(stripJsonComments(contents)))
+		decoder.UseNumber()
+		err = decoder.Decode(&document)
+	case FORMAT_TOML:
+		err = toml.Unmarshal(contents, &document)
+	case FORMAT_YAML:
+		err = yaml.Unmarshal(contents, &document)
+	default:
+		err = fmt.Errorf("unsupported format %d", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		// Empty YAML documents decode to nil
+		document = make(map[string]any)
+	}
+	return document, nil
+}
+
+// Removes the comments and trailing commas of JSONC files (such as the settings of VS Code), so that they can be parsed
+// as plain JSON. Comments are replaced with spaces (keeping the newlines), so that error offsets still match the input.
+func stripJsonComments(contents []byte) []byte {
+	result := make([]byte, 0, len(contents))
+	// Position in result of the last comma outside a string, removed if it's followed by '}' or ']'
+	lastComma := -1
+	for i := 0; i < len(contents); i++ {
+		char := contents[i]
+		switch {
+		case char == '"':
+			end := stringEnd(contents, i)
+			result = append(result, contents[i:end]...)
+			i = end - 1
+			lastComma = -1
+		case char == '/' && i+1 < len(contents) && contents[i+1] == '/':
+			for i < len(contents) && contents[i] != '\n' {
+				result = append(result, ' ')
+				i++
+			}
+			i--
+		case char == '/' && i+1 < len(contents) && contents[i+1] == '*':
+			end := bytes.Index(contents[i+2:], []byte("*/"))
+			if end < 0 {
+				// Unterminated comment, let the decoder report it
+				return append(result, contents[i:]...)
+			}
+			for _, commentChar := range contents[i : i+2+end+2] {
+				result = append(result, blankUnlessNewline(commentChar))
+			}
+			i += 2 + end + 1
+		case char == ',':
+			lastComma = len(result)
+			result = append(result, char)
+		case char == '}' || char == ']':
+			if lastComma >= 0 {
+				result[lastComma] = ' '
+			}
+			lastComma = -1
+			result = append(result, char)
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			result = append(result, char)
+		default:
+			lastComma = -1
+			result = append(result, char)
+		}
+	}
+	return result
+}
+
+// Returns the index after the closing quote of the string that starts at start, or the end of contents
+func stringEnd(contents []byte, start int) int {
+	for i := start + 1; i < len(contents); i++ {
+		switch contents[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(contents)
+}
+
+func blankUnlessNewline(char byte) byte {
+	if char == '\n' || char == '\r' {
+		return char
+	}
+	return ' '
+}
+
+func Serialize(format Format, document map[string]any) ([]byte, error) {
+	switch format {
+	case FORMAT_JSON:
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(document)
+		return buffer.Bytes(), err
+	case FORMAT_TOML:
+		return toml.Marshal(document)
+	case FORMAT_YAML:
+		return yaml.Marshal(document)
+	default:
+		return nil, fmt.Errorf("unsupported format %d", format)
+	}
+}
+
+// Applies a JSON Merge Patch (RFC 7386): objects are merged recursively, null values remove the key, and any other
+// value (including arrays) replaces the original one.
+func MergePatch(target any, patch any) any {
+	patchMap, patchIsMap := patch.(map[string]any)
+	if !patchIsMap {
+		return patch
+	}
+	targetMap, targetIsMap := target.(map[string]any)
+	if !targetIsMap {
+		targetMap = make(map[string]any, len(patchMap))
+	}
+	for key, patchValue := range patchMap {
+		if patchValue == nil {
+			delete(targetMap, key)
+		} else {
+			targetMap[key] = MergePatch(targetMap[key], patchValue)
+		}
+	}
+	return targetMap
+}
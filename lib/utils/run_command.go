@@ -9,13 +9,34 @@ import (
 	. "github.com/pol-rivero/doot/lib/types"
 )
 
+// Extra environment variables of the commands, on top of the environment of the process
+var commandEnv = map[string]string{}
+
+// Sets an environment variable for the commands run by doot (hooks, custom commands...) without modifying the
+// environment of the process. An empty value removes it.
+func SetCommandEnv(name string, value string) {
+	if value == "" {
+		delete(commandEnv, name)
+		return
+	}
+	commandEnv[name] = value
+}
+
+func commandEnviron() []string {
+	env := append(os.Environ(), "ORIGINAL_PWD="+getOriginalPwd())
+	for name, value := range commandEnv {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
 func RunCommand(pwd AbsolutePath, command string, args ...string) error {
 	cmd := exec.Command(command, args...)
 	cmd.Dir = pwd.Str()
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	cmd.Env = append(os.Environ(), "ORIGINAL_PWD="+getOriginalPwd())
+	cmd.Env = commandEnviron()
 
 	log.Info("Running command: '%s %s' (PWD: %s)", command, strings.Join(args, " "), pwd)
 	return cmd.Run()
@@ -36,3 +57,13 @@ func getOriginalPwd() string {
 	}
 	return originalPwd
 }
+
+// Runs the command and returns its standard output. The standard error is discarded.
+func RunCommandOutput(pwd AbsolutePath, command string, args ...string) ([]byte, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = pwd.Str()
+	cmd.Env = commandEnviron()
+
+	log.Info("Running command: '%s %s' (PWD: %s)", command, strings.Join(args, " "), pwd)
+	return cmd.Output()
+}
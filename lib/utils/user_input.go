@@ -9,7 +9,23 @@ const MOCK_NO_INPUT string = "__NoInput__"
 
 var USER_INPUT_MOCK_RESPONSE string = ""
 
+// Answers the questions instead of the user. options contains the accepted answers, the uppercase one is the default.
+type Prompter func(question string, options string) rune
+
+var prompter Prompter
+
+// Sets the function that answers the questions instead of reading stdin, and returns the previous one. Pass nil to ask
+// the user again.
+func SetPrompter(p Prompter) Prompter {
+	previous := prompter
+	prompter = p
+	return previous
+}
+
 func RequestInput(options string, format string, args ...interface{}) rune {
+	if prompter != nil {
+		return promptWith(prompter, options, fmt.Sprintf(format, args...))
+	}
 	suffix := fmt.Sprintf(" [%s] ", addSlashes(options))
 	fmt.Printf(format+suffix, args...)
 	defaultResponse := ensureLower(getFirstUpperRune(options))
@@ -32,6 +48,15 @@ func RequestInput(options string, format string, args ...interface{}) rune {
 	return responseRune
 }
 
+func promptWith(p Prompter, options string, question string) rune {
+	defaultResponse := ensureLower(getFirstUpperRune(options))
+	response := ensureLower(p(question, options))
+	if !strings.ContainsRune(strings.ToLower(options), response) {
+		return defaultResponse
+	}
+	return response
+}
+
 func addSlashes(s string) string {
 	var sb strings.Builder
 	for i, c := range s {
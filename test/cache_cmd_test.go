@@ -0,0 +1,167 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/cachecmd"
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/journalcmd"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheCmd_ExportImportJson(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	exportFile := filepath.Join(tempDir(t), "cache.json")
+	cachecmd.Export(exportFile, true)
+	assert.NoError(t, filesystem.Remove(cacheFile()))
+	assert.Empty(t, cache.Load().Entries)
+
+	cachecmd.Import(exportFile, true)
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".vimrc"), Content: sourceDir() + "/vimrc"},
+	})
+}
+
+func TestCacheCmd_ExportImportBinary(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	exportFile := filepath.Join(tempDir(t), "cache.bin")
+	cachecmd.Export(exportFile, false)
+	exported, err := filesystem.ReadFile(exportFile)
+	assert.NoError(t, err)
+	assert.Equal(t, readFile(cacheFile()), string(exported))
+
+	assert.NoError(t, filesystem.Remove(cacheFile()))
+	cachecmd.Import(exportFile, false)
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir()})
+}
+
+func TestCacheCmd_ImportKeepsOtherEntries(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	exportFile := filepath.Join(tempDir(t), "cache.json")
+	cachecmd.Export(exportFile, true)
+
+	dootCache := cache.Load()
+	dootCache.GetEntry(cache.ComputeCacheKey("/other/dotfiles", "/other/home"))
+	dootCache.Save()
+
+	cachecmd.Import(exportFile, false)
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir(), "/other/dotfiles:/other/home"})
+	cachecmd.Import(exportFile, true)
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir()})
+}
+
+func TestCacheCmd_ImportInvalidFile(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	invalidFile := filepath.Join(tempDir(t), "invalid.json")
+	assert.NoError(t, filesystem.WriteFile(invalidFile, []byte(`{"version": 9999, "entries": []}`), 0644))
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		cachecmd.Import(invalidFile, true)
+	})
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir()})
+}
+
+func TestCacheCmd_Prune(t *testing.T) {
+	oldDir := setUp_TestCacheCmd(t)
+	moveDotfilesDir(t, oldDir)
+
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	cachecmd.Prune()
+	assertHomeDirContents(t, "", []string{})
+	assertCacheKeys(t, []string{})
+}
+
+func TestCacheCmd_PruneUndo(t *testing.T) {
+	oldDir := setUp_TestCacheCmd(t)
+	newDir := moveDotfilesDir(t, oldDir)
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	cachecmd.Prune()
+	assertHomeDirContents(t, "", []string{})
+
+	assert.NoError(t, filesystem.Rename(newDir, oldDir))
+	op := journalcmd.Undo()
+	assert.Equal(t, "cache prune", op.Command)
+	assertHomeSymlink(t, ".bashrc", oldDir+"/bashrc")
+	assertHomeSymlink(t, ".vimrc", oldDir+"/vimrc")
+	assertCacheKeys(t, []string{oldDir + ":" + homeDir()})
+}
+
+func TestCacheCmd_PruneKeepLinks(t *testing.T) {
+	oldDir := setUp_TestCacheCmd(t)
+	moveDotfilesDir(t, oldDir)
+
+	utils.USER_INPUT_MOCK_RESPONSE = "n"
+	cachecmd.Prune()
+	assertHomeDirContents(t, "", []string{".bashrc", ".vimrc"})
+	assertCacheKeys(t, []string{})
+}
+
+func TestCacheCmd_PruneKeepsLinksIntoSiblingDirs(t *testing.T) {
+	oldDir := setUp_TestCacheCmd(t)
+	assert.NoError(t, filesystem.Symlink(oldDir+"2/sibling", homeDir()+"/.sibling"))
+	dootCache := cache.Load()
+	entry := dootCache.GetEntry(cache.ComputeCacheKey(NewAbsolutePath(oldDir), homeDir()))
+	entry.Links = append(entry.Links, &cache.InstalledFile{Path: homeDir() + "/.sibling", Content: oldDir + "2/sibling"})
+	dootCache.Save()
+	moveDotfilesDir(t, oldDir)
+
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	cachecmd.Prune()
+	assertHomeDirContents(t, "", []string{".sibling"})
+	assertCacheKeys(t, []string{})
+}
+
+func TestCacheCmd_InvalidCacheKey(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	dootCache := cache.Load()
+	dootCache.GetEntry("/not/a/valid/key")
+	dootCache.Save()
+
+	cachecmd.Prune()
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir(), "/not/a/valid/key"})
+
+	// The other entries are still exported
+	exportFile := filepath.Join(tempDir(t), "cache.json")
+	cachecmd.Export(exportFile, true)
+	assert.NoError(t, filesystem.Remove(cacheFile()))
+	cachecmd.Import(exportFile, false)
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir()})
+}
+
+func TestCacheCmd_PruneNothing(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	cachecmd.Prune()
+	assertCacheKeys(t, []string{sourceDir() + ":" + homeDir()})
+}
+
+func TestCacheCmd_Rebuild(t *testing.T) {
+	setUp_TestCacheCmd(t)
+	assert.NoError(t, filesystem.Remove(cacheFile()))
+
+	cachecmd.Rebuild()
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".vimrc"), Content: sourceDir() + "/vimrc"},
+	})
+}
+
+// Returns the dotfiles dir
+func setUp_TestCacheCmd(t *testing.T) string {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+		File("vimrc"),
+	})
+	install.Install(false)
+	return sourceDir()
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/cache"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,8 +38,8 @@ func TestCache_SaveAndLoad(t *testing.T) {
 	cacheObj.Save()
 
 	// Check that the cache file was created and is not empty
-	assert.FileExists(t, cacheFile(), "Cache file not created")
-	bytes, err := os.ReadFile(cacheFile())
+	assertFileExists(t, cacheFile(), "Cache file not created")
+	bytes, err := filesystem.ReadFile(cacheFile())
 	assert.NoError(t, err, "Error reading cache file")
 	assert.NotEmpty(t, bytes, "Cache file is empty")
 
@@ -82,7 +84,7 @@ func TestCache_VersionMismatch(t *testing.T) {
 
 func TestCache_MalformedCache(t *testing.T) {
 	SetUp(t, true)
-	err := os.WriteFile(cacheFile(), []byte("This is not a cache file"), 0644)
+	err := filesystem.WriteFile(cacheFile(), []byte("This is not a cache file"), 0644)
 	assert.NoError(t, err, "Error writing cache file")
 
 	// Load the cache again and check that it was reset
@@ -96,6 +98,60 @@ func TestCache_DefaultsToHomeCacheDir(t *testing.T) {
 	cacheObj := cache.Load()
 	cacheObj.Save()
 
-	assert.NoFileExists(t, cacheFile(), "Cache unexpectedly saved in unset environment variable")
-	assert.FileExists(t, homeDir()+"/.cache/doot/doot-cache.bin", "Cache not saved in default location")
+	assertNoFileExists(t, cacheFile(), "Cache unexpectedly saved in unset environment variable")
+	assertFileExists(t, homeDir()+"/.cache/doot/doot-cache.bin", "Cache not saved in default location")
+}
+
+func TestCache_SaveAndLoadGeneratedFiles(t *testing.T) {
+	SetUp(t, true)
+	cacheObj := cache.Load()
+	filesCache := cacheObj.GetEntry("cacheKey1")
+	filesCache.SetGeneratedFiles(map[AbsolutePath]string{
+		"/home/user/.bashrc": "hash1",
+		"/home/user/.zshrc":  "hash2",
+	})
+	cacheObj.Save()
+
+	cacheObj = cache.Load()
+	filesCache = cacheObj.GetEntry("cacheKey1")
+	assert.Equal(t, map[AbsolutePath]string{
+		"/home/user/.bashrc": "hash1",
+		"/home/user/.zshrc":  "hash2",
+	}, filesCache.GetGeneratedFiles())
+	assert.Empty(t, filesCache.Links)
+}
+
+func TestCache_SaveAndLoadModuleOverrides(t *testing.T) {
+	SetUp(t, true)
+	cacheObj := cache.Load()
+	filesCache := cacheObj.GetEntry("cacheKey1")
+	filesCache.SetModuleOverride("nvim", true)
+	filesCache.SetModuleOverride("zsh", false)
+	filesCache.SetModuleOverride("sway", true)
+	filesCache.SetModuleOverride("sway", false)
+	cacheObj.Save()
+
+	cacheObj = cache.Load()
+	filesCache = cacheObj.GetEntry("cacheKey1")
+	enabled, disabled := filesCache.GetModuleOverrides()
+	assert.Equal(t, []string{"nvim"}, enabled)
+	assert.Equal(t, []string{"zsh", "sway"}, disabled)
+}
+
+func TestCache_SplitCacheKey(t *testing.T) {
+	dotfilesDir, targetDir := cache.SplitCacheKey(cache.ComputeCacheKey("/home/user/dots:2", "/home/user"))
+	assert.Equal(t, NewAbsolutePath("/home/user/dots:2"), dotfilesDir)
+	assert.Equal(t, "/home/user", targetDir)
+
+	dotfilesDirStr, targetDir := cache.SplitCacheKeyString(`C:\Users\user\dots;C:\Users\user`, ';')
+	assert.Equal(t, `C:\Users\user\dots`, dotfilesDirStr)
+	assert.Equal(t, `C:\Users\user`, targetDir)
+
+	dotfilesDirStr, targetDir = cache.SplitCacheKeyString(`D:\dots;2;\\server\share\home`, ';')
+	assert.Equal(t, `D:\dots;2`, dotfilesDirStr)
+	assert.Equal(t, `\\server\share\home`, targetDir)
+
+	dotfilesDirStr, targetDir = cache.SplitCacheKeyString("/dots", ':')
+	assert.Equal(t, "/dots", dotfilesDirStr)
+	assert.Equal(t, "", targetDir)
 }
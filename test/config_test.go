@@ -0,0 +1,249 @@
+package test
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_HostAndLocalOverlays(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.toml", Content: `
+diff_command = "diff"
+exclude_files = ["LICENSE"]
+implicit_dot_ignore = ["bin"]
+[hosts]
+laptop = "laptop-files"
+server = "server-files"
+`},
+			FsFile{Name: "config.laptop.toml", Content: `
+diff_command = "delta"
+"exclude_files+" = ["laptop-only"]
+[hosts]
+server = "other-server-files"
+`},
+			FsFile{Name: "config.server.toml", Content: `
+use_hardlinks = true
+`},
+			FsFile{Name: "config.local.toml", Content: `
+target_dir = "/tmp/doot-target"
+"exclude_files+" = ["local-only"]
+implicit_dot_ignore = []
+`},
+		}),
+	})
+
+	config := config.FromDotfilesDir(sourceDirPath())
+	assert.Equal(t, "delta", config.DiffCommand)
+	assert.Equal(t, "/tmp/doot-target", config.TargetDir)
+	assert.Equal(t, []string{"LICENSE", "laptop-only", "local-only"}, config.ExcludeFiles)
+	assert.Equal(t, []string{}, config.ImplicitDotIgnore)
+	assert.Equal(t, map[string]string{"laptop": "laptop-files", "server": "other-server-files"}, config.Hosts)
+	assert.False(t, config.UseHardlinks)
+	assert.True(t, config.ImplicitDot)
+}
+
+func TestConfig_AppendToDefaults(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.local.toml", Content: `
+"exclude_files+" = ["node_modules"]
+
+[["transforms+"]]
+type = "dot_prefix"
+`},
+		}),
+	})
+
+	config := config.FromDotfilesDir(sourceDirPath())
+	assert.Equal(t, []string{"**/.*", "LICENSE", "README.md", "node_modules"}, config.ExcludeFiles)
+	assert.Len(t, config.Transforms, 1)
+	assert.Equal(t, "dot_prefix", config.Transforms[0].Type)
+	assert.Equal(t, homeDir(), config.TargetDir)
+}
+
+func TestConfig_ReportUnknownKeys(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.toml", Content: `implicit_dot = false
+exclude_file = ["LICENSE"]
+"rules+" = ["!keep"]
+"implicit_dot+" = [true]
+
+[[transforms]]
+type = "dot_prefix"
+prefx = "_"
+`},
+			FsFile{Name: "config.laptop.toml", Content: `[hostss]
+laptop = "laptop-files"
+`},
+		}),
+	})
+
+	result := config.LoadFromDotfilesDir(sourceDirPath())
+	configFile := sourceDir() + "/doot/config.toml"
+	hostConfigFile := sourceDir() + "/doot/config.laptop.toml"
+	assert.Equal(t, []AbsolutePath{AbsolutePath(configFile), AbsolutePath(hostConfigFile)}, result.Files)
+	problems := make([]string, 0)
+	for _, problem := range result.Problems {
+		problems = append(problems, problem.String())
+	}
+	assert.ElementsMatch(t, []string{
+		configFile + ":2:1: unknown key 'exclude_file'",
+		configFile + ":4:2: unknown key 'implicit_dot+'",
+		configFile + ":8:1: unknown key 'transforms.prefx'",
+		hostConfigFile + ":1:2: unknown key 'hostss'",
+		"Invalid config: can't append to 'implicit_dot' because it's not a list",
+	}, problems)
+	assert.False(t, result.Config.ImplicitDot)
+	assert.Equal(t, []string{"!keep"}, result.Config.Rules)
+
+	log.PanicInsteadOfExit = true
+	assert.Panics(t, func() {
+		config.FromDotfilesDir(sourceDirPath())
+	})
+}
+
+func TestConfig_ReportSyntaxAndTypeErrors(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.toml", Content: "use_hardlinks = \"yes\"\n"},
+			FsFile{Name: "config.local.toml", Content: "exclude_files = [\n"},
+		}),
+	})
+
+	result := config.LoadFromDotfilesDir(sourceDirPath())
+	assert.Len(t, result.Problems, 2)
+	assert.Equal(t, sourceDirPath().Join("doot/config.toml"), result.Problems[0].File)
+	assert.Equal(t, 1, result.Problems[0].Line)
+	assert.Contains(t, result.Problems[0].Message, "UseHardlinks")
+	assert.Equal(t, sourceDirPath().Join("doot/config.local.toml"), result.Problems[1].File)
+	assert.NotZero(t, result.Problems[1].Line)
+}
+
+func TestConfig_ValidConfigHasNoProblems(t *testing.T) {
+	validConfig := config.DefaultConfig()
+	validConfig.Hosts = map[string]string{"laptop": "laptop-files"}
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(validConfig),
+		}),
+	})
+
+	result := config.LoadFromDotfilesDir(sourceDirPath())
+	assert.Empty(t, result.Problems)
+	assert.Len(t, result.Files, 1)
+}
+
+func TestConfig_JSONSchema(t *testing.T) {
+	var schema map[string]any
+	err := json.Unmarshal(config.JSONSchema(), &schema)
+	assert.NoError(t, err)
+	properties := schema["properties"].(map[string]any)
+	assert.Contains(t, properties, "target_dir")
+	assert.Contains(t, properties, "exclude_files+")
+	assert.NotContains(t, properties, "implicit_dot+")
+	assert.Equal(t, "boolean", properties["implicit_dot"].(map[string]any)["type"])
+	transformItems := properties["transforms"].(map[string]any)["items"].(map[string]any)
+	assert.Contains(t, transformItems["properties"], "reverse_pattern")
+}
+
+func TestConfig_ExpandVariables(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	t.Setenv("DOOT_TEST_DIR", "/tmp/doot-vars")
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.toml", Content: `
+target_dir = "${DOOT_TEST_DIR}/{{ profile }}"
+exclude_files = ["{{os}}-only", "cost-$$5", "{{private}}/**"]
+rules = ["!$DOOT_TEST_DIR"]
+[hosts]
+"*" = "hosts/{{hostname}}"
+[modules]
+work = "modules/{{profile}}"
+[vars]
+profile = "work"
+private = "secrets-$USER"
+
+[[transforms]]
+type = "regex"
+pattern = '^(.+)\.{{os}}$'
+replacement = "$1"
+`},
+		}),
+	})
+	t.Setenv("USER", "someone")
+
+	result := config.LoadFromDotfilesDir(sourceDirPath())
+	assert.Empty(t, result.Problems)
+	config := result.Config
+	assert.Equal(t, "/tmp/doot-vars/work", config.TargetDir)
+	assert.Equal(t, []string{runtime.GOOS + "-only", "cost-$5", "secrets-someone/**"}, config.ExcludeFiles)
+	assert.Equal(t, []string{"!/tmp/doot-vars"}, config.Rules)
+	assert.Equal(t, map[string]string{"*": "hosts/laptop"}, config.Hosts)
+	assert.Equal(t, map[string]string{"work": "modules/work"}, config.Modules)
+	assert.Equal(t, `^(.+)\.`+runtime.GOOS+`$`, config.Transforms[0].Pattern)
+	assert.Equal(t, "$1", config.Transforms[0].Replacement)
+}
+
+func TestConfig_UndefinedVariables(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.toml", Content: `
+diff_command = "$DOOT_UNDEFINED_VARIABLE --color"
+exclude_files = ["{{ nope }}", "{{unterminated"]
+`},
+		}),
+	})
+
+	result := config.LoadFromDotfilesDir(sourceDirPath())
+	problems := make([]string, 0)
+	for _, problem := range result.Problems {
+		problems = append(problems, problem.String())
+	}
+	assert.Equal(t, []string{
+		"Invalid config: environment variable 'DOOT_UNDEFINED_VARIABLE' is not set, but it's used in 'diff_command'",
+		"Invalid config: undefined variable 'nope' in 'exclude_files[0]'",
+		"Invalid config: unterminated '{{' in 'exclude_files[1]'",
+	}, problems)
+}
+
+func TestConfig_InvalidTransforms(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			FsFile{Name: "config.toml", Content: `
+[[transforms]]
+type = "dot_prefix"
+
+[[transforms]]
+type = "regex"
+pattern = "(unclosed"
+
+[[transforms]]
+type = "strip_marker"
+
+[[transforms]]
+type = "uppercase"
+`},
+		}),
+	})
+
+	result := config.LoadFromDotfilesDir(sourceDirPath())
+	problems := make([]string, 0)
+	for _, problem := range result.Problems {
+		problems = append(problems, problem.String())
+	}
+	assert.Len(t, problems, 3)
+	assert.Contains(t, problems[0], "'transforms[1]': invalid 'pattern' \"(unclosed\"")
+	assert.Equal(t, "Invalid config: 'transforms[2]': 'marker' is required for strip_marker transforms", problems[1])
+	assert.Equal(t, "Invalid config: 'transforms[3]': unknown type \"uppercase\", expected one of: dot_prefix, strip_marker, regex", problems[2])
+}
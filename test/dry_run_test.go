@@ -0,0 +1,71 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/engine"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun_Install(t *testing.T) {
+	setUp_TestUndo(t)
+	createNode(homeDir(), FsFile{Name: ".bashrc", Content: "my old bashrc"})
+	questions := []string{}
+	e := engine.New(engine.Options{
+		DryRun: true,
+		Logger: &recordingLogger{},
+		Prompter: func(question string, options string) rune {
+			questions = append(questions, question)
+			return 'y'
+		},
+	})
+
+	changes, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	// The conflict is detected and reported like in a real run
+	assert.Len(t, questions, 1)
+	assert.Contains(t, questions[0], homeDir()+"/.bashrc")
+	assert.ElementsMatch(t, []AbsolutePath{
+		NewAbsolutePath(homeDir()).Join(".bashrc"),
+		NewAbsolutePath(homeDir()).Join(".config/app/settings"),
+	}, changes.Added)
+
+	assertHomeDirContents(t, "", []string{".bashrc"})
+	assertHomeRegularFile(t, ".bashrc")
+	assert.Equal(t, "my old bashrc", readFile(homeDir()+"/.bashrc"))
+	assertCache(t, []AssertCacheEntry{})
+	assert.Empty(t, journal.Load())
+}
+
+func TestDryRun_Clean(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	e := engine.New(engine.Options{DryRun: true, Logger: &recordingLogger{}})
+
+	changes, err := e.Clean(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, changes.Removed, 2)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	assertHomeSymlink(t, ".config/app/settings", sourceDir()+"/config/app/settings")
+	assert.Len(t, journal.Load(), 1)
+}
+
+func TestDryRun_HooksAreNotRun(t *testing.T) {
+	runOnDisk(t)
+	setUp_TestUndo(t)
+	createHookFile("after-update", "after.sh", `#!/bin/bash
+		echo "after" >> after.txt`)
+	logger := &recordingLogger{}
+	e := engine.New(engine.Options{DryRun: true, Logger: logger})
+
+	changes, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, changes.Added, 2)
+	assertHomeDirContents(t, "", []string{})
+	assertNoFileExists(t, sourceDir()+"/after.txt")
+	assertCache(t, []AssertCacheEntry{})
+	assert.Contains(t, logger.printed, "Dry run: no files were modified")
+}
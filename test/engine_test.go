@@ -0,0 +1,163 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/engine"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	printed []string
+	errors  []string
+}
+
+func (l *recordingLogger) Info(message string)    {}
+func (l *recordingLogger) Print(message string)   { l.printed = append(l.printed, message) }
+func (l *recordingLogger) Warning(message string) {}
+func (l *recordingLogger) Error(message string)   { l.errors = append(l.errors, message) }
+
+func TestEngine_ExplicitDirectories(t *testing.T) {
+	setUp_TestUndo(t)
+	dotfilesDir := tempDir(t)
+	targetDir := tempDir(t)
+	explicitCacheDir := tempDir(t)
+	createNode(dotfilesDir, File("vimrc"))
+	logger := &recordingLogger{}
+	e := engine.New(engine.Options{
+		DotfilesDir: dotfilesDir,
+		TargetDir:   targetDir,
+		CacheDir:    explicitCacheDir,
+		Logger:      logger,
+	})
+
+	changes, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AbsolutePath{NewAbsolutePath(targetDir).Join(".vimrc")}, changes.Added)
+	assert.Empty(t, changes.Removed)
+	assert.NotEmpty(t, logger.printed)
+	linkContent, err := filesystem.Readlink(filepath.Join(targetDir, ".vimrc"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dotfilesDir, "vimrc"), linkContent)
+	assertFileExists(t, filepath.Join(explicitCacheDir, "doot-cache.bin"))
+
+	// The settings of the engine don't leak into other commands
+	assertNoFileExists(t, cacheFile())
+	assertHomeDirContents(t, "", []string{})
+	assert.NotEqual(t, dotfilesDir, os.Getenv(common.ENV_DOOT_DIR))
+
+	changes, err = e.Clean(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AbsolutePath{NewAbsolutePath(targetDir).Join(".vimrc")}, changes.Removed)
+	assertNoFileExists(t, filepath.Join(targetDir, ".vimrc"))
+}
+
+func TestEngine_FatalErrorsAreReturned(t *testing.T) {
+	setUp_TestUndo(t)
+	logger := &recordingLogger{}
+	e := engine.New(engine.Options{Logger: logger})
+
+	_, err := e.Rollback(0)
+	var fatalErr *log.FatalError
+	assert.ErrorAs(t, err, &fatalErr)
+	assert.Equal(t, []string{"There is no previous generation to roll back to"}, logger.errors)
+
+	// The engine can still be used after an error
+	changes, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, changes.Added, 2)
+}
+
+func TestEngine_FatalErrorRollsBack(t *testing.T) {
+	runOnDisk(t)
+	setUp_TestUndo(t)
+	createHookFile("after-update", "after.sh", `#!/bin/bash
+		exit 1`)
+	e := engine.New(engine.Options{Logger: &recordingLogger{}})
+
+	_, err := e.Install(engine.InstallOptions{})
+	assert.Error(t, err)
+	assertHomeDirContents(t, "", []string{})
+	assertCache(t, []AssertCacheEntry{})
+}
+
+func TestEngine_Prompter(t *testing.T) {
+	setUp_TestUndo(t)
+	createNode(homeDir(), FsFile{Name: ".bashrc", Content: "my old bashrc"})
+	questions := []string{}
+	e := engine.New(engine.Options{
+		Logger: &recordingLogger{},
+		Prompter: func(question string, options string) rune {
+			questions = append(questions, question)
+			return 'y'
+		},
+	})
+
+	_, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, questions, 1)
+	assert.Contains(t, questions[0], homeDir()+"/.bashrc")
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+
+	restored, err := e.Restore([]string{homeDir() + "/.bashrc"})
+	assert.NoError(t, err)
+	assert.Equal(t, []AbsolutePath{NewAbsolutePath(homeDir()).Join(".bashrc")}, restored)
+	assertHomeRegularFile(t, ".bashrc")
+
+	op, err := e.Undo()
+	assert.NoError(t, err)
+	assert.Equal(t, "restore", op.Command)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+}
+
+func TestEngine_ExplicitConfig(t *testing.T) {
+	setUp_TestUndo(t)
+	cfg := config.DefaultConfig()
+	cfg.ExcludeFiles = []string{"config/**"}
+	e := engine.New(engine.Options{Config: &cfg, Logger: &recordingLogger{}})
+
+	changes, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []AbsolutePath{NewAbsolutePath(homeDir()).Join(".bashrc")}, changes.Added)
+	assertHomeDirContents(t, "", []string{".bashrc"})
+}
+
+func TestEngine_ExplicitConfigIsNotModified(t *testing.T) {
+	setUp_TestUndo(t)
+	cfg := config.DefaultConfig()
+	cfg.ExcludeFiles = []string{"config/**", "a$$b"}
+	cfg.Hosts = map[string]string{"my-host": "$$dir"}
+	cfg.HostGroups = map[string][]string{"group": {"$$host"}}
+	e := engine.New(engine.Options{Config: &cfg, Logger: &recordingLogger{}})
+
+	for range 3 {
+		_, err := e.Install(engine.InstallOptions{})
+		assert.NoError(t, err)
+		assertHomeDirContents(t, "", []string{".bashrc"})
+	}
+	assert.Equal(t, []string{"config/**", "a$$b"}, cfg.ExcludeFiles)
+	assert.Equal(t, map[string]string{"my-host": "$$dir"}, cfg.Hosts)
+	assert.Equal(t, map[string][]string{"group": {"$$host"}}, cfg.HostGroups)
+}
+
+func TestEngine_HooksSeeTheDotfilesDir(t *testing.T) {
+	runOnDisk(t)
+	setUp_TestUndo(t)
+	createHookFile("after-update", "after.sh", `#!/bin/bash
+		echo "$DOOT_DIR" > doot_dir.txt`)
+	dotfilesDir := sourceDir()
+	t.Setenv(common.ENV_DOOT_DIR, "")
+	e := engine.New(engine.Options{DotfilesDir: dotfilesDir, Logger: &recordingLogger{}})
+
+	_, err := e.Install(engine.InstallOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, dotfilesDir+"\n", readFile(filepath.Join(dotfilesDir, "doot_dir.txt")))
+	assert.Equal(t, "", os.Getenv(common.ENV_DOOT_DIR))
+}
@@ -16,10 +16,8 @@ func TestFileFilter_CreateFilter1(t *testing.T) {
 		IncludeFiles: []string{"file2"},
 	}
 	filter := install.CreateFilter(config, false)
-	assert.True(t, filter.IgnoreHidden, "Expected IgnoreHidden to be true")
 	assert.False(t, filter.IgnoreDootCrypt, "Expected IgnoreDootCrypt to be false")
-	assert.Equal(t, filter.ExcludeGlobs.Len(), 1, "Expected 1 exclude glob")
-	assert.Equal(t, filter.IncludeGlobs.Len(), 1, "Expected 1 include glob")
+	assert.Equal(t, 3, filter.Rules.Len(), "Expected 2 exclude rules and 1 include rule")
 }
 
 func TestFileFilter_CreateFilter2(t *testing.T) {
@@ -29,20 +27,32 @@ func TestFileFilter_CreateFilter2(t *testing.T) {
 		IncludeFiles: []string{"file2", invalidGlob},
 	}
 	filter := install.CreateFilter(config, true)
-	assert.False(t, filter.IgnoreHidden, "Expected IgnoreHidden to be false")
 	assert.True(t, filter.IgnoreDootCrypt, "Expected IgnoreDootCrypt to be true")
-	assert.Equal(t, filter.ExcludeGlobs.Len(), 2, "Expected 2 exclude globs")
-	assert.Equal(t, filter.IncludeGlobs.Len(), 1, "Expected 1 include glob (the other one is invalid)")
+	assert.Equal(t, 3, filter.Rules.Len(), "Expected 2 exclude rules and 1 include rule (the other one is invalid)")
+}
+
+func TestFileFilter_CreateFilterWithRules(t *testing.T) {
+	config := &config.Config{
+		ExcludeFiles: []string{"**/.*", "file1"},
+		IncludeFiles: []string{"file2"},
+		Rules:        []string{"dir", "!dir/a", "dir/a/b", "!dir/a/b/c"},
+	}
+	filter := install.CreateFilter(config, false)
+	assert.Equal(t, 4, filter.Rules.Len(), "rules should replace exclude_files and include_files")
+	assert.True(t, filter.IsPathIncluded("file1"))
+	assert.False(t, filter.IsPathIncluded("dir/file"))
+	assert.True(t, filter.IsPathIncluded("dir/a/file"))
+	assert.False(t, filter.IsPathIncluded("dir/a/b/file"))
+	assert.True(t, filter.IsPathIncluded("dir/a/b/c"))
+	assert.True(t, filter.IsPathIncluded("dir/a/b/c/file"))
 }
 
 var SCAN_DIR_TESTS = []func(*testing.T){
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{}),
+			Rules:               glob_collection.NewRuleList([]string{}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		expectedFiles := []RelativePath{
@@ -66,11 +76,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        true,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{}),
+			Rules:               glob_collection.NewRuleList([]string{"**/.*"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		expectedFiles := []RelativePath{
@@ -88,11 +96,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     true,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{}),
+			Rules:               glob_collection.NewRuleList([]string{}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		expectedFiles := []RelativePath{
@@ -110,11 +116,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        true,
 			IgnoreDootCrypt:     true,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{}),
+			Rules:               glob_collection.NewRuleList([]string{"**/.*"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		expectedFiles := []RelativePath{
@@ -127,11 +131,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{"secret*"}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{"*.txt", "**/file6"}),
+			Rules:               glob_collection.NewRuleList([]string{"secret*", "!*.txt", "!**/file6"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		assert.NotContains(t, files, RelativePath("secret2.doot-crypt"), "Excluded because it starts with 'secret'")
@@ -141,11 +143,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{"secret*/**"}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{"secret*/nested.doot-crypt", "**/file6"}),
+			Rules:               glob_collection.NewRuleList([]string{"secret*/**", "!secret*/nested.doot-crypt", "!**/file6"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		assert.NotContains(t, files, RelativePath("secret-dir1.doot-crypt/file5"), "Excluded because it starts with 'secret'")
@@ -155,11 +155,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{"secret*/**"}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{"secret*/nested.doot-crypt**"}),
+			Rules:               glob_collection.NewRuleList([]string{"secret*/**", "!secret*/nested.doot-crypt**"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		assert.NotContains(t, files, RelativePath("secret-dir1.doot-crypt/file5"), "Excluded because it starts with 'secret'")
@@ -169,11 +167,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: true,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{"secret*", "dir1"}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{"**/file6", "dir1/nestedDir"}),
+			Rules:               glob_collection.NewRuleList([]string{"secret*", "dir1", "!**/file6", "!dir1/nestedDir"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		assert.Contains(t, files, RelativePath("secret-dir2.doot-crypt.d/nested.doot-crypt/file6"), "Excluded directories should be explored")
@@ -182,11 +178,9 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: false,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{"**/file2", ".hiddenDir/**/file4", "dir1/nestedDir/**"}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{"dir1/nestedDir/**/file3"}),
+			Rules:               glob_collection.NewRuleList([]string{"**/file2", ".hiddenDir/**/file4", "dir1/nestedDir/**", "!dir1/nestedDir/**/file3"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		assert.Contains(t, files, RelativePath("file1"), "Should not have excluded file1")
@@ -199,17 +193,46 @@ var SCAN_DIR_TESTS = []func(*testing.T){
 
 	func(t *testing.T) {
 		filter := install.FileFilter{
-			IgnoreHidden:        false,
 			IgnoreDootCrypt:     false,
 			ExploreExcludedDirs: true,
-			ExcludeGlobs:        glob_collection.NewGlobCollection([]string{".hiddenDir"}),
-			IncludeGlobs:        glob_collection.NewGlobCollection([]string{".hiddenDir/dïrWìthÜnicóde/1?5helloö"}),
+			Rules:               glob_collection.NewRuleList([]string{".hiddenDir", "!.hiddenDir/dïrWìthÜnicóde/1?5helloö"}),
 		}
 		files := install.ScanDirectory(sourceDirPath(), &filter)
 		assert.Contains(t, files, RelativePath(".hiddenDir/dïrWìthÜnicóde/155helloö/fileABC"), "https://github.com/gobwas/glob/issues/54")
 	},
 }
 
+func TestFileFilter_ScanDirectoryWithRules(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		File("file1"),
+		Dir("X", []FsNode{
+			File("excluded"),
+			Dir("a", []FsNode{
+				File("included"),
+				Dir("b", []FsNode{
+					File("excludedAgain"),
+				}),
+			}),
+		}),
+		Dir(".hidden", []FsNode{
+			File("file2"),
+			File("file3"),
+		}),
+	})
+	filter := install.FileFilter{
+		IgnoreDootCrypt:     false,
+		ExploreExcludedDirs: false,
+		Rules:               glob_collection.NewRuleList([]string{"**/.*", "X", "!X/a", "X/a/b", "!.hidden/file2"}),
+	}
+	files := install.ScanDirectory(sourceDirPath(), &filter)
+	expectedFiles := []RelativePath{
+		"file1",
+		"X/a/included",
+		".hidden/file2",
+	}
+	assert.ElementsMatch(t, expectedFiles, files, "Excluded directories should be explored when a later rule re-includes a path inside them")
+}
+
 func TestFileFilter_ScanDirectory(t *testing.T) {
 	SetUpFiles(t, true, []FsNode{
 		File("file1"),
@@ -247,3 +270,60 @@ func TestFileFilter_ScanDirectory(t *testing.T) {
 		test(t)
 	}
 }
+
+func TestFileFilter_DootIgnore(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		FsFile{Name: ".dootignore", Content: "# Comment\n*.log\n/build/\n!keep.log\n"},
+		File("file1"),
+		File("debug.log"),
+		File("keep.log"),
+		Dir("build", []FsNode{
+			File("output"),
+		}),
+		Dir("plugin", []FsNode{
+			FsFile{Name: ".dootignore", Content: "node_modules/\ncache/**\n!important.log\n/local\n"},
+			File("init.lua"),
+			File("plugin.log"),
+			File("important.log"),
+			File("local"),
+			Dir("build", []FsNode{File("artifact")}),
+			Dir("node_modules", []FsNode{File("dependency.js")}),
+			Dir("cache", []FsNode{File("file"), Dir("nested", []FsNode{File("file")})}),
+			Dir("sub", []FsNode{File("local")}),
+		}),
+		File("build.txt"),
+	})
+	filter := install.FileFilter{
+		IgnoreDootCrypt:     false,
+		ExploreExcludedDirs: false,
+		Rules:               glob_collection.NewRuleList([]string{"**/.*"}),
+	}
+	files := install.ScanDirectory(sourceDirPath(), &filter)
+	expectedFiles := []RelativePath{
+		"file1",
+		"keep.log",
+		"build.txt",
+		"plugin/init.lua",
+		"plugin/important.log",
+		"plugin/build/artifact",
+		"plugin/sub/local",
+	}
+	assert.ElementsMatch(t, expectedFiles, files, "Unexpected files")
+}
+
+func TestFileFilter_DootIgnoreCombinedWithConfig(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		FsFile{Name: ".dootignore", Content: "!.config\n"},
+		File("README.md"),
+		File(".hidden"),
+		Dir(".config", []FsNode{File("app.conf")}),
+		File("secret.doot-crypt"),
+	})
+	filter := install.FileFilter{
+		IgnoreDootCrypt:     true,
+		ExploreExcludedDirs: false,
+		Rules:               glob_collection.NewRuleList([]string{"**/.*", "README.md"}),
+	}
+	files := install.ScanDirectory(sourceDirPath(), &filter)
+	assert.ElementsMatch(t, []RelativePath{".config/app.conf"}, files, "Negated patterns should re-include files excluded by the config, and .dootignore is never returned")
+}
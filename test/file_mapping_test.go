@@ -5,7 +5,10 @@ import (
 	"testing"
 
 	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
 	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/pol-rivero/doot/lib/common/modules"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,7 +18,7 @@ func TestFileMapping_SimpleMapping(t *testing.T) {
 		TargetDir:   "/target",
 		ImplicitDot: false,
 	}
-	mapping := install.NewFileMapping("/src", &config, []RelativePath{
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
 		"file1",
 		".dir/.file2",
 		"doot/file_that_is_internal_to_doot",
@@ -34,7 +37,7 @@ func TestFileMapping_WithImplicitDot(t *testing.T) {
 		ImplicitDot:       true,
 		ImplicitDotIgnore: []string{"dummy_value", "not_dotted"},
 	}
-	mapping := install.NewFileMapping("/src", &config, []RelativePath{
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
 		"file1",
 		"foo/bar",
 		".dir/.file2",
@@ -52,12 +55,117 @@ func TestFileMapping_WithImplicitDot(t *testing.T) {
 	})
 }
 
+func TestFileMapping_ImplicitDotRules(t *testing.T) {
+	config := config.Config{
+		TargetDir:         "/target",
+		ImplicitDot:       true,
+		ImplicitDotIgnore: []string{"bin", "local/bin"},
+		ImplicitDotRules:  []string{"local/share/*", "!local/share/fonts", "**/dotted_anywhere"},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"bin/script",
+		"local/bin/tool",
+		"local/share/app/data",
+		"local/share/fonts/font.ttf",
+		"foo/bar/dotted_anywhere",
+		"dotted_anywhere",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/bin/script":                  "/src/bin/script",
+		"/target/.local/bin/tool":             "/src/local/bin/tool",
+		"/target/.local/share/.app/data":      "/src/local/share/app/data",
+		"/target/.local/share/fonts/font.ttf": "/src/local/share/fonts/font.ttf",
+		"/target/.foo/bar/.dotted_anywhere":   "/src/foo/bar/dotted_anywhere",
+		"/target/.dotted_anywhere":            "/src/dotted_anywhere",
+	})
+}
+
+func TestFileMapping_ImplicitDotRulesWithoutImplicitDot(t *testing.T) {
+	config := config.Config{
+		TargetDir:        "/target",
+		ImplicitDot:      false,
+		ImplicitDotRules: []string{"config", "config/nested"},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"file1",
+		"config/nested/file2",
+		"config/other/file3",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/file1":                 "/src/file1",
+		"/target/.config/.nested/file2": "/src/config/nested/file2",
+		"/target/.config/other/file3":   "/src/config/other/file3",
+	})
+}
+
+func TestFileMapping_Transforms(t *testing.T) {
+	config := config.Config{
+		TargetDir:   "/target",
+		ImplicitDot: false,
+		Transforms: []config.TransformConfig{
+			{Type: "dot_prefix"},
+			{Type: "strip_marker", Marker: ".tmpl"},
+			{Type: "regex", Pattern: `^(.+)\.linux$`, Replacement: "$1"},
+		},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"dot-bashrc",
+		"dot-config/nvim/init.lua",
+		"dot-config/app/settings.tmpl",
+		"bin/script.linux",
+		"dot-",
+		"not-dot-file",
+		"secret.doot-crypt.tmpl",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/.bashrc":               "/src/dot-bashrc",
+		"/target/.config/nvim/init.lua": "/src/dot-config/nvim/init.lua",
+		"/target/.config/app/settings":  "/src/dot-config/app/settings.tmpl",
+		"/target/bin/script":            "/src/bin/script.linux",
+		"/target/dot-":                  "/src/dot-",
+		"/target/not-dot-file":          "/src/not-dot-file",
+		"/target/secret":                "/src/secret.doot-crypt.tmpl",
+	})
+}
+
+func TestFileMapping_InvalidTransform(t *testing.T) {
+	config := config.Config{
+		TargetDir: "/target",
+		Transforms: []config.TransformConfig{
+			{Type: "regex", Pattern: "(unclosed"},
+		},
+	}
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{"file"})
+	})
+}
+
+func TestFileMapping_TransformsBeforeImplicitDot(t *testing.T) {
+	config := config.Config{
+		TargetDir:   "/target",
+		ImplicitDot: true,
+		Transforms: []config.TransformConfig{
+			{Type: "dot_prefix", Prefix: "_"},
+		},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"_zshrc",
+		"config/_hidden/file",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/.zshrc":               "/src/_zshrc",
+		"/target/.config/.hidden/file": "/src/config/_hidden/file",
+	})
+}
+
 func TestFileMapping_WithDootCrypt(t *testing.T) {
 	config := config.Config{
 		TargetDir:   "/target",
 		ImplicitDot: false,
 	}
-	mapping := install.NewFileMapping("/src", &config, []RelativePath{
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
 		"file1.doot-crypt",
 		"file2.doot-crypt.txt",
 		"dirA.doot-crypt/dirB.doot-crypt.d/file3.doot-crypt",
@@ -74,7 +182,7 @@ func TestFileMapping_ConflictingNames(t *testing.T) {
 		TargetDir:   "/target",
 		ImplicitDot: false,
 	}
-	mapping := install.NewFileMapping("/src", &config, []RelativePath{
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
 		"file1.doot-crypt",
 		"file1",
 		"file2.txt",
@@ -98,7 +206,7 @@ func TestFileMapping_HostSpecificDirs(t *testing.T) {
 			"other_host2": "OTHER2",
 		},
 	}
-	mapping := install.NewFileMapping("/src", &config, []RelativePath{
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
 		"OTHER/file1",
 		"OTHER/file-that-should-be-ignored",
 		"doot/other-file-that-should-be-ignored",
@@ -129,7 +237,7 @@ func TestFileMapping_NestedHostSpecificDirs(t *testing.T) {
 			"other_host": "hosts/ignore",
 		},
 	}
-	mapping := install.NewFileMapping("/src", &config, []RelativePath{
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
 		"dir/file3",
 		"hosts/host/file1",
 		"hosts/host/.file2",
@@ -147,3 +255,83 @@ func TestFileMapping_NestedHostSpecificDirs(t *testing.T) {
 		"/target/.some/other/file": "/src/hosts/host/some/other/file",
 	})
 }
+
+func TestFileMapping_HostnameOverride(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "my-container")
+	config := config.Config{
+		TargetDir:   "/target",
+		ImplicitDot: false,
+		Hosts: map[string]string{
+			"my-container": "CONTAINER",
+		},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"file1",
+		"CONTAINER/file1",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/file1": "/src/CONTAINER/file1",
+	})
+}
+
+func TestFileMapping_HostPatternsAndGroups(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "ci-42.corp.example")
+	config := config.Config{
+		TargetDir:   "/target",
+		ImplicitDot: false,
+		HostGroups: map[string][]string{
+			"work":    {"laptop", "*.corp.example"},
+			"servers": {"server-*"},
+		},
+		Hosts: map[string]string{
+			"@work":              "WORK",
+			"@servers":           "SERVERS",
+			"/^ci-[0-9]+\\./":    "CI",
+			"ci-42.corp.example": "HOST",
+			"other-host":         "OTHER",
+		},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"file1",
+		"file2",
+		"file3",
+		"file4",
+		"WORK/file2",
+		"WORK/file3",
+		"WORK/file4",
+		"CI/file3",
+		"CI/file4",
+		"HOST/file4",
+		"SERVERS/file5",
+		"OTHER/file6",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/file1": "/src/file1",
+		"/target/file2": "/src/WORK/file2",
+		"/target/file3": "/src/CI/file3",
+		"/target/file4": "/src/HOST/file4",
+	})
+}
+
+func TestFileMapping_InvalidHostPatterns(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "my-host")
+	config := config.Config{
+		TargetDir:   "/target",
+		ImplicitDot: false,
+		Hosts: map[string]string{
+			"/[/":          "BAD_REGEX",
+			"@missing":     "MISSING_GROUP",
+			"my-*":         "GLOB",
+			"[invalid-glb": "BAD_GLOB",
+		},
+	}
+	mapping := install.NewFileMapping("/src", &config, &modules.Selection{}, []RelativePath{
+		"BAD_REGEX/file1",
+		"MISSING_GROUP/file2",
+		"GLOB/file3",
+		"BAD_GLOB/file4",
+	})
+	assertSymlinkCollection(t, mapping.GetInstalledTargets(), map[AbsolutePath]AbsolutePath{
+		"/target/file3": "/src/GLOB/file3",
+	})
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesystem_MemoryHardlinks(t *testing.T) {
+	memory := filesystem.NewMemory()
+	assert.NoError(t, memory.MkdirAll("/dir", 0755))
+	assert.NoError(t, memory.WriteFile("/dir/file", []byte("contents"), 0644))
+	assert.NoError(t, memory.Link("/dir/file", "/dir/link"))
+
+	id1, links, err := memory.FileId("/dir/file", false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), links)
+	id2, _, err := memory.FileId("/dir/link", false)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, id2)
+
+	assert.NoError(t, memory.WriteFile("/dir/link", []byte("changed"), 0644))
+	contents, err := memory.ReadFile("/dir/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", string(contents))
+
+	assert.NoError(t, memory.Remove("/dir/file"))
+	_, links, err = memory.FileId("/dir/link", false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), links)
+}
+
+func TestFilesystem_MemoryMount(t *testing.T) {
+	memory := filesystem.NewMemory()
+	assert.NoError(t, memory.Mount("/other"))
+	assert.NoError(t, memory.WriteFile("/file", []byte("contents"), 0644))
+
+	assert.ErrorIs(t, memory.Link("/file", "/other/file"), syscall.EXDEV)
+	assert.ErrorIs(t, memory.Rename("/file", "/other/file"), syscall.EXDEV)
+	assert.NoError(t, memory.Link("/file", "/link"))
+}
+
+func TestFilesystem_MemorySymlinks(t *testing.T) {
+	memory := filesystem.NewMemory()
+	assert.NoError(t, memory.MkdirAll("/dir", 0755))
+	assert.NoError(t, memory.WriteFile("/dir/file", []byte("contents"), 0644))
+	assert.NoError(t, memory.Symlink("dir", "/relative"))
+	assert.NoError(t, memory.Symlink("/dangling", "/broken"))
+	assert.NoError(t, memory.Symlink("/loop2", "/loop1"))
+	assert.NoError(t, memory.Symlink("/loop1", "/loop2"))
+
+	contents, err := memory.ReadFile("/relative/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "contents", string(contents))
+	info, err := memory.Lstat("/relative")
+	assert.NoError(t, err)
+	assert.True(t, info.Mode()&os.ModeSymlink != 0)
+	info, err = memory.Stat("/relative")
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	_, err = memory.Stat("/broken")
+	assert.ErrorIs(t, err, syscall.ENOENT)
+	_, err = memory.Stat("/loop1")
+	assert.ErrorIs(t, err, syscall.ELOOP)
+	assert.ErrorIs(t, memory.Symlink("/dir", "/broken"), syscall.EEXIST)
+}
+
+func TestFilesystem_OverlayDoesNotModifyBase(t *testing.T) {
+	base := filesystem.NewMemory()
+	assert.NoError(t, base.MkdirAll("/dir", 0755))
+	assert.NoError(t, base.WriteFile("/dir/file", []byte("contents"), 0644))
+	overlay := filesystem.NewOverlay(base)
+
+	assert.NoError(t, overlay.RemoveAll("/dir"))
+	assert.NoError(t, overlay.MkdirAll("/dir/new", 0755))
+	assert.NoError(t, overlay.Symlink("/somewhere", "/dir/link"))
+	entries, err := overlay.ReadDir("/dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "link", entries[0].Name())
+	assert.Equal(t, "new", entries[1].Name())
+
+	entries, err = base.ReadDir("/dir")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "file", entries[0].Name())
+}
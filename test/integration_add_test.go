@@ -10,6 +10,7 @@ import (
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +18,7 @@ func TestAdd_BasicMapping(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	assertSourceDirContents(t, "", []string{
 		"doot",
@@ -42,7 +43,7 @@ func TestAdd_BasicMapping(t *testing.T) {
 		{NewAbsolutePath(homeDir() + "/dir1/file3"), sourceDir() + "/dir1/file3"},
 	})
 
-	assert.DirExists(t, sourceDir()+"/dir1")
+	assertDirExists(t, sourceDir()+"/dir1")
 	restore.Restore([]string{
 		"file1",
 	})
@@ -61,7 +62,7 @@ func TestAdd_BasicMapping_Hardlink(t *testing.T) {
 	config.ImplicitDot = false
 	config.UseHardlinks = true
 	setUpFiles_TestAdd(t, config, false)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	assertSourceDirContents(t, "", []string{
 		"doot",
@@ -86,7 +87,7 @@ func TestAdd_BasicMapping_Hardlink(t *testing.T) {
 		{NewAbsolutePath(homeDir() + "/dir1/file3"), sourceDir() + "/dir1/file3"},
 	})
 
-	assert.DirExists(t, sourceDir()+"/dir1")
+	assertDirExists(t, sourceDir()+"/dir1")
 	restore.Restore([]string{
 		"file1",
 	})
@@ -102,9 +103,9 @@ func TestAdd_BasicMapping_Hardlink(t *testing.T) {
 
 func TestAdd_RestoreCleansUpDirectories(t *testing.T) {
 	setUpFiles_TestAdd(t, config.DefaultConfig(), true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
-	os.RemoveAll(sourceDir() + "/doot")
+	filesystem.RemoveAll(sourceDir() + "/doot")
 	assertSourceDirContents(t, "", []string{})
 
 	add.Add([]string{
@@ -120,7 +121,7 @@ func TestAdd_RestoreCleansUpDirectories(t *testing.T) {
 		homeDir() + "/.dir2/file5",
 		sourceDir() + "/dir2/nested/nestedFile",
 	})
-	assert.DirExists(t, sourceDir())
+	assertDirExists(t, sourceDir())
 	assertSourceDirContents(t, "", []string{})
 	assertCache(t, []AssertCacheEntry{})
 }
@@ -129,7 +130,7 @@ func TestAdd_RestoreCleansUpDirectories_Hardlink(t *testing.T) {
 	config := config.DefaultConfig()
 	config.UseHardlinks = true
 	setUpFiles_TestAdd(t, config, false)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	assertSourceDirContents(t, "", []string{"doot"})
 
@@ -153,7 +154,7 @@ func TestAdd_RestoreCleansUpDirectories_Hardlink(t *testing.T) {
 func TestAdd_IncorrectInputs(t *testing.T) {
 	config := config.DefaultConfig()
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"some-nonexistent-file", // Doesn't exist
@@ -169,7 +170,7 @@ func TestAdd_FromAnotherPWD(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir() + "/dir1")
+	chdir(t, homeDir()+"/dir1")
 
 	add.Add([]string{
 		"file3",
@@ -190,7 +191,7 @@ func TestAdd_WeirdInputPath(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir() + "/dir1")
+	chdir(t, homeDir()+"/dir1")
 
 	add.Add([]string{
 		"../dir3////./../dir1//file3",
@@ -211,7 +212,7 @@ func TestAdd_ExcludeInclude(t *testing.T) {
 	config.ExcludeFiles = []string{"file1", "*.txt", "dir1", "dir3/**"}
 	config.IncludeFiles = []string{"**/file6", "file2.txt"}
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file1",                // Excluded
@@ -231,13 +232,36 @@ func TestAdd_ExcludeInclude(t *testing.T) {
 	assertHomeSymlink(t, ".dir2/.foo", sourceDir()+"/.dir2/.foo")
 }
 
+func TestAdd_Rules(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.Rules = []string{"dir1", "!dir1/nestedDir", "dir1/nestedDir/file4", "*.txt"}
+	setUpFiles_TestAdd(t, config, true)
+	chdir(t, homeDir())
+
+	add.Add([]string{
+		"file1",                // Not excluded
+		"file2.txt",            // Excluded
+		"dir1/file3",           // Parent dir excluded
+		"dir1/nestedDir/file4", // Excluded, re-included, excluded again
+		".dir2/.foo",           // Not excluded, because exclude_files is ignored when rules are set
+	}, false, false)
+	assertSourceDirContents(t, "", []string{
+		"doot",
+		"file1",
+		".dir2",
+	})
+	assertHomeSymlink(t, "file1", sourceDir()+"/file1")
+	assertHomeSymlink(t, ".dir2/.foo", sourceDir()+"/.dir2/.foo")
+}
+
 func TestAdd_ImplicitDot(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = true
 	config.ImplicitDotIgnore = []string{"file2.txt", "dir3"}
 	config.ExcludeFiles = []string{}
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file1", // Impossible filename with implicit dot
@@ -262,11 +286,85 @@ func TestAdd_ImplicitDot(t *testing.T) {
 	assertHomeSymlink(t, "dir3/file6", sourceDir()+"/dir3/file6")
 }
 
+func TestAdd_ImplicitDotRules(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = true
+	config.ImplicitDotIgnore = []string{"dir1/nestedDir"}
+	config.ImplicitDotRules = []string{"dir2/nested"}
+	config.ExcludeFiles = []string{}
+	setUpFiles_TestAdd(t, config, true)
+	chdir(t, homeDir())
+
+	add.Add([]string{
+		".dir2/nested/nestedFile", // Impossible filename, "nested" should be dotted
+		".dir2/file5",
+		"dir1/nestedDir/file4", // Impossible filename, "dir1" should be dotted
+	}, false, false)
+	assertSourceDirContents(t, "", []string{
+		"doot",
+		"dir2",
+	})
+	assertSourceDirContents(t, "dir2", []string{
+		"file5",
+	})
+
+	createNode(homeDir(), Dir(".dir2", []FsNode{Dir(".nested", []FsNode{File("file")})}))
+	add.Add([]string{".dir2/.nested/file"}, false, false)
+	assertHomeSymlink(t, ".dir2/.nested/file", sourceDir()+"/dir2/nested/file")
+}
+
+func TestAdd_Transforms(t *testing.T) {
+	transforms := []config.TransformConfig{
+		{Type: "dot_prefix"},
+		{Type: "regex", Pattern: `^(.+)\.linux$`, Replacement: "$1", ReversePattern: `^file(\d)$`, ReverseReplacement: "file$1.linux"},
+	}
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.ExcludeFiles = []string{}
+	config.Transforms = transforms
+	setUpFiles_TestAdd(t, config, true)
+	chdir(t, homeDir())
+
+	add.Add([]string{
+		".dir2/.foo",
+		"file1",
+		"dir1/file3",
+		"dir.with.dots/file-without-dots",
+	}, false, false)
+	assertSourceDirContents(t, "", []string{
+		"doot",
+		"dot-dir2",
+		"file1.linux",
+		"dir1",
+		"dir.with.dots",
+	})
+	assertSourceDirContents(t, "dot-dir2", []string{"dot-foo"})
+	assertSourceDirContents(t, "dir1", []string{"file3.linux"})
+	assertHomeSymlink(t, ".dir2/.foo", sourceDir()+"/dot-dir2/dot-foo")
+	assertHomeSymlink(t, "file1", sourceDir()+"/file1.linux")
+	assertHomeSymlink(t, "dir.with.dots/file-without-dots", sourceDir()+"/dir.with.dots/file-without-dots")
+}
+
+func TestAdd_DotPrefixWithImplicitDot(t *testing.T) {
+	transforms := []config.TransformConfig{{Type: "dot_prefix"}}
+	config := config.DefaultConfig()
+	config.ImplicitDot = true
+	config.ExcludeFiles = []string{}
+	config.Transforms = transforms
+	setUpFiles_TestAdd(t, config, true)
+	chdir(t, homeDir())
+
+	// "dot-dir2" is preferred over "dir2", even though both are installed as ".dir2"
+	add.Add([]string{".dir2/.foo"}, false, false)
+	assertSourceDirContents(t, "dot-dir2", []string{"dot-foo"})
+	assertHomeSymlink(t, ".dir2/.foo", sourceDir()+"/dot-dir2/dot-foo")
+}
+
 func TestAdd_WithCryptExtensionUninitialized(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file1",
@@ -287,7 +385,7 @@ func TestAdd_WithCryptExtension(t *testing.T) {
 	config.ExcludeFiles = []string{}
 	setUpFiles_TestAdd(t, config, true)
 	initializeGitCrypt()
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file1",
@@ -337,7 +435,7 @@ func TestAdd_ExcludeIncludeWithCrypt(t *testing.T) {
 	config.IncludeFiles = []string{"**/file6.doot-crypt", "file2.doot-crypt.txt"}
 	setUpFiles_TestAdd(t, config, true)
 	initializeGitCrypt()
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file1",                // Excluded
@@ -368,7 +466,7 @@ func TestAdd_WithCryptDirectory(t *testing.T) {
 	config.ExcludeFiles = []string{}
 	setUpFiles_TestAdd(t, config, true)
 	initializeGitCrypt()
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	createNode(sourceDir(), Dir("cryptTest.doot-crypt", []FsNode{}))
 	createNode(sourceDir(), Dir("cryptTest", []FsNode{
@@ -382,7 +480,7 @@ func TestAdd_WithCryptDirectory(t *testing.T) {
 	}, true, false)
 	assertHomeSymlink(t, "cryptTest/foo/secret1.txt", sourceDir()+"/cryptTest/foo.doot-crypt/secret1.txt")
 
-	os.RemoveAll(sourceDir() + "/cryptTest")
+	filesystem.RemoveAll(sourceDir() + "/cryptTest")
 	// Now there's no choice but to use the cryptTest.doot-crypt directory and create 'foo'
 	add.Add([]string{
 		"cryptTest/foo/secret2.txt",
@@ -409,7 +507,7 @@ func TestAdd_HostSpecificNotFound(t *testing.T) {
 		"other-host": "foo",
 	}
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	log.PanicInsteadOfExit = true
 	assert.Panics(t, func() {
@@ -432,7 +530,7 @@ func TestAdd_HostSpecificDir(t *testing.T) {
 		host:         "host/dir",
 	}
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file2.txt",
@@ -457,7 +555,7 @@ func TestAdd_AddAndRestoreASymlink(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	createSymlink(homeDir(), "my-symlink", "/some-target")
 
@@ -472,7 +570,7 @@ func TestAdd_AddAndRestoreASymlink(t *testing.T) {
 		"my-symlink",
 	})
 	assertHomeSymlink(t, "my-symlink", "/some-target")
-	assert.NoFileExists(t, sourceDir()+"/my-symlink")
+	assertNoFileExists(t, sourceDir()+"/my-symlink")
 
 }
 
@@ -480,7 +578,7 @@ func TestAdd_IsIdempotent(t *testing.T) {
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestAdd(t, config, true)
-	t.Chdir(homeDir())
+	chdir(t, homeDir())
 
 	add.Add([]string{
 		"file1",
@@ -488,9 +586,9 @@ func TestAdd_IsIdempotent(t *testing.T) {
 		"dir1/file3",
 	}, false, false)
 
-	os.Remove(homeDir() + "/file1")
+	filesystem.Remove(homeDir() + "/file1")
 	createNode(homeDir(), FsFile{Name: "file1", Content: "new content"})
-	os.Remove(homeDir() + "/file2.txt")
+	filesystem.Remove(homeDir() + "/file2.txt")
 	createSymlink(homeDir(), "file2.txt", "./file1")
 
 	utils.USER_INPUT_MOCK_RESPONSE = "n"
@@ -10,6 +10,7 @@ import (
 )
 
 func TestCustomCmd_RunCommand(t *testing.T) {
+	runOnDisk(t)
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestCustomCmd(t, config)
@@ -22,6 +23,7 @@ func TestCustomCmd_RunCommand(t *testing.T) {
 }
 
 func TestCustomCmd_OriginalPwd(t *testing.T) {
+	runOnDisk(t)
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestCustomCmd(t, config)
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/config"
+)
+
+func TestFollowSymlinks_Disabled(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	setUpFiles_TestFollowSymlinks(t, config)
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "realDir", "linkedDir", "shared"})
+	assertHomeSymlink(t, "linkedDir", sourceDir()+"/linkedDir")
+	assertHomeSymlink(t, "shared", sourceDir()+"/shared")
+}
+
+func TestFollowSymlinks_InsideAndOutsideRepo(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.FollowRepoSymlinks = true
+	setUpFiles_TestFollowSymlinks(t, config)
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "realDir", "linkedDir", "shared"})
+	assertHomeDirContents(t, "linkedDir", []string{"file2", "nested"})
+	assertHomeSymlink(t, "linkedDir/file2", sourceDir()+"/linkedDir/file2")
+	assertHomeSymlink(t, "linkedDir/nested/file3", sourceDir()+"/linkedDir/nested/file3")
+	assertHomeDirContents(t, "shared", []string{"teamFile"})
+	assertHomeSymlink(t, "shared/teamFile", sourceDir()+"/shared/teamFile")
+
+	install.Clean(false)
+	assertHomeDirContents(t, "", []string{})
+}
+
+func TestFollowSymlinks_Cycle(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.FollowRepoSymlinks = true
+	setUpFiles_TestFollowSymlinks(t, config)
+	createSymlink(sourceDir()+"/realDir/nested", "loop", sourceDir()+"/realDir")
+	createSymlink(sourceDir(), "rootLoop", ".")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "realDir", "linkedDir", "shared"})
+	assertHomeDirContents(t, "realDir/nested", []string{"file3"})
+}
+
+func TestFollowSymlinks_GitTrackedOnly(t *testing.T) {
+	runOnDisk(t)
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.FollowRepoSymlinks = true
+	config.GitTrackedOnly = true
+	setUpFiles_TestFollowSymlinks(t, config)
+	runGit("init", "--quiet")
+	runGit("add", "file1", "shared")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "shared"})
+	assertHomeSymlink(t, "shared/teamFile", sourceDir()+"/shared/teamFile")
+}
+
+func setUpFiles_TestFollowSymlinks(t *testing.T, config config.Config) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config),
+		}),
+		File("file1"),
+		Dir("realDir", []FsNode{
+			File("file2"),
+			Dir("nested", []FsNode{
+				File("file3"),
+			}),
+		}),
+	})
+	createSymlink(sourceDir(), "linkedDir", "realDir")
+	teamCheckout := tempDir(t)
+	createNode(teamCheckout, Dir("team", []FsNode{File("teamFile")}))
+	createSymlink(sourceDir(), "shared", filepath.Join(teamCheckout, "team"))
+}
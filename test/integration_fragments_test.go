@@ -0,0 +1,149 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFragments_AssembleInLexicalOrder(t *testing.T) {
+	setUpFiles_TestFragments(t, config.DefaultConfig())
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".file1"})
+	assertHomeRegularFile(t, ".bashrc")
+	assert.Equal(t, "# base\nalias ll='ls -l'\nexport EDITOR=vim\n", readFile(homeDir()+"/.bashrc"))
+	assertGeneratedCache(t, []string{homeDir() + "/.bashrc"})
+
+	install.Clean(false)
+	assertHomeDirContents(t, "", []string{})
+	assertGeneratedCache(t, []string{})
+}
+
+func TestFragments_HostSpecificFragments(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	config := config.DefaultConfig()
+	config.Hosts = map[string]string{"laptop": "hosts/laptop"}
+	setUpFiles_TestFragments(t, config)
+	createNode(sourceDir(), Dir("hosts", []FsNode{
+		Dir("laptop", []FsNode{
+			Dir("bashrc.doot-fragments", []FsNode{
+				FsFile{Name: "20-env.sh", Content: "export EDITOR=nano\n"},
+				FsFile{Name: "30-laptop.sh", Content: "export LAPTOP=1\n"},
+			}),
+		}),
+	}))
+
+	install.Install(false)
+	assert.Equal(t, "# base\nalias ll='ls -l'\nexport EDITOR=nano\nexport LAPTOP=1\n", readFile(homeDir()+"/.bashrc"))
+}
+
+func TestFragments_RegenerateWhenFragmentsChange(t *testing.T) {
+	setUpFiles_TestFragments(t, config.DefaultConfig())
+
+	install.Install(false)
+	createFile(sourceDir(), FsFile{Name: "bashrc.doot-fragments/30-path.sh", Content: "export PATH=$PATH:~/bin"})
+	install.Install(false)
+	assert.Equal(t, "# base\nalias ll='ls -l'\nexport EDITOR=vim\nexport PATH=$PATH:~/bin\n", readFile(homeDir()+"/.bashrc"))
+
+	filesystem.RemoveAll(sourceDir() + "/bashrc.doot-fragments")
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1"})
+	assertGeneratedCache(t, []string{})
+}
+
+func TestFragments_DoNotOverwriteLocalChanges(t *testing.T) {
+	setUpFiles_TestFragments(t, config.DefaultConfig())
+
+	install.Install(false)
+	filesystem.WriteFile(homeDir()+"/.bashrc", []byte("edited by hand\n"), 0644)
+	createFile(sourceDir(), FsFile{Name: "bashrc.doot-fragments/30-path.sh", Content: "export PATH=$PATH:~/bin\n"})
+
+	utils.USER_INPUT_MOCK_RESPONSE = "n"
+	install.Install(false)
+	assert.Equal(t, "edited by hand\n", readFile(homeDir()+"/.bashrc"))
+	// The file is still tracked with the hash of the previous installation, so it's detected as modified
+	dootCache := cache.Load()
+	cacheEntry := dootCache.GetEntry(sourceDir() + ":" + homeDir())
+	assert.Contains(t, cacheEntry.GetGeneratedFiles(), NewAbsolutePath(homeDir()+"/.bashrc"))
+
+	install.Clean(false)
+	assertHomeDirContents(t, "", []string{".bashrc"})
+
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	install.Install(false)
+	assert.Equal(t, "# base\nalias ll='ls -l'\nexport EDITOR=vim\nexport PATH=$PATH:~/bin\n", readFile(homeDir()+"/.bashrc"))
+	assertGeneratedCache(t, []string{homeDir() + "/.bashrc"})
+}
+
+func TestFragments_DiffWhenNotWritingToDisk(t *testing.T) {
+	runOnDisk(t)
+	scriptDir := t.TempDir()
+	diffOutput := filepath.Join(scriptDir, "diff-output")
+	diffScript := filepath.Join(scriptDir, "diff.sh")
+	assert.NoError(t, os.WriteFile(diffScript, []byte("#!/bin/sh\ncat \"$1\" \"$2\" > "+diffOutput+"\n"), 0755))
+	diffConfig := config.DefaultConfig()
+	diffConfig.DiffCommand = diffScript
+	setUpFiles_TestFragments(t, diffConfig)
+	tempFilesDir := t.TempDir()
+	t.Setenv("TMPDIR", tempFilesDir)
+
+	// The existing file is only in memory, the diff command must still see it
+	useFileSystem(t, filesystem.NewOverlay(filesystem.OS{}))
+	filesystem.WriteFile(homeDir()+"/.bashrc", []byte("only in memory\n"), 0644)
+	answers := []rune{'d', 'n'}
+	previousPrompter := utils.SetPrompter(func(question string, options string) rune {
+		answer := answers[0]
+		answers = answers[1:]
+		return answer
+	})
+	defer utils.SetPrompter(previousPrompter)
+
+	install.Install(false)
+	assert.Empty(t, answers)
+	diff, err := os.ReadFile(diffOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, "# base\nalias ll='ls -l'\nexport EDITOR=vim\nonly in memory\n", string(diff))
+	tempFiles, err := os.ReadDir(tempFilesDir)
+	assert.NoError(t, err)
+	assert.Empty(t, tempFiles)
+}
+
+func TestFragments_ReplaceLinkWithGeneratedFile(t *testing.T) {
+	setUpFiles_TestFragments(t, config.DefaultConfig())
+	createFile(sourceDir(), File("gitconfig"))
+
+	install.Install(false)
+	assertHomeSymlink(t, ".gitconfig", sourceDir()+"/gitconfig")
+
+	filesystem.Remove(sourceDir() + "/gitconfig")
+	createNode(sourceDir(), Dir("gitconfig.doot-fragments", []FsNode{
+		FsFile{Name: "user", Content: "[user]\n"},
+	}))
+	install.Install(false)
+	assertHomeRegularFile(t, ".gitconfig")
+	assert.Equal(t, "[user]\n", readFile(homeDir()+"/.gitconfig"))
+}
+
+func setUpFiles_TestFragments(t *testing.T, config config.Config) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config),
+		}),
+		File("file1"),
+		Dir("bashrc.doot-fragments", []FsNode{
+			FsFile{Name: "20-env.sh", Content: "export EDITOR=vim\n"},
+			FsFile{Name: "00-base.sh", Content: "# base"},
+			FsFile{Name: "10-aliases.sh", Content: "alias ll='ls -l'\n"},
+		}),
+	})
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/generations"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerations_RecordedAfterInstall(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	install.Install(false)
+	list := generations.List(generationsCacheKey())
+	assert.Len(t, list, 1, "An install without changes should not record a new generation")
+	assert.Equal(t, 1, list[0].Number)
+	assert.Equal(t, []generations.Link{
+		{Path: homeDir() + "/.bashrc", Source: sourceDir() + "/bashrc"},
+		{Path: homeDir() + "/.config/app/settings", Source: sourceDir() + "/config/app/settings"},
+	}, list[0].Links)
+
+	createNode(sourceDir(), File("vimrc"))
+	install.Install(false)
+	install.Clean(false)
+	list = generations.List(generationsCacheKey())
+	assert.Len(t, list, 3)
+	assert.Len(t, list[1].Links, 3)
+	assert.Empty(t, list[2].Links)
+}
+
+func TestGenerations_RollbackToPrevious(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	createNode(sourceDir(), File("vimrc"))
+	install.Install(false)
+	assertHomeSymlink(t, ".vimrc", sourceDir()+"/vimrc")
+
+	install.Rollback(0)
+	assertHomeDirContents(t, "", []string{".bashrc", ".config"})
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".config/app/settings"), Content: sourceDir() + "/config/app/settings"},
+	})
+	assert.Len(t, generations.List(generationsCacheKey()), 3, "The rollback should be recorded as a new generation")
+
+	// Rolling back again returns to the generation before the rollback
+	install.Rollback(0)
+	assertHomeSymlink(t, ".vimrc", sourceDir()+"/vimrc")
+
+	install.Rollback(1)
+	assertHomeDirContents(t, "", []string{".bashrc", ".config"})
+}
+
+func TestGenerations_RollbackRestoresRemovedDotfileFromGit(t *testing.T) {
+	runOnDisk(t)
+	setUp_TestUndo(t)
+	runGit("init", "--quiet")
+	runGit("add", ".")
+	commitAll("first")
+	install.Install(false)
+
+	runGit("rm", "--quiet", "bashrc")
+	commitAll("remove bashrc")
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".config"})
+
+	install.Rollback(0)
+	assertHomeRegularFile(t, ".bashrc")
+	assert.Equal(t, "dummy text for file bashrc", readFile(homeDir()+"/.bashrc"))
+	assertHomeSymlink(t, ".config/app/settings", sourceDir()+"/config/app/settings")
+	assertGeneratedCache(t, []string{homeDir() + "/.bashrc"})
+}
+
+func TestGenerations_RollbackRestoresGeneratedFile(t *testing.T) {
+	setUpFiles_TestFragments(t, config.DefaultConfig())
+	install.Install(false)
+	oldContents := readFile(homeDir() + "/.bashrc")
+	createNode(sourceDir(), Dir("bashrc.doot-fragments", []FsNode{
+		FsFile{Name: "30-new.sh", Content: "export NEW=1\n"},
+	}))
+	install.Install(false)
+	assert.NotEqual(t, oldContents, readFile(homeDir()+"/.bashrc"))
+
+	install.Rollback(0)
+	assertHomeRegularFile(t, ".bashrc")
+	assert.Equal(t, oldContents, readFile(homeDir()+"/.bashrc"))
+	assertGeneratedCache(t, []string{homeDir() + "/.bashrc"})
+}
+
+func TestGenerations_RollbackRestoresHardlinkContents(t *testing.T) {
+	config := config.DefaultConfig()
+	config.UseHardlinks = true
+	SetUpFiles(t, false, []FsNode{
+		Dir("doot", []FsNode{ConfigFile(config)}),
+		File("bashrc"),
+	})
+	install.Install(false)
+
+	// Replace the dotfile with a new file, like git does
+	filesystem.Remove(filepath.Join(sourceDir(), "bashrc"))
+	createNode(sourceDir(), FsFile{Name: "bashrc", Content: "new bashrc"})
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	install.Install(false)
+	assert.Equal(t, "new bashrc", readFile(homeDir()+"/.bashrc"))
+
+	install.Rollback(0)
+	assert.Equal(t, "dummy text for file bashrc", readFile(homeDir()+"/.bashrc"))
+	assert.Equal(t, "new bashrc", readFile(sourceDir()+"/bashrc"))
+}
+
+func TestGenerations_NoPreviousGeneration(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		install.Rollback(0)
+	})
+	assert.Panics(t, func() {
+		install.Rollback(5)
+	})
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+}
+
+func generationsCacheKey() string {
+	return cache.ComputeCacheKey(sourceDirPath(), homeDir())
+}
+
+func commitAll(message string) {
+	runGit("-c", "user.name=doot", "-c", "user.email=doot@example.com", "commit", "--quiet", "-m", message)
+}
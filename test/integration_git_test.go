@@ -0,0 +1,87 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+func TestGitTrackedOnly_OnlyLinksTrackedFiles(t *testing.T) {
+	runOnDisk(t)
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.GitTrackedOnly = true
+	setUpFiles_TestGit(t, config)
+	runGit("init", "--quiet")
+	runGit("add", "doot", "file1", "dir1/file3", "dir1/nestedDir/file4", ".gitignore")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "dir1"})
+	assertHomeDirContents(t, "dir1", []string{"file3", "nestedDir"})
+	assertHomeDirContents(t, "dir1/nestedDir", []string{"file4"})
+}
+
+func TestGitTrackedOnly_IncludeUntracked(t *testing.T) {
+	runOnDisk(t)
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.GitTrackedOnly = true
+	config.GitIncludeUntracked = true
+	config.ExcludeFiles = append(config.ExcludeFiles, "dir1/file3")
+	setUpFiles_TestGit(t, config)
+	runGit("init", "--quiet")
+	runGit("add", "file1")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "file2.txt", "dir1"})
+	assertHomeDirContents(t, "dir1", []string{"nestedDir"})
+	assertHomeDirContents(t, "dir1/nestedDir", []string{"file4"})
+}
+
+func TestGitTrackedOnly_DeletedFilesAreSkipped(t *testing.T) {
+	runOnDisk(t)
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.GitTrackedOnly = true
+	setUpFiles_TestGit(t, config)
+	runGit("init", "--quiet")
+	runGit("add", "file1", "file2.txt")
+	runGit("rm", "--quiet", "--cached", "file2.txt")
+	runGit("add", "dir1/file3")
+	filesystem.Remove(sourceDir() + "/dir1/file3")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1"})
+}
+
+func TestGitTrackedOnly_FallbackWhenNotARepository(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.GitTrackedOnly = true
+	setUpFiles_TestGit(t, config)
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{"file1", "file2.txt", "dir1", "node_modules"})
+}
+
+func setUpFiles_TestGit(t *testing.T, config config.Config) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config),
+		}),
+		FsFile{Name: ".gitignore", Content: "node_modules/\n"},
+		File("file1"),
+		File("file2.txt"),
+		Dir("dir1", []FsNode{
+			File("file3"),
+			Dir("nestedDir", []FsNode{
+				File("file4"),
+			}),
+		}),
+		Dir("node_modules", []FsNode{
+			File("dependency.js"),
+		}),
+	})
+}
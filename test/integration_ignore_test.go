@@ -0,0 +1,80 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/ignore"
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnore_IgnoreAndUnignore(t *testing.T) {
+	setUpFiles_TestIgnore(t)
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".ssh", ".config"})
+
+	ignore.Ignore([]string{homeDir() + "/.ssh/config"})
+	assertHomeDirContents(t, "", []string{".bashrc", ".config"})
+	assertIgnoredTargets(t, []string{homeDir() + "/.ssh/config"})
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".config"})
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".config/app/settings"), Content: sourceDir() + "/config/app/settings"},
+	})
+
+	ignore.Unignore([]string{homeDir() + "/.ssh/config"})
+	assertHomeDirContents(t, "", []string{".bashrc", ".ssh", ".config"})
+	assertHomeSymlink(t, ".ssh/config", sourceDir()+"/ssh/config")
+	assertIgnoredTargets(t, []string{})
+}
+
+func TestIgnore_IgnoreDirectory(t *testing.T) {
+	setUpFiles_TestIgnore(t)
+
+	ignore.Ignore([]string{homeDir() + "/.config"})
+	assertHomeDirContents(t, "", []string{".bashrc", ".ssh"})
+
+	createFile(sourceDir()+"/config/app", File("other"))
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".ssh"})
+}
+
+func TestIgnore_IgnoreTwice(t *testing.T) {
+	setUpFiles_TestIgnore(t)
+
+	ignore.Ignore([]string{homeDir() + "/.bashrc", homeDir() + "/.bashrc"})
+	ignore.Ignore([]string{homeDir() + "/.bashrc"})
+	assertIgnoredTargets(t, []string{homeDir() + "/.bashrc"})
+	ignore.Unignore([]string{homeDir() + "/.doesNotExist"})
+	assertIgnoredTargets(t, []string{homeDir() + "/.bashrc"})
+}
+
+func assertIgnoredTargets(t *testing.T, expected []string) {
+	t.Helper()
+	dootCache := cache.Load()
+	cacheEntry := dootCache.GetEntry(sourceDir() + ":" + homeDir())
+	assert.ElementsMatch(t, expected, cacheEntry.IgnoredTargets)
+}
+
+func setUpFiles_TestIgnore(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config.DefaultConfig()),
+		}),
+		File("bashrc"),
+		Dir("ssh", []FsNode{
+			File("config"),
+		}),
+		Dir("config", []FsNode{
+			Dir("app", []FsNode{
+				File("settings"),
+			}),
+		}),
+	})
+}
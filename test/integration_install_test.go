@@ -10,6 +10,7 @@ import (
 	"github.com/pol-rivero/doot/lib/common/log"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,7 +39,7 @@ func TestInstall_DefaultConfig(t *testing.T) {
 	assertHomeSymlink(t, "file1", sourceDir()+"/file1")
 	assertHomeSymlink(t, "dir1/nestedDir/file4", sourceDir()+"/dir1/nestedDir/file4")
 
-	os.Remove(sourceDir() + "/file1")
+	filesystem.Remove(sourceDir() + "/file1")
 	install.Install(false)
 	assertHomeDirContents(t, "", []string{
 		"file2.txt",
@@ -76,7 +77,7 @@ func TestInstall_Hardlinks(t *testing.T) {
 	assertHomeHardlink(t, "file1", sourceDir()+"/file1")
 	assertHomeHardlink(t, "dir1/nestedDir/file4", sourceDir()+"/dir1/nestedDir/file4")
 
-	os.Remove(sourceDir() + "/file1")
+	filesystem.Remove(sourceDir() + "/file1")
 	install.Install(false)
 	assertHomeDirContents(t, "", []string{
 		"file2.txt",
@@ -493,7 +494,7 @@ func TestInstall_DoNotRemoveUnexpectedFiles(t *testing.T) {
 		"dir3",
 	})
 	// User manually changes some files, which should NOT be removed when cleaning
-	os.Remove(homeDir() + "/file1")
+	filesystem.Remove(homeDir() + "/file1")
 	createNode(homeDir(), File("file1"))
 	replaceWithSymlink(homeDir(), "file2.txt", homeDir()+"/incorrect_link") // Link does not point to dotfiles dir
 
@@ -534,7 +535,31 @@ func TestInstall_ExploreExcludedDirs(t *testing.T) {
 	})
 }
 
+func TestInstall_ExploreExcludedDirsDisabled(t *testing.T) {
+	config := config.DefaultConfig()
+	config.ImplicitDot = false
+	config.ExploreExcludedDirs = false
+	config.ExcludeFiles = []string{"dir*", "**/nestedDir", "**/.*"}
+	config.IncludeFiles = []string{"dir1/nestedDir/file4"}
+	setUpFiles_TestInstall(t, config, true)
+
+	// Only the excluded directories that lead to an included path are explored
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{
+		"file1",
+		"file2.txt",
+		"dir1",
+	})
+	assertHomeDirContents(t, "dir1", []string{
+		"nestedDir",
+	})
+	assertHomeDirContents(t, "dir1/nestedDir", []string{
+		"file4",
+	})
+}
+
 func TestInstall_Hooks(t *testing.T) {
+	runOnDisk(t)
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
 	setUpFiles_TestInstall(t, config, true)
@@ -562,6 +587,7 @@ func TestInstall_Hooks(t *testing.T) {
 }
 
 func TestInstall_HooksFail(t *testing.T) {
+	runOnDisk(t)
 	log.PanicInsteadOfExit = true
 	config := config.DefaultConfig()
 	config.ImplicitDot = false
@@ -590,11 +616,11 @@ func TestInstall_FullClean(t *testing.T) {
 	createSymlink(homeDir()+"/nested/dir", "outdatedLink", sourceDir()+"/im-not-in-cache")
 
 	install.Install(false)
-	assert.FileExists(t, homeDir()+"/nested/dir/outdatedLink")
+	assertFileExists(t, homeDir()+"/nested/dir/outdatedLink")
 
 	install.Install(true)
-	assert.NoFileExists(t, homeDir()+"/nested/dir/outdatedLink")
-	assert.NoDirExists(t, homeDir()+"/nested")
+	assertNoFileExists(t, homeDir()+"/nested/dir/outdatedLink")
+	assertNoDirExists(t, homeDir()+"/nested")
 }
 
 func TestInstall_FullClean2(t *testing.T) {
@@ -605,7 +631,7 @@ func TestInstall_FullClean2(t *testing.T) {
 	createSymlink(homeDir()+"/nested/dir", "outdatedLink", sourceDir()+"/im-not-in-cache")
 
 	install.Clean(false)
-	assert.FileExists(t, homeDir()+"/nested/dir/outdatedLink")
+	assertFileExists(t, homeDir()+"/nested/dir/outdatedLink")
 
 	install.Clean(true)
 	assertHomeDirContents(t, "", []string{})
@@ -622,13 +648,13 @@ func TestInstall_FullClean_Hardlink(t *testing.T) {
 	createHardlink(homeDir()+"/nested/dir", "outdatedLink", sourceDir()+"/file1")
 
 	install.Install(false)
-	assert.FileExists(t, homeDir()+"/nested/dir/outdatedLink")
+	assertFileExists(t, homeDir()+"/nested/dir/outdatedLink")
 
 	install.Install(true)
-	assert.NoFileExists(t, homeDir()+"/nested/dir/outdatedLink")
-	assert.NoDirExists(t, homeDir()+"/nested")
-	assert.FileExists(t, homeDir()+"/doNotRemoveUnrelatedFile")
-	assert.FileExists(t, homeDir()+"/doNotRemoveUnrelatedFile2")
+	assertNoFileExists(t, homeDir()+"/nested/dir/outdatedLink")
+	assertNoDirExists(t, homeDir()+"/nested")
+	assertFileExists(t, homeDir()+"/doNotRemoveUnrelatedFile")
+	assertFileExists(t, homeDir()+"/doNotRemoveUnrelatedFile2")
 }
 
 func TestInstall_FullCleanHardlink2(t *testing.T) {
@@ -640,7 +666,7 @@ func TestInstall_FullCleanHardlink2(t *testing.T) {
 	createHardlink(homeDir()+"/nested/dir", "outdatedLink", sourceDir()+"/file1")
 
 	install.Clean(false)
-	assert.FileExists(t, homeDir()+"/nested/dir/outdatedLink")
+	assertFileExists(t, homeDir()+"/nested/dir/outdatedLink")
 
 	install.Clean(true)
 	assertHomeDirContents(t, "", []string{})
@@ -655,7 +681,7 @@ func TestInstall_AddDootCryptDoesntRequireConfirmation(t *testing.T) {
 	install.Install(false)
 	assertHomeSymlink(t, "file1", sourceDir()+"/file1")
 
-	err := os.Rename(sourceDir()+"/file1", sourceDir()+"/file1.doot-crypt")
+	err := filesystem.Rename(sourceDir()+"/file1", sourceDir()+"/file1.doot-crypt")
 	assert.NoError(t, err)
 
 	// Shouldn't wait for user input
@@ -672,7 +698,7 @@ func TestInstall_AdoptChanges(t *testing.T) {
 	assertHomeSymlink(t, "file1", sourceDir()+"/file1")
 	assert.Equal(t, "dummy text for file file1", readFile(sourceDir()+"/file1"))
 
-	os.Remove(homeDir() + "/file1")
+	filesystem.Remove(homeDir() + "/file1")
 	createFile(homeDir(), FsFile{Name: "file1", Content: "Some external program has replaced this"})
 	assertHomeRegularFile(t, "file1")
 
@@ -701,7 +727,7 @@ func TestInstall_AdoptChanges_Hardlink(t *testing.T) {
 	assertHomeHardlink(t, "file1", sourceDir()+"/file1")
 	assert.Equal(t, "dummy text for file file1", readFile(sourceDir()+"/file1"))
 
-	os.Remove(homeDir() + "/file1")
+	filesystem.Remove(homeDir() + "/file1")
 	createFile(homeDir(), FsFile{Name: "file1", Content: "Some external program has replaced this"})
 	assertHomeRegularFile(t, "file1")
 
@@ -726,7 +752,7 @@ func TestInstall_ReplaceRegularFileWithSymlink(t *testing.T) {
 
 	// Home directory contains a regular file that should be replaced with a symlink
 	setUpFiles_TestInstall(t, config, true)
-	os.Remove(sourceDir() + "/file1")
+	filesystem.Remove(sourceDir() + "/file1")
 	createSymlink(sourceDir(), "file1", "/some-file")
 
 	createFile(homeDir(), File("file1"))
@@ -747,7 +773,7 @@ func TestInstall_ReplaceRegularFileWithSymlink_Hardlink(t *testing.T) {
 
 	// Home directory contains a regular file that should be replaced with a symlink
 	setUpFiles_TestInstall(t, config, false)
-	os.Remove(sourceDir() + "/file1")
+	filesystem.Remove(sourceDir() + "/file1")
 	replaceWithSymlink(sourceDir(), "file1", "/some-file")
 
 	createFile(homeDir(), File("file1"))
@@ -800,8 +826,8 @@ func TestInstall_SwapFileAndDirectory(t *testing.T) {
 	install.Install(false)
 
 	// Change file1 to be a directory and dir1 to a file, it should be handled correctly
-	os.Remove(sourceDir() + "/file1")
-	os.RemoveAll(sourceDir() + "/dir1")
+	filesystem.Remove(sourceDir() + "/file1")
+	filesystem.RemoveAll(sourceDir() + "/dir1")
 	createDir(sourceDir(), Dir("file1", []FsNode{File("insideFile1")}))
 	createFile(sourceDir(), File("dir1"))
 
@@ -818,8 +844,8 @@ func TestInstall_SwapFileAndDirectoryUsingHardlinks(t *testing.T) {
 	setUpFiles_TestInstall(t, config, false)
 	install.Install(false)
 
-	os.Remove(sourceDir() + "/file1")
-	os.RemoveAll(sourceDir() + "/dir1")
+	filesystem.Remove(sourceDir() + "/file1")
+	filesystem.RemoveAll(sourceDir() + "/dir1")
 	createDir(sourceDir(), Dir("file1", []FsNode{File("insideFile1")}))
 	createFile(sourceDir(), File("dir1"))
 
@@ -838,7 +864,7 @@ func TestInstall_DoesNotOverwriteExistingFilesInDirectory(t *testing.T) {
 	assertHomeDirContents(t, "dir1", []string{"file3", "nestedDir"})
 
 	createFile(homeDir(), File("dir1/pleaseDoNotRemoveMe"))
-	os.RemoveAll(sourceDir() + "/dir1")
+	filesystem.RemoveAll(sourceDir() + "/dir1")
 	createFile(sourceDir(), File("dir1"))
 
 	install.Install(false)
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/repo"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayers_HigherLayerOverridesLowerLayer(t *testing.T) {
+	baseDir := setUp_TestLayers(t, func(c *config.Config) {})
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".vimrc", ".gitconfig"})
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	assertHomeSymlink(t, ".vimrc", baseDir+"/vimrc")
+	assertHomeSymlink(t, ".gitconfig", baseDir+"/gitconfig")
+	assertLinkLayers(t, map[string]string{
+		homeDir() + "/.bashrc":    filepath.Base(sourceDir()),
+		homeDir() + "/.vimrc":     "base",
+		homeDir() + "/.gitconfig": "base",
+	})
+}
+
+func TestLayers_HigherLayerOverridesHostSpecificFile(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	baseDir := setUp_TestLayers(t, func(c *config.Config) {})
+	createNode(baseDir, Dir("doot", []FsNode{ConfigFile(hostsConfig())}))
+	createNode(baseDir, Dir("laptop", []FsNode{File("bashrc"), File("vimrc")}))
+
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	assertHomeSymlink(t, ".vimrc", baseDir+"/laptop/vimrc")
+}
+
+func TestLayers_LowerLayerUsesItsOwnConfig(t *testing.T) {
+	baseDir := setUp_TestLayers(t, func(c *config.Config) {})
+	baseConfig := config.DefaultConfig()
+	baseConfig.ImplicitDot = false
+	baseConfig.ExcludeFiles = []string{"gitconfig"}
+	createNode(baseDir, Dir("doot", []FsNode{ConfigFile(baseConfig)}))
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", "bashrc", "vimrc"})
+	assertHomeSymlink(t, "vimrc", baseDir+"/vimrc")
+}
+
+func TestLayers_RemoveLayer(t *testing.T) {
+	setUp_TestLayers(t, func(c *config.Config) {})
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".vimrc", ".gitconfig"})
+
+	createNode(sourceDir(), Dir("doot", []FsNode{ConfigFile(config.DefaultConfig())}))
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc"})
+	assertLinkLayers(t, map[string]string{})
+}
+
+func TestLayers_RegisteredRepository(t *testing.T) {
+	t.Setenv(common.ENV_XDG_CONFIG_HOME, tempDir(t))
+	baseDir := setUp_TestLayers(t, func(c *config.Config) {
+		c.Layers = []string{"company"}
+	})
+	repo.Add(baseDir, "company")
+
+	install.Install(false)
+	assertHomeSymlink(t, ".vimrc", baseDir+"/vimrc")
+	assertLinkLayers(t, map[string]string{
+		homeDir() + "/.bashrc":    filepath.Base(sourceDir()),
+		homeDir() + "/.vimrc":     "company",
+		homeDir() + "/.gitconfig": "company",
+	})
+}
+
+func TestLayers_FragmentsFromSeveralLayers(t *testing.T) {
+	baseDir := setUp_TestLayers(t, func(c *config.Config) {})
+	createNode(baseDir, Dir("zshrc.doot-fragments", []FsNode{
+		FsFile{Name: "10-base.sh", Content: "base"},
+		FsFile{Name: "20-override.sh", Content: "base override"},
+	}))
+	createNode(sourceDir(), Dir("zshrc.doot-fragments", []FsNode{
+		FsFile{Name: "20-override.sh", Content: "personal override"},
+		FsFile{Name: "30-personal.sh", Content: "personal"},
+	}))
+
+	install.Install(false)
+	assert.Equal(t, "base\npersonal override\npersonal\n", readFile(homeDir()+"/.zshrc"))
+}
+
+func TestLayers_MissingLayer(t *testing.T) {
+	setUp_TestLayers(t, func(c *config.Config) {
+		c.Layers = []string{"/does/not/exist"}
+	})
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		install.Install(false)
+	})
+	assertHomeDirContents(t, "", []string{})
+}
+
+func TestLayers_NestedLayer(t *testing.T) {
+	setUp_TestLayers(t, func(c *config.Config) {
+		c.Layers = []string{"base"}
+	})
+	createNode(sourceDir(), Dir("base", []FsNode{File("vimrc")}))
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		install.Install(false)
+	})
+	assertHomeDirContents(t, "", []string{})
+}
+
+// Returns the directory of the base layer, which contains "bashrc", "vimrc" and "gitconfig"
+func setUp_TestLayers(t *testing.T, configure func(*config.Config)) string {
+	SetUp(t, false)
+	baseDir := filepath.Join(tempDir(t), "base")
+	createNode(filepath.Dir(baseDir), Dir("base", []FsNode{
+		File("bashrc"),
+		File("vimrc"),
+		File("gitconfig"),
+	}))
+	topConfig := config.DefaultConfig()
+	topConfig.Layers = []string{baseDir}
+	configure(&topConfig)
+	createNode(sourceDir(), File("bashrc"))
+	createNode(sourceDir(), Dir("doot", []FsNode{ConfigFile(topConfig)}))
+	return baseDir
+}
+
+func hostsConfig() config.Config {
+	hostsConfig := config.DefaultConfig()
+	hostsConfig.Hosts = map[string]string{"laptop": "laptop"}
+	return hostsConfig
+}
+
+func assertLinkLayers(t *testing.T, expected map[string]string) {
+	t.Helper()
+	dootCache := cache.Load()
+	actual := make(map[string]string)
+	for target, layer := range dootCache.GetEntry(sourceDir() + ":" + homeDir()).GetLinkLayers() {
+		actual[target.Str()] = layer
+	}
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch_JsonWithHostPatch(t *testing.T) {
+	setUpFiles_TestMergePatch(t)
+
+	install.Install(false)
+	assertHomeRegularFile(t, ".settings.json")
+	assert.JSONEq(t, `{"editor": {"fontSize": 16, "tabSize": 2}, "theme": "light"}`, readFile(homeDir()+"/.settings.json"))
+	assertHomeSymlink(t, ".starship.toml", sourceDir()+"/starship.toml")
+	assertHomeDirContents(t, "", []string{".settings.json", ".starship.toml"})
+	assertGeneratedCache(t, []string{homeDir() + "/.settings.json"})
+
+	install.Clean(false)
+	assertHomeDirContents(t, "", []string{})
+}
+
+func TestMergePatch_JsonWithComments(t *testing.T) {
+	setUpFiles_TestMergePatch(t)
+	createNode(sourceDir(), FsFile{Name: "settings.json", Content: `{
+		// Editor settings
+		"editor": {"fontSize": 14, "tabSize": 2,},
+		"theme": "dark", /* overridden by the patch */
+	}`})
+
+	install.Install(false)
+	assert.JSONEq(t, `{"editor": {"fontSize": 16, "tabSize": 2}, "theme": "light"}`, readFile(homeDir()+"/.settings.json"))
+}
+
+func TestMergePatch_TomlAndYaml(t *testing.T) {
+	setUpFiles_TestMergePatch(t)
+	createNode(sourceDir(), Dir("hosts", []FsNode{
+		Dir("laptop", []FsNode{
+			FsFile{Name: "starship.toml.doot-patch", Content: "[battery]\ndisabled = false\n"},
+			FsFile{Name: "alacritty.yml.doot-patch", Content: "font:\n  size: 9\n"},
+		}),
+	}))
+	createFile(sourceDir(), FsFile{Name: "alacritty.yml", Content: "font:\n  family: mono\n  size: 12\n"})
+
+	install.Install(false)
+	assert.Equal(t, "[battery]\ndisabled = false\n\n[character]\nsymbol = '>'\n", readFile(homeDir()+"/.starship.toml"))
+	assert.Equal(t, "font:\n    family: mono\n    size: 9\n", readFile(homeDir()+"/.alacritty.yml"))
+}
+
+func TestMergePatch_RegenerateWhenPatchChanges(t *testing.T) {
+	setUpFiles_TestMergePatch(t)
+
+	install.Install(false)
+	filesystem.WriteFile(sourceDir()+"/hosts/laptop/settings.json.doot-patch", []byte(`{"theme": null}`), 0644)
+	install.Install(false)
+	assert.JSONEq(t, `{"editor": {"fontSize": 12, "tabSize": 2}}`, readFile(homeDir()+"/.settings.json"))
+
+	filesystem.Remove(sourceDir() + "/hosts/laptop/settings.json.doot-patch")
+	install.Install(false)
+	assertHomeSymlink(t, ".settings.json", sourceDir()+"/settings.json")
+	assertGeneratedCache(t, []string{})
+}
+
+func TestMergePatch_IgnorePatchWithoutBase(t *testing.T) {
+	setUpFiles_TestMergePatch(t)
+	filesystem.Remove(sourceDir() + "/settings.json")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".starship.toml"})
+}
+
+func setUpFiles_TestMergePatch(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	config := config.DefaultConfig()
+	config.Hosts = map[string]string{"laptop": "hosts/laptop"}
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config),
+		}),
+		FsFile{Name: "settings.json", Content: `{"theme": "dark", "editor": {"fontSize": 12, "tabSize": 2}}`},
+		FsFile{Name: "starship.toml", Content: "[character]\nsymbol = '>'\n"},
+		Dir("hosts", []FsNode{
+			Dir("laptop", []FsNode{
+				FsFile{Name: "settings.json.doot-patch", Content: `{"theme": "light", "editor": {"fontSize": 16}}`},
+			}),
+		}),
+	})
+}
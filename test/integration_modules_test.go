@@ -0,0 +1,143 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/module"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/config"
+)
+
+func TestModules_DeclaredModules(t *testing.T) {
+	config := config.DefaultConfig()
+	config.Modules = map[string]string{"nvim": "nvim", "zsh": "shells/zsh"}
+	config.EnabledModules = []string{"zsh"}
+	setUpFiles_TestModules(t, config)
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".zshrc", ".sway"})
+	assertHomeSymlink(t, ".zshrc", sourceDir()+"/shells/zsh/zshrc")
+	assertHomeSymlink(t, ".sway/swayrc", sourceDir()+"/sway/swayrc")
+}
+
+func TestModules_AutoModules(t *testing.T) {
+	config := config.DefaultConfig()
+	config.AutoModules = true
+	config.EnabledModules = []string{"nvim", "sway"}
+	setUpFiles_TestModules(t, config)
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".config", ".swayrc"})
+	assertHomeSymlink(t, ".config/nvim/init.lua", sourceDir()+"/nvim/config/nvim/init.lua")
+	assertHomeSymlink(t, ".swayrc", sourceDir()+"/sway/swayrc")
+}
+
+func TestModules_HostModules(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "work-laptop")
+	config := config.DefaultConfig()
+	config.Modules = map[string]string{"nvim": "nvim", "zsh": "shells/zsh", "work-vpn": "work-vpn"}
+	config.EnabledModules = []string{"nvim"}
+	config.HostModules = map[string][]string{
+		"work-*":      {"work-vpn"},
+		"home-server": {"zsh"},
+	}
+	setUpFiles_TestModules(t, config)
+	createNode(sourceDir(), Dir("work-vpn", []FsNode{File("vpn.conf")}))
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".config", ".vpn.conf", ".sway"})
+	assertHomeSymlink(t, ".vpn.conf", sourceDir()+"/work-vpn/vpn.conf")
+}
+
+func TestModules_HostSpecificFilesInModules(t *testing.T) {
+	t.Setenv(common.ENV_DOOT_HOSTNAME, "laptop")
+	config := config.DefaultConfig()
+	config.Hosts = map[string]string{"laptop": "laptop-dots"}
+	config.Modules = map[string]string{"nvim": "nvim", "zsh": "shells/zsh"}
+	config.EnabledModules = []string{"nvim"}
+	setUpFiles_TestModules(t, config)
+	createNode(sourceDir(), Dir("laptop-dots", []FsNode{
+		Dir("nvim", []FsNode{
+			Dir("config", []FsNode{
+				Dir("nvim", []FsNode{
+					File("init.lua"),
+				}),
+			}),
+		}),
+		Dir("shells", []FsNode{
+			Dir("zsh", []FsNode{
+				File("zshrc"),
+			}),
+		}),
+	}))
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".config", ".sway"})
+	assertHomeSymlink(t, ".config/nvim/init.lua", sourceDir()+"/laptop-dots/nvim/config/nvim/init.lua")
+
+	module.Disable([]string{"nvim"})
+	assertHomeDirContents(t, "", []string{".file1", ".sway"})
+}
+
+func TestModules_EnableAndDisable(t *testing.T) {
+	config := config.DefaultConfig()
+	config.Modules = map[string]string{"nvim": "nvim", "zsh": "shells/zsh"}
+	config.EnabledModules = []string{"nvim"}
+	setUpFiles_TestModules(t, config)
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".config", ".sway"})
+
+	module.Enable([]string{"zsh"})
+	assertHomeDirContents(t, "", []string{".file1", ".config", ".sway", ".zshrc"})
+
+	module.Disable([]string{"nvim", "doesNotExist"})
+	assertHomeDirContents(t, "", []string{".file1", ".sway", ".zshrc"})
+
+	// The local selection persists across installs
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".sway", ".zshrc"})
+
+	module.Enable([]string{"nvim"})
+	module.Disable([]string{"zsh"})
+	assertHomeDirContents(t, "", []string{".file1", ".config", ".sway"})
+}
+
+func TestModules_GitTrackedOnly(t *testing.T) {
+	runOnDisk(t)
+	config := config.DefaultConfig()
+	config.Modules = map[string]string{"nvim": "nvim", "zsh": "shells/zsh"}
+	config.EnabledModules = []string{"zsh"}
+	config.GitTrackedOnly = true
+	setUpFiles_TestModules(t, config)
+	runGit("init", "--quiet")
+	runGit("add", ".")
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".file1", ".zshrc", ".sway"})
+}
+
+func setUpFiles_TestModules(t *testing.T, config config.Config) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config),
+		}),
+		File("file1"),
+		Dir("nvim", []FsNode{
+			Dir("config", []FsNode{
+				Dir("nvim", []FsNode{
+					File("init.lua"),
+				}),
+			}),
+		}),
+		Dir("shells", []FsNode{
+			Dir("zsh", []FsNode{
+				File("zshrc"),
+			}),
+		}),
+		Dir("sway", []FsNode{
+			File("swayrc"),
+		}),
+	})
+}
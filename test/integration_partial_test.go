@@ -0,0 +1,107 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/config"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+)
+
+func TestPartial_InstallTargetPath(t *testing.T) {
+	setUpFiles_TestPartial(t)
+
+	install.InstallPaths(false, []string{homeDir() + "/.config/nvim"})
+	assertHomeDirContents(t, "", []string{".config"})
+	assertHomeDirContents(t, ".config", []string{"nvim"})
+	assertHomeSymlink(t, ".config/nvim/init.lua", sourceDir()+"/config/nvim/init.lua")
+
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".config/nvim/init.lua"), Content: sourceDir() + "/config/nvim/init.lua"},
+	})
+}
+
+func TestPartial_InstallSourcePathAndGlob(t *testing.T) {
+	setUpFiles_TestPartial(t)
+
+	install.InstallPaths(false, []string{sourceDir() + "/bashrc", sourceDir() + "/config/*/config"})
+	assertHomeDirContents(t, "", []string{".bashrc", ".config"})
+	assertHomeDirContents(t, ".config", []string{"sway"})
+	assertHomeSymlink(t, ".config/sway/config", sourceDir()+"/config/sway/config")
+}
+
+func TestPartial_LeaveOtherEntriesUntouched(t *testing.T) {
+	setUpFiles_TestPartial(t)
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".zshrc", ".config"})
+
+	// Unselected changes are not applied: .zshrc is not removed and .profile is not added
+	filesystem.Remove(sourceDir() + "/zshrc")
+	createFile(sourceDir(), File("profile"))
+	filesystem.Remove(sourceDir() + "/config/nvim/init.lua")
+	createFile(sourceDir()+"/config/nvim", File("lazy.lua"))
+	install.InstallPaths(false, []string{homeDir() + "/.config/nvim"})
+	assertHomeDirContents(t, "", []string{".bashrc", ".zshrc", ".config"})
+	assertHomeDirContents(t, ".config/nvim", []string{"lazy.lua"})
+
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".zshrc"), Content: sourceDir() + "/zshrc"},
+		{Path: homePath.Join(".config/nvim/lazy.lua"), Content: sourceDir() + "/config/nvim/lazy.lua"},
+		{Path: homePath.Join(".config/sway/config"), Content: sourceDir() + "/config/sway/config"},
+	})
+
+	install.Install(false)
+	assertHomeDirContents(t, "", []string{".bashrc", ".profile", ".config"})
+}
+
+func TestPartial_Clean(t *testing.T) {
+	setUpFiles_TestPartial(t)
+	install.Install(false)
+
+	install.CleanPaths(false, []string{homeDir() + "/.config", sourceDir() + "/zshrc"})
+	assertHomeDirContents(t, "", []string{".bashrc"})
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+	})
+
+	install.CleanPaths(false, []string{homeDir() + "/.doesNotExist"})
+	assertHomeDirContents(t, "", []string{".bashrc"})
+	assertFileExists(t, homeDir()+"/.bashrc")
+}
+
+func TestPartial_CleanGeneratedFileBySource(t *testing.T) {
+	setUpFiles_TestPartial(t)
+	createNode(sourceDir(), Dir("gitconfig.doot-fragments", []FsNode{
+		FsFile{Name: "10-user", Content: "[user]\n"},
+	}))
+	install.Install(false)
+	assertGeneratedCache(t, []string{homeDir() + "/.gitconfig"})
+
+	install.CleanPaths(false, []string{sourceDir() + "/gitconfig.doot-fragments"})
+	assertNoFileExists(t, homeDir()+"/.gitconfig")
+	assertGeneratedCache(t, []string{})
+	assertHomeDirContents(t, "", []string{".bashrc", ".zshrc", ".config"})
+}
+
+func setUpFiles_TestPartial(t *testing.T) {
+	SetUpFiles(t, true, []FsNode{
+		Dir("doot", []FsNode{
+			ConfigFile(config.DefaultConfig()),
+		}),
+		File("bashrc"),
+		File("zshrc"),
+		Dir("config", []FsNode{
+			Dir("nvim", []FsNode{
+				File("init.lua"),
+			}),
+			Dir("sway", []FsNode{
+				File("config"),
+			}),
+		}),
+	})
+}
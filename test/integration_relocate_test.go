@@ -0,0 +1,139 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/journalcmd"
+	"github.com/pol-rivero/doot/lib/commands/relocate"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelocate_MovedDotfilesDir(t *testing.T) {
+	oldDir := setUp_TestRelocate(t)
+	newDir := moveDotfilesDir(t, oldDir)
+
+	relocate.Relocate(oldDir, newDir)
+	assertHomeSymlink(t, ".bashrc", newDir+"/bashrc")
+	assertHomeSymlink(t, ".config/app/settings", newDir+"/config/app/settings")
+	assertCacheKeys(t, []string{newDir + ":" + homeDir()})
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: newDir + "/bashrc"},
+		{Path: homePath.Join(".config/app/settings"), Content: newDir + "/config/app/settings"},
+	})
+}
+
+func TestRelocate_DetectMovedDotfilesDir(t *testing.T) {
+	oldDir := setUp_TestRelocate(t)
+	newDir := moveDotfilesDir(t, oldDir)
+
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", newDir+"/bashrc")
+	assertHomeSymlink(t, ".config/app/settings", newDir+"/config/app/settings")
+	assertCacheKeys(t, []string{newDir + ":" + homeDir()})
+}
+
+func TestRelocate_DoNotDetectDifferentContents(t *testing.T) {
+	oldDir := setUp_TestRelocate(t)
+	newDir := moveDotfilesDir(t, oldDir)
+	assert.NoError(t, filesystem.Remove(newDir+"/bashrc"))
+
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	install.Install(false)
+	assertHomeSymlink(t, ".config/app/settings", newDir+"/config/app/settings")
+	assertCacheKeys(t, []string{oldDir + ":" + homeDir(), newDir + ":" + homeDir()})
+}
+
+func TestRelocate_ChangedTargetDir(t *testing.T) {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+	})
+	oldTarget := filepath.Join(homeDir(), "old")
+	newTarget := filepath.Join(homeDir(), "new")
+	createNode(sourceDir(), Dir("doot", []FsNode{ConfigFile(targetDirConfig(oldTarget))}))
+	install.Install(false)
+	assertHomeSymlink(t, "old/.bashrc", sourceDir()+"/bashrc")
+
+	createNode(sourceDir(), Dir("doot", []FsNode{ConfigFile(targetDirConfig(newTarget))}))
+	assert.NoError(t, filesystem.MkdirAll(newTarget, 0755))
+	relocate.Relocate(oldTarget, newTarget)
+	assertHomeSymlink(t, "new/.bashrc", sourceDir()+"/bashrc")
+	assertNoFileExists(t, oldTarget+"/.bashrc")
+	assertCacheKeys(t, []string{sourceDir() + ":" + newTarget})
+
+	install.Install(false)
+	assertDirContents(t, newTarget, []string{".bashrc"})
+}
+
+func TestRelocate_Undo(t *testing.T) {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+	})
+	oldTarget := filepath.Join(homeDir(), "old")
+	newTarget := filepath.Join(homeDir(), "new")
+	createNode(sourceDir(), Dir("doot", []FsNode{ConfigFile(targetDirConfig(oldTarget))}))
+	install.Install(false)
+	assert.NoError(t, filesystem.MkdirAll(newTarget, 0755))
+	relocate.Relocate(oldTarget, newTarget)
+	assertHomeSymlink(t, "new/.bashrc", sourceDir()+"/bashrc")
+
+	op := journalcmd.Undo()
+	assert.Equal(t, "relocate", op.Command)
+	assertHomeSymlink(t, "old/.bashrc", sourceDir()+"/bashrc")
+	assertNoFileExists(t, newTarget+"/.bashrc")
+	assertCacheKeys(t, []string{sourceDir() + ":" + oldTarget})
+}
+
+func TestRelocate_NothingToRelocate(t *testing.T) {
+	setUp_TestRelocate(t)
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		relocate.Relocate("/does/not/exist", tempDir(t))
+	})
+}
+
+// Returns the dotfiles dir
+func setUp_TestRelocate(t *testing.T) string {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+		Dir("config", []FsNode{
+			Dir("app", []FsNode{File("settings")}),
+		}),
+	})
+	install.Install(false)
+	return sourceDir()
+}
+
+func moveDotfilesDir(t *testing.T, oldDir string) string {
+	newDir := filepath.Join(tempDir(t), "moved")
+	assert.NoError(t, filesystem.Rename(oldDir, newDir))
+	t.Setenv(common.ENV_DOOT_DIR, newDir)
+	return newDir
+}
+
+func targetDirConfig(targetDir string) config.Config {
+	targetConfig := config.DefaultConfig()
+	targetConfig.TargetDir = targetDir
+	return targetConfig
+}
+
+func assertCacheKeys(t *testing.T, expected []string) {
+	t.Helper()
+	dootCache := cache.Load()
+	actual := make([]string, 0, len(dootCache.Entries))
+	for _, entry := range dootCache.Entries {
+		actual = append(actual, entry.CacheKey)
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
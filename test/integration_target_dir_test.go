@@ -0,0 +1,78 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetDir_InstallIntoAlternateRoot(t *testing.T) {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+		Dir("config", []FsNode{
+			File("app.conf"),
+		}),
+	})
+	targetDir := tempDir(t)
+	setTargetDirOverride(t, targetDir)
+
+	install.Install(false)
+
+	assertHomeDirContents(t, "", []string{})
+	assertDirContents(t, targetDir, []string{".bashrc", ".config"})
+	assertLink(t, filepath.Join(targetDir, ".bashrc"), sourceDir()+"/bashrc")
+	assertLink(t, filepath.Join(targetDir, ".config/app.conf"), sourceDir()+"/config/app.conf")
+
+	dootCache := cache.Load()
+	homeLinks := dootCache.GetEntry(sourceDir() + ":" + homeDir()).GetLinks()
+	targetLinks := dootCache.GetEntry(sourceDir() + ":" + targetDir).GetLinks()
+	assert.Equal(t, 0, homeLinks.Len())
+	assert.Equal(t, 2, targetLinks.Len())
+}
+
+func TestTargetDir_CleanOnlyAffectsAlternateRoot(t *testing.T) {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+	})
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+
+	targetDir := tempDir(t)
+	setTargetDirOverride(t, targetDir)
+	install.Install(false)
+	assertDirContents(t, targetDir, []string{".bashrc"})
+
+	install.Clean(false)
+	assertDirContents(t, targetDir, []string{})
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+}
+
+func TestTargetDir_DotfilesDirOverride(t *testing.T) {
+	SetUp(t, false)
+	otherDotfilesDir := tempDir(t)
+	createNode(otherDotfilesDir, File("zshrc"))
+	t.Cleanup(func() { common.SetDotfilesDirOverride("") })
+	common.SetDotfilesDirOverride(otherDotfilesDir)
+
+	assert.Equal(t, otherDotfilesDir, sourceDir(), "DOOT_DIR should be updated")
+	install.Install(false)
+	assertHomeSymlink(t, ".zshrc", otherDotfilesDir+"/zshrc")
+}
+
+func setTargetDirOverride(t *testing.T, targetDir string) {
+	t.Cleanup(func() { config.SetTargetDirOverride("") })
+	config.SetTargetDirOverride(targetDir)
+}
+
+func assertLink(t *testing.T, link string, expectedTarget string) {
+	t.Helper()
+	target, err := filesystem.Readlink(link)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTarget, target)
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction_RollbackOnFailedHook(t *testing.T) {
+	runOnDisk(t)
+	setUp_TestUndo(t)
+	createHookFile("after-update", "after.sh", `#!/bin/bash
+		exit 1`)
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		install.Install(false)
+	})
+	assertHomeDirContents(t, "", []string{})
+	assertCache(t, []AssertCacheEntry{})
+	assert.Empty(t, journal.Load(), "Rolled back operations should not be recorded")
+}
+
+func TestTransaction_RollbackKeepsPreviousInstall(t *testing.T) {
+	runOnDisk(t)
+	setUp_TestUndo(t)
+	install.Install(false)
+	createNode(sourceDir(), File("vimrc"))
+	createNode(homeDir(), FsFile{Name: ".vimrc", Content: "my old vimrc"})
+	createHookFile("after-update", "after.sh", `#!/bin/bash
+		exit 1`)
+
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		install.Install(false)
+	})
+	assertHomeRegularFile(t, ".vimrc")
+	assert.Equal(t, "my old vimrc", readFile(homeDir()+"/.vimrc"))
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".config/app/settings"), Content: sourceDir() + "/config/app/settings"},
+	})
+	assert.Len(t, journal.Load(), 1)
+}
+
+func TestTransaction_FailedLinksAreNotCached(t *testing.T) {
+	setUp_TestUndo(t)
+	// .config/app is a file, so .config/app/settings can't be created
+	createNode(homeDir(), Dir(".config", []FsNode{FsFile{Name: "app", Content: "not a directory"}}))
+
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	assertCache(t, []AssertCacheEntry{
+		{Path: NewAbsolutePath(homeDir()).Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+	})
+}
+
+func TestTransaction_RollbackOnSignal(t *testing.T) {
+	setUp_TestUndo(t)
+	exitCodes := make(chan int, 1)
+	journal.ExitOnSignal = func(code int) { exitCodes <- code }
+	defer func() { journal.ExitOnSignal = os.Exit }()
+
+	journal.Begin("install", nil)
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	process, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, process.Signal(os.Interrupt))
+	select {
+	case code := <-exitCodes:
+		assert.Equal(t, journal.INTERRUPTED_EXIT_CODE, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The signal was not handled")
+	}
+	journal.End()
+
+	assertHomeDirContents(t, "", []string{})
+	assertCache(t, []AssertCacheEntry{})
+	assert.Empty(t, journal.Load(), "Interrupted operations should not be recorded")
+
+	// The next command is recorded normally
+	install.Install(false)
+	assert.Len(t, journal.Load(), 1)
+}
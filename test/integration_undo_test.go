@@ -0,0 +1,153 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/add"
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/journalcmd"
+	"github.com/pol-rivero/doot/lib/commands/restore"
+	"github.com/pol-rivero/doot/lib/common/cache"
+	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/common/journal"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndo_Install(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	assertHomeSymlink(t, ".config/app/settings", sourceDir()+"/config/app/settings")
+
+	journalcmd.Undo()
+	assertHomeDirContents(t, "", []string{})
+	assertCache(t, []AssertCacheEntry{})
+}
+
+func TestUndo_Clean(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	install.Clean(false)
+	assertHomeDirContents(t, "", []string{})
+
+	journalcmd.Undo()
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+	assertHomeSymlink(t, ".config/app/settings", sourceDir()+"/config/app/settings")
+	homePath := NewAbsolutePath(homeDir())
+	assertCache(t, []AssertCacheEntry{
+		{Path: homePath.Join(".bashrc"), Content: sourceDir() + "/bashrc"},
+		{Path: homePath.Join(".config/app/settings"), Content: sourceDir() + "/config/app/settings"},
+	})
+
+	journalcmd.Undo()
+	assertHomeDirContents(t, "", []string{})
+	journalcmd.Undo()
+	assertHomeDirContents(t, "", []string{})
+}
+
+func TestUndo_RestoresOverwrittenFile(t *testing.T) {
+	setUp_TestUndo(t)
+	createNode(homeDir(), FsFile{Name: ".bashrc", Content: "my old bashrc"})
+	utils.USER_INPUT_MOCK_RESPONSE = "y"
+	install.Install(false)
+	assertHomeSymlink(t, ".bashrc", sourceDir()+"/bashrc")
+
+	journalcmd.Undo()
+	assertHomeRegularFile(t, ".bashrc")
+	assert.Equal(t, "my old bashrc", readFile(homeDir()+"/.bashrc"))
+	assertHomeDirContents(t, "", []string{".bashrc"})
+}
+
+func TestUndo_AdoptedChanges(t *testing.T) {
+	setUp_TestUndo(t)
+	createNode(homeDir(), FsFile{Name: ".bashrc", Content: "my old bashrc"})
+	utils.USER_INPUT_MOCK_RESPONSE = "a"
+	install.Install(false)
+	assert.Equal(t, "my old bashrc", readFile(sourceDir()+"/bashrc"))
+
+	journalcmd.Undo()
+	assertHomeRegularFile(t, ".bashrc")
+	assert.Equal(t, "my old bashrc", readFile(homeDir()+"/.bashrc"))
+	assert.Equal(t, "dummy text for file bashrc", readFile(sourceDir()+"/bashrc"))
+}
+
+func TestUndo_SkipsModifiedLinks(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	assert.NoError(t, filesystem.Remove(homeDir()+"/.bashrc"))
+	createNode(homeDir(), FsFile{Name: ".bashrc", Content: "edited by hand"})
+
+	journalcmd.Undo()
+	assert.Equal(t, "edited by hand", readFile(homeDir()+"/.bashrc"))
+	assertHomeDirContents(t, "", []string{".bashrc"})
+}
+
+func TestUndo_AddAndRestore(t *testing.T) {
+	addConfig := config.DefaultConfig()
+	addConfig.ImplicitDot = false
+	setUpFiles_TestAdd(t, addConfig, true)
+	chdir(t, homeDir())
+
+	add.Add([]string{homeDir() + "/dir1/file3"}, false, false)
+	assertHomeSymlink(t, "dir1/file3", sourceDir()+"/dir1/file3")
+	journalcmd.Undo()
+	assertHomeRegularFile(t, "dir1/file3")
+	assertSourceDirContents(t, "", []string{"doot"})
+	assertCache(t, []AssertCacheEntry{})
+
+	add.Add([]string{"file1"}, false, false)
+	restore.Restore([]string{"file1"})
+	assertHomeRegularFile(t, "file1")
+	assertSourceDirContents(t, "", []string{"doot"})
+	journalcmd.Undo()
+	assertHomeSymlink(t, "file1", sourceDir()+"/file1")
+	assertCache(t, []AssertCacheEntry{
+		{Path: NewAbsolutePath(homeDir() + "/file1"), Content: sourceDir() + "/file1"},
+	})
+}
+
+func TestUndo_KeepsOtherCacheEntries(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	dootCache := cache.Load()
+	otherEntry := dootCache.GetEntry(cache.ComputeCacheKey("/other/dotfiles", "/other/home"))
+	otherEntry.Links = []*cache.InstalledFile{{Path: "/other/home/.vimrc", Content: "/other/dotfiles/vimrc"}}
+	dootCache.Save()
+
+	journalcmd.Undo()
+	assertHomeDirContents(t, "", []string{})
+	dootCache = cache.Load()
+	otherEntry = dootCache.FindEntry(cache.ComputeCacheKey("/other/dotfiles", "/other/home"))
+	assert.NotNil(t, otherEntry)
+	assert.Len(t, otherEntry.Links, 1)
+}
+
+func TestUndo_Log(t *testing.T) {
+	setUp_TestUndo(t)
+	install.Install(false)
+	install.Install(false)
+	install.Clean(false)
+	journalcmd.Undo()
+
+	operations := journal.Load()
+	assert.Len(t, operations, 3, "Operations without changes should not be recorded")
+	assert.Equal(t, "install", operations[0].Command)
+	assert.Len(t, operations[0].Actions, 2)
+	assert.Equal(t, "clean", operations[1].Command)
+	assert.Equal(t, journal.UNDO_COMMAND, operations[2].Command)
+	assert.Equal(t, []int{operations[1].Id}, journal.UndoneIds(operations))
+	assert.Equal(t, operations[0].Id, journal.LastUndoable(operations).Id)
+	journalcmd.PrintLog()
+}
+
+func setUp_TestUndo(t *testing.T) {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+		Dir("config", []FsNode{
+			Dir("app", []FsNode{File("settings")}),
+		}),
+	})
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/utils/merge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge_MergePatch(t *testing.T) {
+	target := map[string]any{
+		"a": "b",
+		"c": map[string]any{
+			"d": "e",
+			"f": "g",
+		},
+		"list": []any{1, 2},
+	}
+	patch := map[string]any{
+		"a": "z",
+		"c": map[string]any{
+			"f": nil,
+		},
+		"list": []any{3},
+		"new":  map[string]any{"x": nil, "y": 1},
+	}
+	assert.Equal(t, map[string]any{
+		"a": "z",
+		"c": map[string]any{
+			"d": "e",
+		},
+		"list": []any{3},
+		"new":  map[string]any{"y": 1},
+	}, merge.MergePatch(target, patch))
+}
+
+func TestMerge_MergePatchReplacesNonObjects(t *testing.T) {
+	assert.Equal(t, "patch", merge.MergePatch(map[string]any{"a": 1}, "patch"))
+	assert.Equal(t, map[string]any{"a": 1}, merge.MergePatch("target", map[string]any{"a": 1}))
+}
+
+func TestMerge_FormatFromPath(t *testing.T) {
+	format, ok := merge.FormatFromPath("/home/user/.config/Code/User/settings.json")
+	assert.True(t, ok)
+	assert.Equal(t, merge.FORMAT_JSON, format)
+	format, ok = merge.FormatFromPath("starship.TOML")
+	assert.True(t, ok)
+	assert.Equal(t, merge.FORMAT_TOML, format)
+	format, ok = merge.FormatFromPath("alacritty.yml")
+	assert.True(t, ok)
+	assert.Equal(t, merge.FORMAT_YAML, format)
+	_, ok = merge.FormatFromPath(".bashrc")
+	assert.False(t, ok)
+}
+
+func TestMerge_ParseAndSerialize(t *testing.T) {
+	formats := map[merge.Format]string{
+		merge.FORMAT_JSON: "{\"a\": {\"b\": 1}, \"c\": [\"d\"]}",
+		merge.FORMAT_TOML: "c = ['d']\n[a]\nb = 1\n",
+		merge.FORMAT_YAML: "a:\n  b: 1\nc:\n  - d\n",
+	}
+	for format, contents := range formats {
+		document, err := merge.Parse(format, []byte(contents))
+		assert.NoError(t, err)
+		serialized, err := merge.Serialize(format, document)
+		assert.NoError(t, err)
+		reparsed, err := merge.Parse(format, serialized)
+		assert.NoError(t, err)
+		assert.Equal(t, document, reparsed)
+		assert.Contains(t, reparsed, "a")
+		assert.Contains(t, reparsed, "c")
+	}
+}
+
+func TestMerge_ParseJsonWithComments(t *testing.T) {
+	contents := `{
+		// Line comment, with a "quote" and a trailing comma,
+		"a": {"b": 1, /* block, comment */ },
+		/* Multi-line
+		   block comment */
+		"url": "http://example.com/*not a comment*/",
+		"c": ["d", "e\\", ],
+	}`
+	document, err := merge.Parse(merge.FORMAT_JSON, []byte(contents))
+	assert.NoError(t, err)
+	serialized, err := merge.Serialize(merge.FORMAT_JSON, document)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a": {"b": 1}, "url": "http://example.com/*not a comment*/", "c": ["d", "e\\"]}`, string(serialized))
+
+	_, err = merge.Parse(merge.FORMAT_JSON, []byte(`{"a": 1 /* unterminated`))
+	assert.Error(t, err)
+}
+
+func TestMerge_ParseInvalid(t *testing.T) {
+	_, err := merge.Parse(merge.FORMAT_JSON, []byte("{"))
+	assert.Error(t, err)
+	_, err = merge.Parse(merge.FORMAT_YAML, []byte("- not\n- a map\n"))
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/pol-rivero/doot/lib/common/config"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,16 +14,16 @@ func TestMetatest_CreateEmptyTempDirs(t *testing.T) {
 	SetUp(t, true)
 	dootDir := sourceDir()
 	assert.NotEmpty(t, dootDir, "DOOT_DIR not set")
-	assert.DirExists(t, dootDir, "DOOT_DIR does not exist")
+	assertDirExists(t, dootDir, "DOOT_DIR does not exist")
 
 	cacheDir := cacheDir()
 	assert.NotEmpty(t, cacheDir, "DOOT_CACHE_DIR not set")
-	assert.DirExists(t, cacheDir, "DOOT_CACHE_DIR does not exist")
+	assertDirExists(t, cacheDir, "DOOT_CACHE_DIR does not exist")
 
 	targetDir, err := os.UserHomeDir()
 	assert.NoError(t, err, "Error retrieving home directory")
 	assert.Regexp(t, "^/tmp/", targetDir, "Temporary HOME was not created in /tmp")
-	assert.DirExists(t, targetDir, "HOME does not exist")
+	assertDirExists(t, targetDir, "HOME does not exist")
 
 	homeDir := homeDir()
 	assert.Equal(t, targetDir, homeDir, "homeDir() returned unexpected value")
@@ -39,14 +40,14 @@ func TestMetatest_CreateTempDirs(t *testing.T) {
 		}),
 	})
 	dootDir := sourceDir()
-	assert.FileExists(t, dootDir+"/topLevelFile")
+	assertFileExists(t, dootDir+"/topLevelFile")
 
-	fileContents, err := os.ReadFile(dootDir + "/topLevelFile")
+	fileContents, err := filesystem.ReadFile(dootDir + "/topLevelFile")
 	assert.NoError(t, err, "Error reading file")
 	assert.Equal(t, "dummy text for file topLevelFile", string(fileContents), "topLevelFile has unexpected contents")
 
-	assert.FileExists(t, dootDir+"/topLevelDir/file1")
-	assert.FileExists(t, dootDir+"/topLevelDir/nestedDir/file2")
+	assertFileExists(t, dootDir+"/topLevelDir/file1")
+	assertFileExists(t, dootDir+"/topLevelDir/nestedDir/file2")
 }
 
 func TestMetatest_CreateConfig(t *testing.T) {
@@ -60,9 +61,9 @@ func TestMetatest_CreateConfig(t *testing.T) {
 		ConfigFile(config),
 	})
 	dootDir := sourceDir()
-	assert.FileExists(t, filepath.Join(dootDir, "config.toml"))
+	assertFileExists(t, filepath.Join(dootDir, "config.toml"))
 
-	fileContents, err := os.ReadFile(filepath.Join(dootDir, "config.toml"))
+	fileContents, err := filesystem.ReadFile(filepath.Join(dootDir, "config.toml"))
 	assert.NoError(t, err, "Error reading file")
 	assert.Regexp(t, "^target_dir = '\\$HOME", string(fileContents), "config.toml has unexpected first line")
 	assert.Contains(t, string(fileContents), "[hosts]\nmy-laptop = 'laptop-dots'\nother-pc = 'other-dots'", "config.toml does not contain expected hosts section")
@@ -74,9 +75,25 @@ func TestMetatest_CreateConfigBeforeSetUp(t *testing.T) {
 		ConfigFile(config),
 	})
 	dootDir := sourceDir()
-	assert.FileExists(t, filepath.Join(dootDir, "config.toml"))
+	assertFileExists(t, filepath.Join(dootDir, "config.toml"))
 
-	fileContents, err := os.ReadFile(filepath.Join(dootDir, "config.toml"))
+	fileContents, err := filesystem.ReadFile(filepath.Join(dootDir, "config.toml"))
 	assert.NoError(t, err, "Error reading file")
 	assert.Regexp(t, "^target_dir = '\\$HOME", string(fileContents), "$HOME was not replaced before writing config.toml")
 }
+
+func TestMetatest_DotfilesInDifferentFilesystem(t *testing.T) {
+	SetUp(t, true)
+	dootDirId, _, err := filesystem.GetFileId(sourceDir(), false)
+	assert.NoError(t, err)
+	homeDirId, _, err := filesystem.GetFileId(homeDir(), false)
+	assert.NoError(t, err)
+	assert.NotEqual(t, dootDirId.Dev, homeDirId.Dev, "DOOT_DIR is in the same filesystem as HOME")
+
+	SetUp(t, false)
+	dootDirId, _, err = filesystem.GetFileId(sourceDir(), false)
+	assert.NoError(t, err)
+	homeDirId, _, err = filesystem.GetFileId(homeDir(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, dootDirId.Dev, homeDirId.Dev, "DOOT_DIR is not in the same filesystem as HOME")
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pol-rivero/doot/lib/commands/install"
+	"github.com/pol-rivero/doot/lib/commands/repo"
+	"github.com/pol-rivero/doot/lib/common"
+	"github.com/pol-rivero/doot/lib/common/log"
+	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepo_PointerFile(t *testing.T) {
+	dotfilesDir := setUp_TestRepo(t)
+	writeUserConfig(t, "dotfiles_dir = '"+dotfilesDir+"'\n")
+
+	assert.Equal(t, dotfilesDir, common.FindDotfilesDir().Str())
+}
+
+func TestRepo_DootDirTakesPrecedence(t *testing.T) {
+	dotfilesDir := setUp_TestRepo(t)
+	writeUserConfig(t, "dotfiles_dir = '"+dotfilesDir+"'\n")
+	otherDir := tempDir(t)
+	t.Setenv(common.ENV_DOOT_DIR, otherDir)
+
+	assert.Equal(t, otherDir, common.FindDotfilesDir().Str())
+}
+
+func TestRepo_AddAndUse(t *testing.T) {
+	firstDir := setUp_TestRepo(t)
+	secondDir := filepath.Join(tempDir(t), "second")
+	createNode(filepath.Dir(secondDir), Dir("second", []FsNode{File("zshrc")}))
+
+	repo.Add(firstDir, "first")
+	repo.Add(secondDir, "")
+	assert.Equal(t, firstDir, common.FindDotfilesDir().Str(), "The first registered repo should be used by default")
+
+	userConfig := common.LoadUserConfig()
+	assert.Equal(t, []string{"first", "second"}, userConfig.RepoNames())
+	assert.Equal(t, "first", userConfig.Current)
+
+	repo.Use("second")
+	assert.Equal(t, secondDir, common.FindDotfilesDir().Str())
+	install.Install(false)
+	assertHomeSymlink(t, ".zshrc", secondDir+"/zshrc")
+}
+
+func TestRepo_AddDuplicateName(t *testing.T) {
+	dotfilesDir := setUp_TestRepo(t)
+	repo.Add(dotfilesDir, "dots")
+	repo.Add(dotfilesDir, "dots")
+
+	log.PanicInsteadOfExit = true
+	defer func() { log.PanicInsteadOfExit = false }()
+	assert.Panics(t, func() {
+		repo.Add(tempDir(t), "dots")
+	})
+	assert.Equal(t, map[string]string{"dots": dotfilesDir}, common.LoadUserConfig().Repos)
+}
+
+func TestRepo_AddRegisteredDirRenamesIt(t *testing.T) {
+	dotfilesDir := setUp_TestRepo(t)
+	repo.Add(dotfilesDir, "dots")
+	repo.Add(dotfilesDir, "renamed")
+
+	userConfig := common.LoadUserConfig()
+	assert.Equal(t, map[string]string{"renamed": dotfilesDir}, userConfig.Repos)
+	assert.Equal(t, "renamed", userConfig.Current)
+	assert.Equal(t, dotfilesDir, common.FindDotfilesDir().Str())
+}
+
+func TestRepo_RegisterAndUse(t *testing.T) {
+	setUp_TestRepo(t)
+	dotfilesDir := filepath.Join(tempDir(t), "dotfiles")
+	otherDir := filepath.Join(tempDir(t), "dotfiles")
+	assert.NoError(t, filesystem.MkdirAll(dotfilesDir, 0755))
+	assert.NoError(t, filesystem.MkdirAll(otherDir, 0755))
+
+	repo.Add(otherDir, "")
+	repo.RegisterAndUse(NewAbsolutePath(dotfilesDir))
+	repo.RegisterAndUse(NewAbsolutePath(dotfilesDir))
+
+	userConfig := common.LoadUserConfig()
+	assert.Equal(t, []string{"dotfiles", "dotfiles-2"}, userConfig.RepoNames())
+	assert.Equal(t, "dotfiles-2", userConfig.Current)
+	assert.Equal(t, dotfilesDir, userConfig.SelectedDir())
+}
+
+// Returns the dotfiles dir, which is no longer referenced by $DOOT_DIR
+func setUp_TestRepo(t *testing.T) string {
+	SetUpFiles(t, false, []FsNode{
+		File("bashrc"),
+	})
+	t.Setenv(common.ENV_XDG_CONFIG_HOME, tempDir(t))
+	dotfilesDir := sourceDir()
+	t.Setenv(common.ENV_DOOT_DIR, "")
+	return dotfilesDir
+}
+
+func writeUserConfig(t *testing.T, content string) {
+	t.Helper()
+	path := common.UserConfigPath()
+	assert.NoError(t, filesystem.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, filesystem.WriteFile(path, []byte(content), 0644))
+}
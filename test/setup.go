@@ -11,25 +11,51 @@ import (
 	"github.com/pol-rivero/doot/lib/common/config"
 	. "github.com/pol-rivero/doot/lib/types"
 	"github.com/pol-rivero/doot/lib/utils"
-	fs "github.com/pol-rivero/doot/test/filesystem_emulation"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 )
 
+// Root of the directories created by SetUp when the tests run in memory
+const MEMORY_TEST_ROOT string = "/tmp/doot-test"
+
+var (
+	nextOnDisk    bool
+	tempDirsCount int
+)
+
+// Makes the next SetUp create the files on disk instead of in memory. Needed by the tests that run processes (hooks,
+// git, custom commands...), since they can't see the files in memory.
+func runOnDisk(t *testing.T) {
+	nextOnDisk = true
+	t.Cleanup(func() { nextOnDisk = false })
+}
+
+// If dotfilesInDifferentFilesystem is true, the dotfiles directory is a different device than the home directory, so
+// files can't be hard linked or moved between them. This is ignored when running on disk.
 func SetUp(t *testing.T, dotfilesInDifferentFilesystem bool) {
 	utils.USER_INPUT_MOCK_RESPONSE = utils.MOCK_NO_INPUT
-	var tempDootDir string
-	tempCacheDir := t.TempDir()
-	tempHomeDir := t.TempDir()
-
-	if dotfilesInDifferentFilesystem {
-		other := fs.PickDifferentFSDir(t, tempHomeDir)
-		tempDootDir = filepath.Join(other, "doot-"+strconv.Itoa(os.Getpid()))
-		if err := os.MkdirAll(tempDootDir, 0o755); err != nil {
-			t.Fatalf("mkdir DOOT_DIR on different FS: %v", err)
-		}
-		t.Cleanup(func() { _ = os.RemoveAll(filepath.Dir(tempDootDir)) })
+	var tempDootDir, tempCacheDir, tempHomeDir string
 
-	} else {
+	if nextOnDisk {
+		useFileSystem(t, filesystem.OS{})
 		tempDootDir = t.TempDir()
+		tempCacheDir = t.TempDir()
+		tempHomeDir = t.TempDir()
+	} else {
+		memory := filesystem.NewMemory()
+		tempDootDir = filepath.Join(MEMORY_TEST_ROOT, "dotfiles")
+		tempCacheDir = filepath.Join(MEMORY_TEST_ROOT, "cache")
+		tempHomeDir = filepath.Join(MEMORY_TEST_ROOT, "home")
+		if dotfilesInDifferentFilesystem {
+			if err := memory.Mount(tempDootDir); err != nil {
+				t.Fatalf("mount DOOT_DIR on different FS: %v", err)
+			}
+		}
+		useFileSystem(t, memory)
+		for _, dir := range []string{tempDootDir, tempCacheDir, tempHomeDir} {
+			if err := filesystem.MkdirAll(dir, 0o755); err != nil {
+				t.Fatalf("mkdir %s: %v", dir, err)
+			}
+		}
 	}
 
 	os.Setenv(common.ENV_DOOT_DIR, tempDootDir)
@@ -37,6 +63,38 @@ func SetUp(t *testing.T, dotfilesInDifferentFilesystem bool) {
 	os.Setenv("HOME", tempHomeDir)
 }
 
+func useFileSystem(t *testing.T, fileSystem filesystem.FileSystem) {
+	previous := filesystem.Use(fileSystem)
+	t.Cleanup(func() { filesystem.Use(previous) })
+}
+
+// Same as t.Chdir, but it also works in memory
+func chdir(t *testing.T, dir string) {
+	memory, inMemory := filesystem.Current().(*filesystem.Memory)
+	if !inMemory {
+		t.Chdir(dir)
+		return
+	}
+	previous, _ := memory.Getwd()
+	if err := memory.Chdir(dir); err != nil {
+		t.Fatalf("chdir %s: %v", dir, err)
+	}
+	t.Cleanup(func() { memory.Chdir(previous) })
+}
+
+// Returns a new empty directory, in memory or on disk depending on how the test was set up
+func tempDir(t *testing.T) string {
+	if filesystem.IsReal() {
+		return t.TempDir()
+	}
+	tempDirsCount++
+	dir := filepath.Join(MEMORY_TEST_ROOT, "tmp", strconv.Itoa(tempDirsCount))
+	if err := filesystem.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", dir, err)
+	}
+	return dir
+}
+
 func SetUpFiles(t *testing.T, dotfilesInDifferentFilesystem bool, setUpDir []FsNode) {
 	SetUp(t, dotfilesInDifferentFilesystem)
 	for _, node := range setUpDir {
@@ -134,8 +192,8 @@ func createNode(parentDir string, node FsNode) {
 
 func createDir(parentDir string, dir FsDir) {
 	dirPath := filepath.Join(parentDir, dir.GetName())
-	err := os.Mkdir(dirPath, 0755)
-	if err != nil && !os.IsExist(err) {
+	err := filesystem.MkdirAll(dirPath, 0755)
+	if err != nil {
 		panic(err)
 	}
 	for _, child := range dir.GetChildren() {
@@ -145,13 +203,7 @@ func createDir(parentDir string, dir FsDir) {
 
 func createFile(parentDir string, file FsFile) {
 	filePath := filepath.Join(parentDir, file.GetName())
-	f, err := os.Create(filePath)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-
-	_, err = f.WriteString(file.Content)
+	err := filesystem.WriteFile(filePath, []byte(file.Content), 0644)
 	if err != nil {
 		panic(err)
 	}
@@ -159,20 +211,20 @@ func createFile(parentDir string, file FsFile) {
 
 func createSymlink(parentDir, name, target string) {
 	symlinkPath := filepath.Join(parentDir, name)
-	err := os.Symlink(target, symlinkPath)
+	err := filesystem.Symlink(target, symlinkPath)
 	if err != nil {
 		panic(err)
 	}
 }
 
 func replaceWithSymlink(parentDir, name, target string) {
-	os.Remove(filepath.Join(parentDir, name))
+	filesystem.Remove(filepath.Join(parentDir, name))
 	createSymlink(parentDir, name, target)
 }
 
 func createHardlink(parentDir, name, otherFile string) {
 	hardlinkPath := filepath.Join(parentDir, name)
-	err := os.Link(otherFile, hardlinkPath)
+	err := filesystem.Link(otherFile, hardlinkPath)
 	if err != nil {
 		panic(err)
 	}
@@ -1,14 +1,17 @@
 package test
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"syscall"
 	"testing"
 
 	"github.com/pol-rivero/doot/lib/commands/crypt"
 	"github.com/pol-rivero/doot/lib/common/cache"
 	. "github.com/pol-rivero/doot/lib/types"
+	"github.com/pol-rivero/doot/lib/utils/files"
+	"github.com/pol-rivero/doot/lib/utils/filesystem"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +28,7 @@ func assertSourceDirContents(t *testing.T, dir string, expected []string) {
 func assertDirContents(t *testing.T, path string, expected []string) {
 	t.Helper()
 	fileNames := []string{}
-	dirEntries, err := os.ReadDir(path)
+	dirEntries, err := filesystem.ReadDir(path)
 	assert.NoError(t, err, "Error reading directory")
 	for _, entry := range dirEntries {
 		fileNames = append(fileNames, entry.Name())
@@ -41,10 +44,10 @@ func assertHomeSymlink(t *testing.T, link string, target string) {
 
 func assertSymlink(t *testing.T, linkPath string, target string) {
 	t.Helper()
-	info, err := os.Lstat(linkPath)
+	info, err := filesystem.Lstat(linkPath)
 	assert.NoError(t, err, "Failed to get link info")
 	assert.True(t, info.Mode()&os.ModeSymlink != 0, "File is not a symlink")
-	targetPath, err := os.Readlink(linkPath)
+	targetPath, err := filesystem.Readlink(linkPath)
 	assert.NoError(t, err, "Failed to read link")
 	assert.Equal(t, target, targetPath)
 }
@@ -52,15 +55,15 @@ func assertSymlink(t *testing.T, linkPath string, target string) {
 func assertHomeHardlink(t *testing.T, link string, target string) {
 	t.Helper()
 	linkPath := filepath.Join(homeDir(), link)
-	info1, err := os.Lstat(linkPath)
+	info1, err := filesystem.Lstat(linkPath)
 	assert.NoError(t, err, "Failed to get link info")
-	info2, err := os.Lstat(target)
+	info2, err := filesystem.Lstat(target)
 	assert.NoError(t, err, "Failed to get target info")
-	stat1, ok1 := info1.Sys().(*syscall.Stat_t)
-	stat2, ok2 := info2.Sys().(*syscall.Stat_t)
-	assert.True(t, ok1 && ok2, "Failed to convert FileInfo.Sys() to *syscall.Stat_t")
-	assert.Equal(t, stat1.Dev, stat2.Dev, "Device numbers do not match")
-	assert.Equal(t, stat1.Ino, stat2.Ino, "Inode numbers do not match")
+	id1, _, err1 := filesystem.GetFileId(linkPath, false)
+	id2, _, err2 := filesystem.GetFileId(target, false)
+	assert.True(t, err1 == nil && err2 == nil, "Failed to get the file ids")
+	assert.Equal(t, id1.Dev, id2.Dev, "Device numbers do not match")
+	assert.Equal(t, id1.Inode, id2.Inode, "Inode numbers do not match")
 	assert.Equal(t, info1.Mode(), info2.Mode(), "File modes do not match")
 }
 
@@ -72,11 +75,49 @@ func assertHomeRegularFile(t *testing.T, path string) {
 
 func assertRegularFile(t *testing.T, filePath string) {
 	t.Helper()
-	info, err := os.Lstat(filePath)
+	info, err := filesystem.Lstat(filePath)
 	assert.NoError(t, err, "Failed to get file info")
 	assert.True(t, info.Mode().IsRegular(), "File is not a regular file")
 }
 
+// Same as assert.FileExists and its variants, but they work on the file system used by the test
+
+func assertFileExists(t *testing.T, path string, msgAndArgs ...any) {
+	t.Helper()
+	info, err := filesystem.Lstat(path)
+	if err != nil {
+		assert.Fail(t, fmt.Sprintf("unable to find file %q", path), msgAndArgs...)
+	} else if info.IsDir() {
+		assert.Fail(t, fmt.Sprintf("%q is a directory", path), msgAndArgs...)
+	}
+}
+
+func assertNoFileExists(t *testing.T, path string, msgAndArgs ...any) {
+	t.Helper()
+	info, err := filesystem.Lstat(path)
+	if err == nil && !info.IsDir() {
+		assert.Fail(t, fmt.Sprintf("file %q exists", path), msgAndArgs...)
+	}
+}
+
+func assertDirExists(t *testing.T, path string, msgAndArgs ...any) {
+	t.Helper()
+	info, err := filesystem.Lstat(path)
+	if err != nil {
+		assert.Fail(t, fmt.Sprintf("unable to find directory %q", path), msgAndArgs...)
+	} else if !info.IsDir() {
+		assert.Fail(t, fmt.Sprintf("%q is a file", path), msgAndArgs...)
+	}
+}
+
+func assertNoDirExists(t *testing.T, path string, msgAndArgs ...any) {
+	t.Helper()
+	info, err := filesystem.Lstat(path)
+	if err == nil && info.IsDir() {
+		assert.Fail(t, fmt.Sprintf("directory %q exists", path), msgAndArgs...)
+	}
+}
+
 type AssertCacheEntry struct {
 	Path    AbsolutePath
 	Content string
@@ -93,6 +134,20 @@ func assertCache(t *testing.T, expectTargets []AssertCacheEntry) {
 	assertSymlinkCollection(t, cacheEntry.GetLinks(), expectMap)
 }
 
+func assertGeneratedCache(t *testing.T, expectTargets []string) {
+	t.Helper()
+	dootCache := cache.Load()
+	cacheEntry := dootCache.GetEntry(sourceDir() + ":" + homeDir())
+	actualTargets := make([]string, 0)
+	for target, hash := range cacheEntry.GetGeneratedFiles() {
+		actualTargets = append(actualTargets, target.Str())
+		currentHash, err := files.HashFile(target)
+		assert.NoError(t, err)
+		assert.Equal(t, hash, currentHash, "Cached hash does not match the contents of %s", target)
+	}
+	assert.ElementsMatch(t, expectTargets, actualTargets)
+}
+
 func assertSymlinkCollection(t *testing.T, targets SymlinkCollection, expect map[AbsolutePath]AbsolutePath) {
 	t.Helper()
 	assert.Len(t, targets.Iter(), len(expect))
@@ -119,6 +174,7 @@ func initializeGitCrypt() {
 }
 
 func createHookFile(hook, scriptName, content string) {
+	requireDisk()
 	createNode(sourceDir(), Dir("doot", []FsNode{
 		Dir("hooks", []FsNode{
 			Dir(hook, []FsNode{
@@ -129,10 +185,11 @@ func createHookFile(hook, scriptName, content string) {
 			}),
 		}),
 	}))
-	os.Chmod(filepath.Join(sourceDir(), "doot", "hooks", hook, scriptName), 0755)
+	filesystem.Chmod(filepath.Join(sourceDir(), "doot", "hooks", hook, scriptName), 0755)
 }
 
 func createCustomCommandFile(name, content string) {
+	requireDisk()
 	createNode(sourceDir(), Dir("doot", []FsNode{
 		Dir("commands", []FsNode{
 			FsFile{
@@ -141,13 +198,30 @@ func createCustomCommandFile(name, content string) {
 			},
 		}),
 	}))
-	os.Chmod(filepath.Join(sourceDir(), "doot", "commands", name), 0755)
+	filesystem.Chmod(filepath.Join(sourceDir(), "doot", "commands", name), 0755)
+}
+
+// Processes can't see the files in memory, the test must call runOnDisk before SetUp
+func requireDisk() {
+	if !filesystem.IsReal() {
+		panic("this test runs processes, call runOnDisk before setting it up")
+	}
 }
 
 func readFile(path string) string {
-	content, err := os.ReadFile(path)
+	content, err := filesystem.ReadFile(path)
 	if err != nil {
 		panic(err)
 	}
 	return string(content)
 }
+
+func runGit(args ...string) {
+	requireDisk()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = sourceDir()
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		panic(fmt.Sprintf("git %v failed: %v\n%s", args, err, output))
+	}
+}